@@ -2,22 +2,28 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/http/pprof"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
 	"time"
 
+	"delivery-system/internal/buildinfo"
+	"delivery-system/internal/clock"
 	"delivery-system/internal/config"
 	"delivery-system/internal/database"
 	"delivery-system/internal/handlers"
 	"delivery-system/internal/kafka"
 	"delivery-system/internal/logger"
+	"delivery-system/internal/metrics"
 	"delivery-system/internal/models"
 	"delivery-system/internal/redis"
 	"delivery-system/internal/services"
+	"delivery-system/internal/webhooks"
 )
 
 func main() {
@@ -26,7 +32,23 @@ func main() {
 
 	// Инициализация логгера
 	log := logger.New(&cfg.Logger)
-	log.Info("Starting delivery system server...")
+	log.WithFields(map[string]interface{}{
+		"version":    buildinfo.Version,
+		"commit":     buildinfo.Commit,
+		"build_time": buildinfo.BuildTime,
+	}).Info("Starting delivery system server...")
+
+	if err := cfg.Server.ValidateTLS(); err != nil {
+		log.WithError(err).Fatal("Invalid server configuration")
+	}
+	trustedProxies, err := cfg.RateLimit.ParseTrustedProxies()
+	if err != nil {
+		log.WithError(err).Fatal("Invalid rate limit configuration")
+	}
+	apiKeyAuth, err := handlers.APIKeyAuth(cfg.Auth.APIKeys)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid authentication configuration")
+	}
 
 	// Подключение к базе данных
 	db, err := database.Connect(&cfg.Database, log)
@@ -49,84 +71,276 @@ func main() {
 	}
 	defer producer.Close()
 
+	// Инициализация метрик
+	metricsRegistry := metrics.NewRegistry()
+	ordersByStatusGauge := metricsRegistry.NewGauge("delivery_orders_by_status", "Current number of orders in each status", "status")
+	rateLimitMetrics := services.NewRateLimitMetrics(metricsRegistry)
+	consumerMetrics := kafka.NewConsumerMetrics(metricsRegistry)
+
 	// Создание Kafka consumer
-	consumer, err := kafka.NewConsumer(&cfg.Kafka, log)
+	consumer, err := kafka.NewConsumer(&cfg.Kafka, producer, consumerMetrics, log)
 	if err != nil {
 		log.WithError(err).Fatal("Failed to create Kafka consumer")
 	}
 	defer consumer.Stop()
 
 	// Инициализация сервисов
-	orderService := services.NewOrderService(db, log)
-	courierService := services.NewCourierService(db, log)
+	clk := clock.New()
+	pricingService := services.NewDeliveryPricingService(&cfg.DeliveryPricing)
+	orderService := services.NewOrderService(db, redisClient, pricingService, &cfg.Orders, log)
+	courierService := services.NewCourierService(db, redisClient, &cfg.Assignment, log)
+	statsService := services.NewStatsService(db, redisClient, log)
+	escalationService := services.NewEscalationService(db, producer, &cfg.Escalation, clk, log)
+	slaService := services.NewSLAService(db, producer, &cfg.SLA, clk, log)
+	cacheService := services.NewCacheService(redisClient, &cfg.Cache, log)
+	locationCache := services.NewCourierLocationCache(cacheService, &cfg.Cache, log)
+	cacheInvalidator := services.NewCacheInvalidator(cacheService, log)
+	webhookService := services.NewWebhookService(db, log)
+	offerHub := services.NewOrderOfferHub(courierService, locationCache, &cfg.Assignment, log)
+	autoAssignService := services.NewAutoAssignService(courierService, redisClient, producer, cacheService, metricsRegistry, &cfg.Assignment, log)
+
+	rateLimiterService := services.NewRateLimiterService(redisClient, &cfg.RateLimit, clk, rateLimitMetrics, log)
 
 	// Инициализация handlers
-	orderHandler := handlers.NewOrderHandler(orderService, producer, redisClient, log)
-	courierHandler := handlers.NewCourierHandler(courierService, producer, redisClient, log)
-	healthHandler := handlers.NewHealthHandler(db, redisClient)
+	orderHandler := handlers.NewOrderHandler(orderService, producer, cacheService, &cfg.Orders, &cfg.Pagination, log)
+	courierHandler := handlers.NewCourierHandler(courierService, producer, cacheService, locationCache, &cfg.Cache, &cfg.Pagination, log)
+	healthHandler := handlers.NewHealthHandler(db, redisClient, kafka.NewHealthChecker(cfg.Kafka.Brokers), consumer, log)
+	statsHandler := handlers.NewStatsHandler(statsService, log)
+	cacheHandler := handlers.NewCacheHandler(cacheService, log)
+	rateLimitHandler := handlers.NewRateLimitHandler(rateLimiterService, trustedProxies, log)
+	webhookHandler := handlers.NewWebhookHandler(webhookService, &cfg.Webhooks, log)
+	assignmentHandler := handlers.NewAssignmentHandler(autoAssignService, log)
+	offerHandler := handlers.NewOfferHandler(offerHub, log)
+
+	// Middleware ограничения частоты запросов создается только при включенном лимитере
+	var rateLimitMiddleware *handlers.RateLimitMiddleware
+	if cfg.RateLimit.Enabled {
+		rateLimitMiddleware = handlers.NewRateLimitMiddleware(rateLimiterService, &cfg.RateLimit, trustedProxies, log)
+	} else {
+		log.Warn("Rate limiting is disabled")
+	}
 
 	// Регистрация обработчиков событий Kafka
-	registerEventHandlers(consumer, log)
+	registerEventHandlers(consumer, locationCache, cacheInvalidator, statsService, autoAssignService, offerHub, log)
 
-	// Запуск Kafka consumer
-	if err := consumer.Start(); err != nil {
-		log.WithError(err).Fatal("Failed to start Kafka consumer")
+	// Доставка событий подписчикам webhooks
+	webhookDispatcher := webhooks.NewDispatcher(webhookService, producer, &cfg.Webhooks, log)
+	for _, eventType := range models.EventTypes {
+		consumer.RegisterHandler(eventType, webhookDispatcher.HandleEvent)
+	}
+
+	// Фоновые задачи и consumer обращаются к столбцам, которые добавляют миграции,
+	// поэтому запускаются только после их применения
+	bgCtx, bgCancel := context.WithCancel(context.Background())
+	defer bgCancel()
+
+	startBackground := func() error {
+		// Счетчики заказов по статусам заполняются до запуска consumer, чтобы следующие события учитывались поверх них.
+		// Без счетчиков статистика считается запросом к базе данных
+		if err := statsService.SeedOrderCounters(context.Background()); err != nil {
+			log.WithError(err).Warn("Failed to seed order counters")
+		}
+
+		// Запуск Kafka consumer
+		if err := consumer.Start(); err != nil {
+			return fmt.Errorf("failed to start Kafka consumer: %w", err)
+		}
+
+		// Запуск фоновых задач
+		go statsService.RunOrderStatusGauge(bgCtx, ordersByStatusGauge,
+			time.Duration(cfg.Metrics.OrderStatusRefreshInterval)*time.Second)
+		go escalationService.Run(bgCtx)
+		go autoAssignService.Run(bgCtx)
+		go slaService.Run(bgCtx)
+		go webhookDispatcher.Run(bgCtx)
+		return nil
+	}
+
+	// Сброс нагрузки при исчерпании пула соединений с БД или недоступности Redis.
+	// Схему БД он не использует, поэтому защищает HTTP сервер и во время миграций
+	loadShedder := handlers.NewLoadShedder(db, redisClient, &cfg.LoadShedding, metricsRegistry, log)
+	if cfg.LoadShedding.Enabled {
+		go loadShedder.Run(bgCtx)
 	}
 
 	// Настройка HTTP роутера
-	mux := setupRoutes(orderHandler, courierHandler, healthHandler)
+	mux := setupRoutes(orderHandler, courierHandler, assignmentHandler, offerHandler, healthHandler, statsHandler, cacheHandler, rateLimitHandler, webhookHandler, rateLimitMiddleware,
+		handlers.AdminAuth(cfg.Admin.Token), metricsRegistry)
+
+	// Счетчик активных запросов для диагностики при остановке
+	inFlight := handlers.NewInFlightTracker()
 
 	// Создание HTTP сервера
 	server := &http.Server{
-		Addr:         fmt.Sprintf("%s:%s", cfg.Server.Host, cfg.Server.Port),
-		Handler:      mux,
-		ReadTimeout:  time.Duration(cfg.Server.ReadTimeout) * time.Second,
-		WriteTimeout: time.Duration(cfg.Server.WriteTimeout) * time.Second,
+		Addr:              fmt.Sprintf("%s:%s", cfg.Server.Host, cfg.Server.Port),
+		Handler:           inFlight.Wrap(handlers.RequestID(handlers.Localize(cfg.Server.DefaultLanguage)(loadShedder.Wrap(handlers.JSONCase(cfg.Server.JSONCase)(apiKeyAuth(mux.ServeHTTP)))))),
+		ReadTimeout:       time.Duration(cfg.Server.ReadTimeout) * time.Second,
+		WriteTimeout:      time.Duration(cfg.Server.WriteTimeout) * time.Second,
+		IdleTimeout:       time.Duration(cfg.Server.IdleTimeout) * time.Second,
+		ReadHeaderTimeout: time.Duration(cfg.Server.ReadHeaderTimeout) * time.Second,
 	}
 
+	// Потоки предложений заказов не завершаются сами, поэтому закрываются в начале остановки
+	server.RegisterOnShutdown(offerHub.Close)
+
 	// Запуск сервера в горутине
 	go func() {
-		log.WithField("address", server.Addr).Info("HTTP server starting")
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if cfg.Server.TLSEnabled() {
+			// ListenAndServeTLS автоматически включает HTTP/2
+			log.WithField("address", server.Addr).Info("HTTPS server starting")
+			err = server.ListenAndServeTLS(cfg.Server.TLSCertPath, cfg.Server.TLSKeyPath)
+		} else {
+			log.WithField("address", server.Addr).Info("HTTP server starting")
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.WithError(err).Fatal("HTTP server failed")
 		}
 	}()
 
-	// Ожидание сигнала завершения
+	// Профилирование доступно только на отдельном адресе
+	var pprofServer *http.Server
+	if cfg.Debug.PprofEnabled {
+		pprofServer = startPprofServer(cfg.Debug.PprofAddr, log)
+	}
+
+	// Миграции применяются после запуска сервера: liveness отвечает сразу,
+	// а readiness возвращает 503 до их завершения и запуска фоновых задач.
+	// При ошибке сервер останавливается штатно, а не прерывается посреди обработки запросов
+	startupFailed := make(chan error, 1)
+	if cfg.Database.AutoMigrate {
+		healthHandler.SetMigrationsPending(true)
+		go func() {
+			if err := db.Migrate(bgCtx, cfg.Database.MigrationsDir, log); err != nil {
+				startupFailed <- fmt.Errorf("failed to apply database migrations: %w", err)
+				return
+			}
+			if err := startBackground(); err != nil {
+				startupFailed <- err
+				return
+			}
+			healthHandler.SetMigrationsPending(false)
+		}()
+	} else if err := startBackground(); err != nil {
+		log.WithError(err).Fatal("Failed to start background workers")
+	}
+
+	// Ожидание сигнала завершения или ошибки запуска
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	var startupErr error
+	select {
+	case <-quit:
+	case startupErr = <-startupFailed:
+		log.WithError(startupErr).Error("Server startup failed")
+	}
+
+	log.WithField("in_flight", inFlight.Count()).Info("Shutting down server...")
 
-	log.Info("Shutting down server...")
+	// Остановка фоновых задач
+	bgCancel()
 
 	// Graceful shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	const shutdownTimeout = 30 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancel()
 
-	if err := server.Shutdown(ctx); err != nil {
-		log.WithError(err).Error("Server forced to shutdown")
+	shutdownStart := time.Now()
+	shutdownErr := server.Shutdown(ctx)
+	shutdownLog := log.WithField("drain_duration_ms", time.Since(shutdownStart).Milliseconds()).
+		WithField("deadline_exceeded", errors.Is(shutdownErr, context.DeadlineExceeded)).
+		WithField("in_flight_remaining", inFlight.Count())
+	if shutdownErr != nil {
+		shutdownLog.WithError(shutdownErr).Error("Server forced to shutdown")
+	} else {
+		shutdownLog.Info("HTTP server drained")
+	}
+	if pprofServer != nil {
+		pprofServer.Shutdown(ctx)
 	}
 
 	log.Info("Server exited")
+	if startupErr != nil {
+		os.Exit(1)
+	}
+}
+
+// startPprofServer запускает обработчики net/http/pprof на отдельном адресе.
+// Используется собственный mux, чтобы профилирование не попало в публичный API
+func startPprofServer(addr string, log *logger.Logger) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		log.WithField("address", addr).Warn("pprof server starting")
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.WithError(err).Error("pprof server failed")
+		}
+	}()
+
+	return server
 }
 
 // setupRoutes настраивает маршруты HTTP сервера
-func setupRoutes(orderHandler *handlers.OrderHandler, courierHandler *handlers.CourierHandler, healthHandler *handlers.HealthHandler) *http.ServeMux {
+func setupRoutes(orderHandler *handlers.OrderHandler, courierHandler *handlers.CourierHandler, assignmentHandler *handlers.AssignmentHandler, offerHandler *handlers.OfferHandler, healthHandler *handlers.HealthHandler,
+	statsHandler *handlers.StatsHandler, cacheHandler *handlers.CacheHandler, rateLimitHandler *handlers.RateLimitHandler, webhookHandler *handlers.WebhookHandler, rateLimitMiddleware *handlers.RateLimitMiddleware,
+	admin func(http.HandlerFunc) http.HandlerFunc, metricsRegistry *metrics.Registry) *http.ServeMux {
 	mux := http.NewServeMux()
 
+	// limited применяет ограничение частоты запросов к API эндпоинтам, если лимитер включен
+	limited := func(next http.HandlerFunc) http.HandlerFunc { return next }
+	if rateLimitMiddleware != nil {
+		limited = rateLimitMiddleware.Wrap
+	}
+
 	// Health check endpoints
 	mux.HandleFunc("/health", corsMiddleware(healthHandler.Health))
 	mux.HandleFunc("/health/readiness", corsMiddleware(healthHandler.Readiness))
 	mux.HandleFunc("/health/liveness", corsMiddleware(healthHandler.Liveness))
+	mux.HandleFunc("/version", corsMiddleware(healthHandler.Version))
+
+	// Metrics endpoint
+	mux.HandleFunc("/metrics", metricsRegistry.Handler())
+
+	// Rate limit endpoints (не расходуют квоту клиента)
+	mux.HandleFunc("/api/rate-limit/status", corsMiddleware(rateLimitHandler.GetStatus))
+
+	// Admin endpoints (требуют токен администратора)
+	mux.HandleFunc("/api/admin/rate-limit/bans", corsMiddleware(admin(rateLimitHandler.ListBans)))
+
+	// Stats endpoints
+	mux.HandleFunc("/api/stats/orders-by-status", corsMiddleware(limited(statsHandler.GetOrdersByStatus)))
+
+	// Cache endpoints
+	mux.HandleFunc("/api/cache/metrics", corsMiddleware(limited(cacheHandler.GetMetrics)))
 
 	// Order endpoints
-	mux.HandleFunc("/api/orders", corsMiddleware(handleOrdersRoute(orderHandler)))
-	mux.HandleFunc("/api/orders/", corsMiddleware(handleOrderRoute(orderHandler)))
+	mux.HandleFunc("/api/orders", corsMiddleware(limited(handleOrdersRoute(orderHandler))))
+	mux.HandleFunc("/api/orders/", corsMiddleware(limited(handleOrderRoute(orderHandler, courierHandler, assignmentHandler))))
+	mux.HandleFunc("/api/orders/batch-get", corsMiddleware(limited(orderHandler.BatchGetOrders)))
+	mux.HandleFunc("/api/orders/unassigned", corsMiddleware(limited(orderHandler.GetUnassignedOrders)))
+	mux.HandleFunc("/api/orders/status/bulk", corsMiddleware(limited(orderHandler.BulkUpdateOrderStatus)))
 
 	// Courier endpoints
-	mux.HandleFunc("/api/couriers", corsMiddleware(handleCouriersRoute(courierHandler)))
-	mux.HandleFunc("/api/couriers/", corsMiddleware(handleCourierRoute(courierHandler)))
-	mux.HandleFunc("/api/couriers/available", corsMiddleware(courierHandler.GetAvailableCouriers))
+	mux.HandleFunc("/api/couriers", corsMiddleware(limited(handleCouriersRoute(courierHandler))))
+	mux.HandleFunc("/api/couriers/", corsMiddleware(limited(handleCourierRoute(courierHandler, offerHandler))))
+	mux.HandleFunc("/api/couriers/available", corsMiddleware(limited(courierHandler.GetAvailableCouriers)))
+	mux.HandleFunc("/api/couriers/report", corsMiddleware(limited(courierHandler.GetCourierReport)))
+
+	// Webhook subscription endpoints (требуют токен администратора: подписчик получает данные клиентов)
+	mux.HandleFunc("/api/webhooks", corsMiddleware(admin(limited(handleWebhooksRoute(webhookHandler)))))
+	mux.HandleFunc("/api/webhooks/", corsMiddleware(admin(limited(handleWebhookRoute(webhookHandler)))))
 
 	return mux
 }
@@ -146,15 +360,74 @@ func handleOrdersRoute(handler *handlers.OrderHandler) http.HandlerFunc {
 }
 
 // handleOrderRoute обрабатывает маршруты для отдельного заказа
-func handleOrderRoute(handler *handlers.OrderHandler) http.HandlerFunc {
+func handleOrderRoute(handler *handlers.OrderHandler, courierHandler *handlers.CourierHandler, assignmentHandler *handlers.AssignmentHandler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if strings.HasSuffix(r.URL.Path, "/status") {
+		if strings.HasSuffix(r.URL.Path, "/auto-assign") {
+			// Автоматическое назначение ближайшего свободного курьера
+			if r.Method == http.MethodPost {
+				assignmentHandler.AutoAssign(w, r)
+			} else {
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
+		} else if strings.HasSuffix(r.URL.Path, "/auto-assign/preview") {
+			// Предпросмотр автоматического назначения курьера
+			if r.Method == http.MethodGet {
+				courierHandler.PreviewAutoAssignment(w, r)
+			} else {
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
+		} else if strings.Contains(r.URL.Path, "/items/") {
+			// Изменение и удаление позиции заказа
+			switch r.Method {
+			case http.MethodPatch:
+				handler.UpdateOrderItem(w, r)
+			case http.MethodDelete:
+				handler.RemoveOrderItem(w, r)
+			default:
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
+		} else if strings.HasSuffix(r.URL.Path, "/status") {
 			// Обновление статуса заказа
 			if r.Method == http.MethodPut {
 				handler.UpdateOrderStatus(w, r)
 			} else {
 				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
 			}
+		} else if strings.HasSuffix(r.URL.Path, "/timeline") {
+			// Хронология заказа
+			if r.Method == http.MethodGet {
+				handler.GetOrderTimeline(w, r)
+			} else {
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
+		} else if strings.HasSuffix(r.URL.Path, "/ready") {
+			// Отметка о готовности заказа к выдаче
+			if r.Method == http.MethodPost {
+				handler.MarkOrderReady(w, r)
+			} else {
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
+		} else if strings.HasSuffix(r.URL.Path, "/proof") {
+			// Подтверждение доставки заказа
+			if r.Method == http.MethodPost {
+				handler.SubmitDeliveryProof(w, r)
+			} else {
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
+		} else if strings.HasSuffix(r.URL.Path, "/recalculate-price") {
+			// Пересчет стоимости доставки по текущим тарифам
+			if r.Method == http.MethodPost {
+				handler.RecalculateDeliveryCost(w, r)
+			} else {
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
+		} else if strings.HasSuffix(r.URL.Path, "/unassign") {
+			// Снятие курьера с заказа
+			if r.Method == http.MethodPost {
+				handler.UnassignOrder(w, r)
+			} else {
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
 		} else {
 			// Получение заказа по ID
 			if r.Method == http.MethodGet {
@@ -181,11 +454,60 @@ func handleCouriersRoute(handler *handlers.CourierHandler) http.HandlerFunc {
 }
 
 // handleCourierRoute обрабатывает маршруты для отдельного курьера
-func handleCourierRoute(handler *handlers.CourierHandler) http.HandlerFunc {
+func handleCourierRoute(handler *handlers.CourierHandler, offerHandler *handlers.OfferHandler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if strings.HasSuffix(r.URL.Path, "/status") {
-			// Обновление статуса курьера
-			if r.Method == http.MethodPut {
+		if strings.HasSuffix(r.URL.Path, "/offers/stream") {
+			// Поток предложений новых заказов рядом с курьером
+			if r.Method == http.MethodGet {
+				offerHandler.StreamOffers(w, r)
+			} else {
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
+		} else if strings.HasSuffix(r.URL.Path, "/deliveries") {
+			// История доставок курьера
+			if r.Method == http.MethodGet {
+				handler.GetCourierDeliveries(w, r)
+			} else {
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
+		} else if strings.HasSuffix(r.URL.Path, "/shift/start") {
+			// Начало смены курьера
+			if r.Method == http.MethodPost {
+				handler.StartShift(w, r)
+			} else {
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
+		} else if strings.HasSuffix(r.URL.Path, "/shift/end") {
+			// Окончание смены курьера
+			if r.Method == http.MethodPost {
+				handler.EndShift(w, r)
+			} else {
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
+		} else if strings.HasSuffix(r.URL.Path, "/deactivate") {
+			// Отключение курьера
+			if r.Method == http.MethodPost {
+				handler.DeactivateCourier(w, r)
+			} else {
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
+		} else if strings.HasSuffix(r.URL.Path, "/reactivate") {
+			// Возврат курьера в работу
+			if r.Method == http.MethodPost {
+				handler.ReactivateCourier(w, r)
+			} else {
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
+		} else if strings.HasSuffix(r.URL.Path, "/locations/batch") {
+			// Пакетная загрузка местоположений курьера
+			if r.Method == http.MethodPost {
+				handler.RecordLocationsBatch(w, r)
+			} else {
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
+		} else if strings.HasSuffix(r.URL.Path, "/status") {
+			// Обновление статуса курьера; местоположение меняется, только если переданы координаты
+			if r.Method == http.MethodPut || r.Method == http.MethodPatch {
 				handler.UpdateCourierStatus(w, r)
 			} else {
 				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
@@ -208,8 +530,59 @@ func handleCourierRoute(handler *handlers.CourierHandler) http.HandlerFunc {
 	}
 }
 
+// handleWebhooksRoute обрабатывает маршруты для коллекции подписок на webhooks
+func handleWebhooksRoute(handler *handlers.WebhookHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handler.GetSubscriptions(w, r)
+		case http.MethodPost:
+			handler.CreateSubscription(w, r)
+		default:
+			writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+	}
+}
+
+// handleWebhookRoute обрабатывает маршруты для отдельной подписки на webhooks
+func handleWebhookRoute(handler *handlers.WebhookHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handler.GetSubscription(w, r)
+		case http.MethodPut:
+			handler.UpdateSubscription(w, r)
+		case http.MethodDelete:
+			handler.DeleteSubscription(w, r)
+		default:
+			writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+	}
+}
+
 // registerEventHandlers регистрирует обработчики событий Kafka
-func registerEventHandlers(consumer *kafka.Consumer, log *logger.Logger) {
+func registerEventHandlers(consumer *kafka.Consumer, locationCache *services.CourierLocationCache, cacheInvalidator *services.CacheInvalidator,
+	statsService *services.StatsService, autoAssignService *services.AutoAssignService, offerHub *services.OrderOfferHub, log *logger.Logger) {
+	// Последнее местоположение курьера кешируется для чтения без обращения к базе данных
+	consumer.RegisterHandler(models.EventTypeLocationUpdated, locationCache.HandleLocationUpdated)
+
+	// Измененные заказы и курьеры удаляются из кеша, даже если изменение выполнено не через обработчик запроса
+	consumer.RegisterHandler(models.EventTypeOrderStatusChanged, cacheInvalidator.HandleOrderStatusChanged)
+	consumer.RegisterHandler(models.EventTypeOrderAmountChanged, cacheInvalidator.HandleOrderAmountChanged)
+	consumer.RegisterHandler(models.EventTypeCourierAssigned, cacheInvalidator.HandleCourierAssigned)
+	consumer.RegisterHandler(models.EventTypeCourierStatusChanged, cacheInvalidator.HandleCourierStatusChanged)
+
+	// Счетчики заказов по статусам для статистики без агрегирующих запросов к базе данных
+	consumer.RegisterHandler(models.EventTypeOrderCreated, statsService.HandleOrderCreated)
+	consumer.RegisterHandler(models.EventTypeOrderStatusChanged, statsService.HandleOrderStatusChanged)
+	consumer.RegisterHandler(models.EventTypeCourierAssigned, statsService.HandleCourierAssigned)
+
+	// Освободившийся курьер запускает назначение заказов из очереди ожидания
+	consumer.RegisterHandler(models.EventTypeCourierStatusChanged, autoAssignService.HandleCourierStatusChanged)
+
+	// Новые заказы предлагаются курьерам, подписанным на поток предложений
+	consumer.RegisterHandler(models.EventTypeOrderCreated, offerHub.HandleOrderCreated)
+
 	// Пример обработчика событий - можно расширить по необходимости
 	consumer.RegisterHandler("order.created", func(ctx context.Context, event *models.Event) error {
 		log.WithField("event_id", event.ID).Info("Processing order created event")
@@ -222,14 +595,39 @@ func registerEventHandlers(consumer *kafka.Consumer, log *logger.Logger) {
 		// Здесь можно добавить логику уведомлений, обновления статистики и т.д.
 		return nil
 	})
+
+	consumer.RegisterHandler(models.EventTypeOrderCancelled, func(ctx context.Context, event *models.Event) error {
+		log.WithField("event_id", event.ID).Info("Processing order cancelled event")
+		// Здесь можно добавить логику возвратов и освобождения остатков
+		return nil
+	})
+
+	consumer.RegisterHandler(models.EventTypeOrderReadyForPickup, func(ctx context.Context, event *models.Event) error {
+		log.WithField("event_id", event.ID).Info("Processing order ready for pickup event")
+		// Здесь можно добавить push-уведомление курьеру
+		return nil
+	})
+
+	consumer.RegisterHandler(models.EventTypeOrderUnassignedTimeout, func(ctx context.Context, event *models.Event) error {
+		log.WithField("event_id", event.ID).Warn("Processing order unassigned timeout event")
+		// Здесь можно добавить оповещение диспетчеров
+		return nil
+	})
+
+	consumer.RegisterHandler(models.EventTypeOrderSLABreached, func(ctx context.Context, event *models.Event) error {
+		log.WithField("event_id", event.ID).Warn("Processing order SLA breached event")
+		// Здесь можно добавить оповещение операционной команды
+		return nil
+	})
 }
 
 // corsMiddleware и другие helper функции
 func corsMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match, If-None-Match, If-Modified-Since, X-Request-ID, X-API-Key")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag, X-Request-ID, Last-Modified, Location, Warning, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, X-RateLimit-Warning, Retry-After, X-Pagination-Limit, X-Pagination-Offset, Content-Disposition")
 
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusOK)
@@ -241,7 +639,5 @@ func corsMiddleware(next http.HandlerFunc) http.HandlerFunc {
 }
 
 func writeErrorResponse(w http.ResponseWriter, statusCode int, message string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	fmt.Fprintf(w, `{"error": "%s", "message": "%s"}`, http.StatusText(statusCode), message)
+	handlers.WriteErrorResponse(w, statusCode, message)
 }
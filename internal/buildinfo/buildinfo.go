@@ -0,0 +1,28 @@
+package buildinfo
+
+// Значения задаются при сборке через -ldflags, например:
+//
+//	go build -ldflags "-X delivery-system/internal/buildinfo.Version=1.2.0 \
+//	  -X delivery-system/internal/buildinfo.Commit=$(git rev-parse --short HEAD) \
+//	  -X delivery-system/internal/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildTime = "unknown"
+)
+
+// Info представляет информацию о сборке приложения
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+}
+
+// Get возвращает информацию о текущей сборке
+func Get() Info {
+	return Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+	}
+}
@@ -0,0 +1,429 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"delivery-system/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Config представляет настройки клиента API системы доставки
+type Config struct {
+	BaseURL    string       // адрес сервиса, например http://localhost:8080
+	HTTPClient *http.Client // HTTP клиент, по умолчанию с таймаутом 10 секунд
+	AuthToken  string       // токен для заголовка Authorization, пусто - без авторизации
+}
+
+// Client представляет типизированный клиент API системы доставки
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	authToken  string
+}
+
+// New создает новый клиент API
+func New(cfg Config) *Client {
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &Client{
+		baseURL:    strings.TrimRight(cfg.BaseURL, "/"),
+		httpClient: httpClient,
+		authToken:  cfg.AuthToken,
+	}
+}
+
+// ListOrdersParams представляет параметры фильтрации списка заказов
+type ListOrdersParams struct {
+	Status    *models.OrderStatus
+	CourierID *uuid.UUID
+	Query     string // поиск по имени клиента, телефону и адресу
+	// SLABreached оставляет только заказы с нарушенным сроком доставки
+	SLABreached bool
+	// Preset именованный набор фильтров (unassigned, today, active); явные параметры имеют приоритет
+	Preset string
+	// Sort порядок сортировки: models.OrderSortCreatedDesc или models.OrderSortCreatedAsc
+	Sort   models.OrderSort
+	Limit  int
+	Offset int
+}
+
+// ListCouriersParams представляет параметры фильтрации списка курьеров
+type ListCouriersParams struct {
+	Status *models.CourierStatus
+	// Skill оставляет только курьеров с указанным навыком
+	Skill  string
+	Limit  int
+	Offset int
+}
+
+// ListCourierDeliveriesParams представляет параметры истории доставок курьера
+type ListCourierDeliveriesParams struct {
+	// From и To ограничивают время доставки; To не включается
+	From   *time.Time
+	To     *time.Time
+	Limit  int
+	Offset int
+}
+
+// CreateOrder создает новый заказ
+func (c *Client) CreateOrder(ctx context.Context, req *models.CreateOrderRequest) (*models.Order, error) {
+	var order models.Order
+	if err := c.do(ctx, http.MethodPost, "/api/orders", req, &order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// GetOrder получает заказ по ID
+func (c *Client) GetOrder(ctx context.Context, orderID uuid.UUID) (*models.Order, error) {
+	var order models.Order
+	if err := c.do(ctx, http.MethodGet, "/api/orders/"+orderID.String(), nil, &order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// GetOrderWithBreakdown получает заказ по ID вместе с расшифровкой стоимости
+func (c *Client) GetOrderWithBreakdown(ctx context.Context, orderID uuid.UUID) (*models.OrderDetails, error) {
+	var details models.OrderDetails
+	if err := c.do(ctx, http.MethodGet, "/api/orders/"+orderID.String()+"?include=breakdown", nil, &details); err != nil {
+		return nil, err
+	}
+	return &details, nil
+}
+
+// ListOrders получает список заказов с фильтрацией
+func (c *Client) ListOrders(ctx context.Context, params ListOrdersParams) ([]*models.Order, error) {
+	query := url.Values{}
+	if params.Status != nil {
+		query.Set("status", string(*params.Status))
+	}
+	if params.CourierID != nil {
+		query.Set("courier_id", params.CourierID.String())
+	}
+	if params.Query != "" {
+		query.Set("q", params.Query)
+	}
+	if params.SLABreached {
+		query.Set("sla", "breached")
+	}
+	if params.Preset != "" {
+		query.Set("preset", params.Preset)
+	}
+	if params.Sort != "" {
+		query.Set("sort", string(params.Sort))
+	}
+	setPagination(query, params.Limit, params.Offset)
+
+	var orders []*models.Order
+	if err := c.do(ctx, http.MethodGet, withQuery("/api/orders", query), nil, &orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// ListUnassignedOrders возвращает созданные заказы без курьера, начиная с самых старых, со временем ожидания
+func (c *Client) ListUnassignedOrders(ctx context.Context, limit, offset int) ([]*models.UnassignedOrder, error) {
+	query := url.Values{}
+	setPagination(query, limit, offset)
+
+	var orders []*models.UnassignedOrder
+	if err := c.do(ctx, http.MethodGet, withQuery("/api/orders/unassigned", query), nil, &orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// GetOrderTimeline получает хронологию заказа
+func (c *Client) GetOrderTimeline(ctx context.Context, orderID uuid.UUID) (*models.OrderTimeline, error) {
+	var timeline models.OrderTimeline
+	if err := c.do(ctx, http.MethodGet, "/api/orders/"+orderID.String()+"/timeline", nil, &timeline); err != nil {
+		return nil, err
+	}
+	return &timeline, nil
+}
+
+// UpdateOrderStatus обновляет статус заказа
+func (c *Client) UpdateOrderStatus(ctx context.Context, orderID uuid.UUID, req *models.UpdateOrderStatusRequest) error {
+	return c.do(ctx, http.MethodPut, "/api/orders/"+orderID.String()+"/status", req, nil)
+}
+
+// BulkUpdateOrderStatus переводит несколько заказов в один статус в одной транзакции.
+// Если хотя бы один заказ не может перейти в статус, ни один заказ не изменяется и возвращается *APIError с ErrConflict
+func (c *Client) BulkUpdateOrderStatus(ctx context.Context, req *models.BulkUpdateOrderStatusRequest) (*models.BulkUpdateOrderStatusResponse, error) {
+	var response models.BulkUpdateOrderStatusResponse
+	if err := c.do(ctx, http.MethodPost, "/api/orders/status/bulk", req, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// MarkOrderReady отмечает заказ готовым к выдаче курьеру
+func (c *Client) MarkOrderReady(ctx context.Context, orderID uuid.UUID) error {
+	return c.do(ctx, http.MethodPost, "/api/orders/"+orderID.String()+"/ready", nil, nil)
+}
+
+// SubmitDeliveryProof прикладывает подтверждение доставки и завершает заказ
+func (c *Client) SubmitDeliveryProof(ctx context.Context, orderID uuid.UUID, req *models.SubmitDeliveryProofRequest) (*models.DeliveryProof, error) {
+	var proof models.DeliveryProof
+	if err := c.do(ctx, http.MethodPost, "/api/orders/"+orderID.String()+"/proof", req, &proof); err != nil {
+		return nil, err
+	}
+	return &proof, nil
+}
+
+// UnassignOrder снимает курьера с заказа
+func (c *Client) UnassignOrder(ctx context.Context, orderID uuid.UUID) error {
+	return c.do(ctx, http.MethodPost, "/api/orders/"+orderID.String()+"/unassign", nil, nil)
+}
+
+// UpdateOrderItem меняет количество или цену позиции заказа и возвращает новую сумму заказа
+func (c *Client) UpdateOrderItem(ctx context.Context, orderID, itemID uuid.UUID, req *models.UpdateOrderItemRequest) (models.Money, error) {
+	var resp struct {
+		TotalAmount models.Money `json:"total_amount"`
+	}
+	path := "/api/orders/" + orderID.String() + "/items/" + itemID.String()
+	if err := c.do(ctx, http.MethodPatch, path, req, &resp); err != nil {
+		return 0, err
+	}
+	return resp.TotalAmount, nil
+}
+
+// RecalculateDeliveryCost пересчитывает стоимость доставки заказа по текущим тарифам
+func (c *Client) RecalculateDeliveryCost(ctx context.Context, orderID uuid.UUID) (models.Money, error) {
+	var resp struct {
+		DeliveryCost models.Money `json:"delivery_cost"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/orders/"+orderID.String()+"/recalculate-price", nil, &resp); err != nil {
+		return 0, err
+	}
+	return resp.DeliveryCost, nil
+}
+
+// CreateCourier создает нового курьера
+func (c *Client) CreateCourier(ctx context.Context, req *models.CreateCourierRequest) (*models.Courier, error) {
+	var courier models.Courier
+	if err := c.do(ctx, http.MethodPost, "/api/couriers", req, &courier); err != nil {
+		return nil, err
+	}
+	return &courier, nil
+}
+
+// GetCourier получает курьера по ID
+func (c *Client) GetCourier(ctx context.Context, courierID uuid.UUID) (*models.Courier, error) {
+	var courier models.Courier
+	if err := c.do(ctx, http.MethodGet, "/api/couriers/"+courierID.String(), nil, &courier); err != nil {
+		return nil, err
+	}
+	return &courier, nil
+}
+
+// ListCouriers получает список курьеров с фильтрацией
+func (c *Client) ListCouriers(ctx context.Context, params ListCouriersParams) ([]*models.Courier, error) {
+	query := url.Values{}
+	if params.Status != nil {
+		query.Set("status", string(*params.Status))
+	}
+	if params.Skill != "" {
+		query.Set("skill", params.Skill)
+	}
+	setPagination(query, params.Limit, params.Offset)
+
+	var couriers []*models.Courier
+	if err := c.do(ctx, http.MethodGet, withQuery("/api/couriers", query), nil, &couriers); err != nil {
+		return nil, err
+	}
+	return couriers, nil
+}
+
+// ListCourierDeliveries получает историю доставок курьера с итогами за период
+func (c *Client) ListCourierDeliveries(ctx context.Context, courierID uuid.UUID, params ListCourierDeliveriesParams) (*models.CourierDeliveries, error) {
+	query := url.Values{}
+	if params.From != nil {
+		query.Set("from", params.From.Format(time.RFC3339))
+	}
+	if params.To != nil {
+		query.Set("to", params.To.Format(time.RFC3339))
+	}
+	setPagination(query, params.Limit, params.Offset)
+
+	var deliveries models.CourierDeliveries
+	if err := c.do(ctx, http.MethodGet, withQuery("/api/couriers/"+courierID.String()+"/deliveries", query), nil, &deliveries); err != nil {
+		return nil, err
+	}
+	return &deliveries, nil
+}
+
+// GetCourierReport получает отчет об эффективности всех курьеров за период [from, to); nil - без ограничения
+func (c *Client) GetCourierReport(ctx context.Context, from, to *time.Time) ([]*models.CourierReportRow, error) {
+	query := url.Values{}
+	if from != nil {
+		query.Set("from", from.Format(time.RFC3339))
+	}
+	if to != nil {
+		query.Set("to", to.Format(time.RFC3339))
+	}
+
+	var report []*models.CourierReportRow
+	if err := c.do(ctx, http.MethodGet, withQuery("/api/couriers/report", query), nil, &report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// GetAvailableCouriers получает список доступных курьеров на смене
+func (c *Client) GetAvailableCouriers(ctx context.Context) ([]*models.Courier, error) {
+	var couriers []*models.Courier
+	if err := c.do(ctx, http.MethodGet, "/api/couriers/available", nil, &couriers); err != nil {
+		return nil, err
+	}
+	return couriers, nil
+}
+
+// UpdateCourierStatus обновляет статус и местоположение курьера
+func (c *Client) UpdateCourierStatus(ctx context.Context, courierID uuid.UUID, req *models.UpdateCourierStatusRequest) error {
+	return c.do(ctx, http.MethodPut, "/api/couriers/"+courierID.String()+"/status", req, nil)
+}
+
+// RecordLocations загружает пакет точек маршрута курьера, упорядоченных по времени
+func (c *Client) RecordLocations(ctx context.Context, courierID uuid.UUID, points []models.LocationPoint) (*models.BatchLocationUpdateResponse, error) {
+	req := models.BatchLocationUpdateRequest{Points: points}
+	var resp models.BatchLocationUpdateResponse
+	if err := c.do(ctx, http.MethodPost, "/api/couriers/"+courierID.String()+"/locations/batch", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// AssignOrder назначает заказ курьеру. force разрешает назначение курьера,
+// находящегося дальше максимального расстояния от точки забора
+func (c *Client) AssignOrder(ctx context.Context, courierID, orderID uuid.UUID, force bool) error {
+	req := map[string]interface{}{"order_id": orderID, "force": force}
+	return c.do(ctx, http.MethodPost, "/api/couriers/"+courierID.String()+"/assign", req, nil)
+}
+
+// AutoAssignOrder назначает заказу ближайшего свободного курьера. Если свободных курьеров нет,
+// заказ ставится в очередь и результат содержит Queued = true
+func (c *Client) AutoAssignOrder(ctx context.Context, orderID uuid.UUID) (*models.AutoAssignResult, error) {
+	var result models.AutoAssignResult
+	if err := c.do(ctx, http.MethodPost, "/api/orders/"+orderID.String()+"/auto-assign", nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// PreviewAutoAssignment возвращает курьера, которого выбрало бы автоматическое назначение заказа,
+// и до alternatives следующих кандидатов, ничего не назначая
+func (c *Client) PreviewAutoAssignment(ctx context.Context, orderID uuid.UUID, alternatives int) (*models.AutoAssignPreview, error) {
+	path := "/api/orders/" + orderID.String() + "/auto-assign/preview?alternatives=" + strconv.Itoa(alternatives)
+	var preview models.AutoAssignPreview
+	if err := c.do(ctx, http.MethodGet, path, nil, &preview); err != nil {
+		return nil, err
+	}
+	return &preview, nil
+}
+
+// StartShift открывает смену курьера
+func (c *Client) StartShift(ctx context.Context, courierID uuid.UUID) (*models.CourierShift, error) {
+	var shift models.CourierShift
+	if err := c.do(ctx, http.MethodPost, "/api/couriers/"+courierID.String()+"/shift/start", nil, &shift); err != nil {
+		return nil, err
+	}
+	return &shift, nil
+}
+
+// EndShift закрывает смену курьера
+func (c *Client) EndShift(ctx context.Context, courierID uuid.UUID) (*models.CourierShift, error) {
+	var shift models.CourierShift
+	if err := c.do(ctx, http.MethodPost, "/api/couriers/"+courierID.String()+"/shift/end", nil, &shift); err != nil {
+		return nil, err
+	}
+	return &shift, nil
+}
+
+// DeactivateCourier отключает курьера, который больше не работает в компании
+func (c *Client) DeactivateCourier(ctx context.Context, courierID uuid.UUID) error {
+	return c.do(ctx, http.MethodPost, "/api/couriers/"+courierID.String()+"/deactivate", nil, nil)
+}
+
+// ReactivateCourier возвращает отключенного курьера в работу
+func (c *Client) ReactivateCourier(ctx context.Context, courierID uuid.UUID) error {
+	return c.do(ctx, http.MethodPost, "/api/couriers/"+courierID.String()+"/reactivate", nil, nil)
+}
+
+// do выполняет запрос к API и декодирует ответ в out, если он передан.
+// Ответы со статусом вне диапазона 2xx преобразуются в *APIError
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.authToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.authToken)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return newAPIError(resp)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
+
+// setPagination добавляет параметры постраничной выборки
+func setPagination(query url.Values, limit, offset int) {
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	if offset > 0 {
+		query.Set("offset", strconv.Itoa(offset))
+	}
+}
+
+// withQuery добавляет строку запроса к пути
+func withQuery(path string, query url.Values) string {
+	if len(query) == 0 {
+		return path
+	}
+	return path + "?" + query.Encode()
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"delivery-system/internal/models"
+)
+
+// Ошибки, с которыми можно сравнивать *APIError через errors.Is
+var (
+	ErrBadRequest   = errors.New("bad request")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrServer       = errors.New("server error")
+)
+
+// FieldError представляет ошибку валидации конкретного поля запроса
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// APIError представляет ответ API с ошибкой
+type APIError struct {
+	StatusCode int              `json:"-"`
+	ErrorText  string           `json:"error"`
+	Code       models.ErrorCode `json:"code"`
+	Message    string           `json:"message"`
+	Errors     []FieldError     `json:"errors,omitempty"`
+}
+
+// Error реализует интерфейс error
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("delivery API: %d %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("delivery API: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Is позволяет сравнивать ошибку API с ErrNotFound, ErrConflict и другими по коду ответа
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServer:
+		return e.StatusCode >= http.StatusInternalServerError
+	}
+	return false
+}
+
+// newAPIError разбирает ответ с ошибкой. Если тело не в формате API, сохраняется только код
+func newAPIError(resp *http.Response) *APIError {
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err == nil && len(data) > 0 {
+		if json.Unmarshal(data, apiErr) != nil {
+			apiErr.Message = string(data)
+		}
+	}
+	apiErr.StatusCode = resp.StatusCode
+
+	return apiErr
+}
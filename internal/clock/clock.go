@@ -0,0 +1,57 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock представляет источник текущего времени. Позволяет подменять время
+// в компонентах, зависящих от него (лимиты, эскалация, тарифы)
+type Clock interface {
+	Now() time.Time
+}
+
+// Real представляет системные часы
+type Real struct{}
+
+// New возвращает системные часы
+func New() Clock {
+	return Real{}
+}
+
+// Now возвращает текущее системное время
+func (Real) Now() time.Time {
+	return time.Now()
+}
+
+// Mock представляет управляемые часы, время которых меняется только вручную
+type Mock struct {
+	mu  sync.RWMutex
+	now time.Time
+}
+
+// NewMock создает управляемые часы, показывающие заданное время
+func NewMock(now time.Time) *Mock {
+	return &Mock{now: now}
+}
+
+// Now возвращает текущее время управляемых часов
+func (m *Mock) Now() time.Time {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.now
+}
+
+// Set устанавливает время управляемых часов
+func (m *Mock) Set(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = now
+}
+
+// Advance сдвигает время управляемых часов вперед на d
+func (m *Mock) Advance(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = m.now.Add(d)
+}
@@ -1,26 +1,78 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"net"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 )
 
 // Config представляет конфигурацию приложения
 type Config struct {
-	Server   ServerConfig   `json:"server"`
-	Database DatabaseConfig `json:"database"`
-	Redis    RedisConfig    `json:"redis"`
-	Kafka    KafkaConfig    `json:"kafka"`
-	Logger   LoggerConfig   `json:"logger"`
+	Server          ServerConfig          `json:"server"`
+	Database        DatabaseConfig        `json:"database"`
+	Redis           RedisConfig           `json:"redis"`
+	Cache           CacheConfig           `json:"cache"`
+	Orders          OrderConfig           `json:"orders"`
+	Kafka           KafkaConfig           `json:"kafka"`
+	Logger          LoggerConfig          `json:"logger"`
+	Metrics         MetricsConfig         `json:"metrics"`
+	Escalation      EscalationConfig      `json:"escalation"`
+	SLA             SLAConfig             `json:"sla"`
+	RateLimit       RateLimitConfig       `json:"rate_limit"`
+	DeliveryPricing DeliveryPricingConfig `json:"delivery_pricing"`
+	Assignment      AssignmentConfig      `json:"assignment"`
+	Webhooks        WebhookConfig         `json:"webhooks"`
+	Admin           AdminConfig           `json:"admin"`
+	Auth            AuthConfig            `json:"auth"`
+	LoadShedding    LoadSheddingConfig    `json:"load_shedding"`
+	Pagination      PaginationConfig      `json:"pagination"`
+	Debug           DebugConfig           `json:"debug"`
 }
 
 // ServerConfig представляет конфигурацию HTTP сервера
 type ServerConfig struct {
-	Port         string `json:"port"`
-	Host         string `json:"host"`
-	ReadTimeout  int    `json:"read_timeout"`
-	WriteTimeout int    `json:"write_timeout"`
+	Port              string `json:"port"`
+	Host              string `json:"host"`
+	ReadTimeout       int    `json:"read_timeout"`
+	WriteTimeout      int    `json:"write_timeout"`
+	IdleTimeout       int    `json:"idle_timeout"`
+	ReadHeaderTimeout int    `json:"read_header_timeout"`
+	// TLSCertPath и TLSKeyPath включают TLS (и HTTP/2), если заданы оба; без них сервер работает по HTTP
+	TLSCertPath string `json:"tls_cert_path"`
+	TLSKeyPath  string `json:"tls_key_path"`
+	// JSONCase стиль ключей JSON в ответах по умолчанию: JSONCaseSnake или JSONCaseCamel
+	JSONCase string `json:"json_case"`
+	// DefaultLanguage язык сообщений об ошибках, если клиент не указал поддерживаемый язык в Accept-Language
+	DefaultLanguage string `json:"default_language"`
+}
+
+// Стили ключей JSON в ответах API
+const (
+	JSONCaseSnake = "snake"
+	JSONCaseCamel = "camel"
+)
+
+// Языки сообщений об ошибках в ответах API
+const (
+	LanguageEnglish = "en"
+	LanguageRussian = "ru"
+)
+
+// TLSEnabled сообщает, настроено ли завершение TLS на сервере
+func (c *ServerConfig) TLSEnabled() bool {
+	return c.TLSCertPath != "" && c.TLSKeyPath != ""
+}
+
+// ValidateTLS проверяет, что сертификат и ключ заданы вместе
+func (c *ServerConfig) ValidateTLS() error {
+	if (c.TLSCertPath == "") != (c.TLSKeyPath == "") {
+		return errors.New("SERVER_TLS_CERT_PATH and SERVER_TLS_KEY_PATH must be set together")
+	}
+	return nil
 }
 
 // DatabaseConfig представляет конфигурацию базы данных
@@ -31,6 +83,16 @@ type DatabaseConfig struct {
 	Password string `json:"password"`
 	DBName   string `json:"db_name"`
 	SSLMode  string `json:"ssl_mode"`
+	// AutoMigrate применяет миграции из MigrationsDir при запуске; до их завершения
+	// /health/readiness отвечает 503
+	AutoMigrate   bool   `json:"auto_migrate"`
+	MigrationsDir string `json:"migrations_dir"`
+	// RetryMaxAttempts сколько раз выполняется операция при временных ошибках PostgreSQL
+	// (сбой сериализации, взаимоблокировка, обрыв соединения), 1 - без повторов
+	RetryMaxAttempts int `json:"retry_max_attempts"`
+	// RetryBaseDelayMs задержка перед первым повтором в миллисекундах, далее удваивается до RetryMaxDelayMs
+	RetryBaseDelayMs int `json:"retry_base_delay_ms"`
+	RetryMaxDelayMs  int `json:"retry_max_delay_ms"`
 }
 
 // RedisConfig представляет конфигурацию Redis
@@ -39,13 +101,55 @@ type RedisConfig struct {
 	Port     string `json:"port"`
 	Password string `json:"password"`
 	DB       int    `json:"db"`
+	// KeyPrefix пространство имен всех ключей, позволяет нескольким окружениям использовать один Redis
+	KeyPrefix string `json:"key_prefix"`
+}
+
+// CacheConfig представляет конфигурацию кеширования
+type CacheConfig struct {
+	DefaultTTL int `json:"default_ttl"` // время жизни записей в секундах
+	StatsTTL   int `json:"stats_ttl"`   // время жизни агрегированной статистики в секундах
+	// OperationTimeoutMs ограничивает время одной операции с кешем в миллисекундах (0 - без ограничения)
+	OperationTimeoutMs int `json:"operation_timeout_ms"`
+	// LocationTTL время жизни последнего местоположения курьера из событий location.updated в секундах
+	LocationTTL int `json:"location_ttl"`
+	// SizeScanLimit максимальное число ключей, просматриваемых при подсчете размера кеша (0 - без ограничения)
+	SizeScanLimit int `json:"size_scan_limit"`
+}
+
+// OrderConfig представляет ограничения на содержимое заказа (0 - без ограничения)
+// и настройки поиска дубликатов
+type OrderConfig struct {
+	MaxItemsPerOrder   int  `json:"max_items_per_order"`
+	MaxQuantityPerItem int  `json:"max_quantity_per_item"`
+	MaxItemPriceCents  int  `json:"max_item_price_cents"`
+	DedupEnabled       bool `json:"dedup_enabled"`
+	DedupWindow        int  `json:"dedup_window"` // окно поиска дубликатов в секундах
+	// MaxDeliveryRadiusKm максимальное расстояние от точки забора до точки доставки в километрах
+	MaxDeliveryRadiusKm float64 `json:"max_delivery_radius_km"`
+	// ClientStatuses статусы, которые клиенты могут устанавливать через PUT /api/orders/{id}/status,
+	// пустой список - любой известный статус
+	ClientStatuses []string `json:"client_statuses"`
+	// CourierHiddenFields и CustomerHiddenFields поля заказа (ключи JSON), которые не возвращаются
+	// клиентам API с ролью courier и customer соответственно
+	CourierHiddenFields  []string `json:"courier_hidden_fields"`
+	CustomerHiddenFields []string `json:"customer_hidden_fields"`
 }
 
 // KafkaConfig представляет конфигурацию Kafka
 type KafkaConfig struct {
-	Brokers []string `json:"brokers"`
-	GroupID string   `json:"group_id"`
-	Topics  Topics   `json:"topics"`
+	Brokers           []string `json:"brokers"`
+	GroupID           string   `json:"group_id"`
+	Topics            Topics   `json:"topics"`
+	DeadLetterTopic   string   `json:"dead_letter_topic"`  // топик для непригодных к обработке сообщений, пусто - не отправлять
+	HandlerRetries    int      `json:"handler_retries"`    // число повторов обработчика при временной ошибке
+	InitialOffset     string   `json:"initial_offset"`     // oldest или newest - откуда читать новой группе потребителей
+	SessionTimeout    int      `json:"session_timeout"`    // таймаут сессии группы потребителей в секундах
+	HeartbeatInterval int      `json:"heartbeat_interval"` // интервал heartbeat в секундах
+	PublishTimeout    int      `json:"publish_timeout"`    // сколько секунд ждать подтверждения публикации, 0 - без ограничения
+	// MaxMessageBytes максимальный размер значения сообщения, которое consumer разбирает; сообщения больше
+	// пропускаются и отправляются в DeadLetterTopic. 0 - без ограничения
+	MaxMessageBytes int `json:"max_message_bytes"`
 }
 
 // Topics представляет список топиков Kafka
@@ -53,6 +157,41 @@ type Topics struct {
 	Orders    string `json:"orders"`
 	Couriers  string `json:"couriers"`
 	Locations string `json:"locations"`
+	// EventTopics переопределяет топик для отдельных типов событий (тип события -> топик)
+	EventTopics map[string]string `json:"event_topics"`
+}
+
+// Resolve возвращает топик для типа события с учетом переопределений
+func (t *Topics) Resolve(eventType, defaultTopic string) string {
+	if topic, ok := t.EventTopics[eventType]; ok && topic != "" {
+		return topic
+	}
+	return defaultTopic
+}
+
+// All возвращает список всех используемых топиков без повторов
+func (t *Topics) All() []string {
+	seen := make(map[string]bool)
+	var topics []string
+	add := func(topic string) {
+		if topic != "" && !seen[topic] {
+			seen[topic] = true
+			topics = append(topics, topic)
+		}
+	}
+
+	add(t.Orders)
+	add(t.Couriers)
+	add(t.Locations)
+
+	// Переопределенные топики добавляются в стабильном порядке
+	base := len(topics)
+	for _, topic := range t.EventTopics {
+		add(topic)
+	}
+	sort.Strings(topics[base:])
+
+	return topics
 }
 
 // LoggerConfig представляет конфигурацию логгера
@@ -60,44 +199,315 @@ type LoggerConfig struct {
 	Level  string `json:"level"`
 	Format string `json:"format"`
 	File   string `json:"file"`
+	// Ротация файла лога: максимальный размер файла, число и возраст архивов (0 - без ограничения)
+	MaxSizeMB  int `json:"max_size_mb"`
+	MaxBackups int `json:"max_backups"`
+	MaxAgeDays int `json:"max_age_days"`
+	// RedactPII заменяет персональные данные (имена, телефоны, адреса, IP) в логах их хешем
+	RedactPII bool `json:"redact_pii"`
+}
+
+// MetricsConfig представляет конфигурацию метрик
+type MetricsConfig struct {
+	OrderStatusRefreshInterval int `json:"order_status_refresh_interval"`
+}
+
+// EscalationConfig представляет конфигурацию эскалации неназначенных заказов
+type EscalationConfig struct {
+	UnassignedTimeout int  `json:"unassigned_timeout"`
+	CheckInterval     int  `json:"check_interval"`
+	BumpPriority      bool `json:"bump_priority"`
+}
+
+// SLAConfig представляет конфигурацию контроля сроков доставки
+type SLAConfig struct {
+	DeliveryTimeout int `json:"delivery_timeout"` // срок доставки с момента создания заказа в секундах
+	CheckInterval   int `json:"check_interval"`   // интервал проверки в секундах
+}
+
+// RateLimitConfig представляет конфигурацию ограничения частоты запросов
+type RateLimitConfig struct {
+	Enabled      bool `json:"enabled"`
+	DefaultRPM   int  `json:"default_rpm"`   // лимит запросов за окно для обычных клиентов
+	VIPRPM       int  `json:"vip_rpm"`       // лимит запросов за окно для VIP клиентов
+	BanDuration  int  `json:"ban_duration"`  // длительность блокировки в секундах
+	BanThreshold int  `json:"ban_threshold"` // число запросов сверх лимита за время блокировки, после которого клиент блокируется
+	// BanEscalationFactor во сколько раз каждая следующая блокировка длиннее предыдущей, 1 - без увеличения
+	BanEscalationFactor float64 `json:"ban_escalation_factor"`
+	// MaxBanDuration максимальная длительность увеличенной блокировки в секундах, 0 - без ограничения
+	MaxBanDuration int `json:"max_ban_duration"`
+	// BanHistoryTTL сколько секунд после блокировки она учитывается при расчете длительности следующей
+	BanHistoryTTL int `json:"ban_history_ttl"`
+	WindowSeconds int `json:"window_seconds"` // длительность окна подсчета в секундах
+	// WarningThreshold доля оставшихся запросов от лимита, ниже которой клиент получает предупреждение
+	WarningThreshold float64 `json:"warning_threshold"`
+	// RetryAfterFormat формат заголовка Retry-After: RetryAfterFormatSeconds или RetryAfterFormatHTTPDate
+	RetryAfterFormat string `json:"retry_after_format"`
+	// MaxConcurrentPerIP максимум одновременно выполняющихся запросов с одного IP адреса, 0 - без ограничения
+	MaxConcurrentPerIP int `json:"max_concurrent_per_ip"`
+	// ConcurrencyTTL через сколько секунд счетчик одновременных запросов сбрасывается, если его не обновляли.
+	// Возвращает места, не освобожденные из-за аварийной остановки экземпляра сервиса
+	ConcurrencyTTL int `json:"concurrency_ttl"`
+	// TrustedProxies подсети (CIDR) или адреса прокси, которым разрешено передавать адрес клиента
+	// в X-Forwarded-For и X-Real-IP. Заголовки остальных клиентов игнорируются
+	TrustedProxies []string `json:"trusted_proxies"`
+}
+
+// ParseTrustedProxies разбирает TrustedProxies; отдельный адрес считается подсетью из одного адреса
+func (c *RateLimitConfig) ParseTrustedProxies() ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(c.TrustedProxies))
+	for _, proxy := range c.TrustedProxies {
+		if ip := net.ParseIP(proxy); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RATE_LIMIT_TRUSTED_PROXIES entry %q: %w", proxy, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// Форматы заголовка Retry-After
+const (
+	RetryAfterFormatSeconds  = "seconds"
+	RetryAfterFormatHTTPDate = "http-date"
+)
+
+// DeliveryPricingConfig представляет тарифы на доставку
+type DeliveryPricingConfig struct {
+	BasePrice  float64 `json:"base_price"`
+	PricePerKm float64 `json:"price_per_km"`
+	MinPrice   float64 `json:"min_price"`
+	MaxPrice   float64 `json:"max_price"`
+	// Надбавка за вес сверх бесплатного порога, за каждый начатый килограмм
+	FreeWeightGrams int     `json:"free_weight_grams"`
+	PricePerKg      float64 `json:"price_per_kg"`
+}
+
+// AssignmentConfig представляет настройки назначения заказов курьерам
+type AssignmentConfig struct {
+	// MaxAssignmentDistanceKm максимальное расстояние от курьера до точки забора заказа, 0 - без ограничения
+	MaxAssignmentDistanceKm float64 `json:"max_assignment_distance_km"`
+	// QueueRetryInterval интервал повторного назначения заказов из очереди в секундах, 0 - только по событиям
+	QueueRetryInterval int `json:"queue_retry_interval"`
+	// QueueBatchSize сколько заказов из очереди обрабатывается за один проход
+	QueueBatchSize int `json:"queue_batch_size"`
+	// MaxQueueAge через сколько секунд ожидания заказ удаляется из очереди автоматического назначения, 0 - без ограничения
+	MaxQueueAge int `json:"max_queue_age"`
+	// AvailabilityGracePeriod через сколько секунд курьер, вернувшийся из busy в available,
+	// становится доступен для назначения, 0 - сразу
+	AvailabilityGracePeriod int `json:"availability_grace_period"`
+}
+
+// LoadSheddingConfig представляет настройки сброса нагрузки при перегрузке зависимостей
+type LoadSheddingConfig struct {
+	// Enabled включает отклонение некритичных запросов, пока пул соединений с БД исчерпан или Redis недоступен
+	Enabled bool `json:"enabled"`
+	// CheckInterval интервал проверки состояния зависимостей в секундах
+	CheckInterval int `json:"check_interval"`
+	// RetryAfter значение заголовка Retry-After в отклоненных ответах в секундах
+	RetryAfter int `json:"retry_after"`
+}
+
+// AdminConfig представляет настройки административного API
+type AdminConfig struct {
+	// Token токен доступа к /api/admin/*, пустой токен отключает административные эндпоинты
+	Token string `json:"token"`
+}
+
+// AuthConfig представляет настройки аутентификации клиентов API
+type AuthConfig struct {
+	// APIKeys ключи API клиентов: ключ -> "<роль>:<ID пользователя>", роль courier или customer
+	APIKeys map[string]string `json:"api_keys"`
+}
+
+// DebugConfig представляет настройки отладочных эндпоинтов
+type DebugConfig struct {
+	// PprofEnabled включает net/http/pprof на отдельном адресе PprofAddr, недоступном через публичный API
+	PprofEnabled bool   `json:"pprof_enabled"`
+	PprofAddr    string `json:"pprof_addr"`
+}
+
+// PaginationConfig представляет настройки постраничной выборки списков
+type PaginationConfig struct {
+	DefaultPageSize int `json:"default_page_size"`
+	// MaxPageSize максимальный limit, большие значения уменьшаются до него
+	MaxPageSize int `json:"max_page_size"`
+}
+
+// WebhookConfig представляет настройки доставки событий подписчикам webhooks
+type WebhookConfig struct {
+	Timeout         int    `json:"timeout"`           // таймаут одного запроса к подписчику в секундах
+	MaxRetries      int    `json:"max_retries"`       // количество повторов при неудачной доставке
+	RetryBackoff    int    `json:"retry_backoff"`     // начальная пауза между повторами в секундах, удваивается с каждой попыткой
+	DeadLetterTopic string `json:"dead_letter_topic"` // топик для событий, которые не удалось доставить
+	// AllowPrivateTargets разрешает адреса подписчиков в loopback, частных и link-local сетях.
+	// Только для локальной разработки: иначе подписка открывает доступ к внутренним сервисам
+	AllowPrivateTargets bool `json:"allow_private_targets"`
+	// Workers количество воркеров, параллельно доставляющих события подписчикам
+	Workers int `json:"workers"`
+	// QueueSize размер очереди доставок; при заполненной очереди события сразу уходят в DeadLetterTopic
+	QueueSize int `json:"queue_size"`
 }
 
 // Load загружает конфигурацию из переменных окружения
 func Load() *Config {
 	return &Config{
 		Server: ServerConfig{
-			Port:         getEnv("SERVER_PORT", "8080"),
-			Host:         getEnv("SERVER_HOST", "0.0.0.0"),
-			ReadTimeout:  getEnvAsInt("SERVER_READ_TIMEOUT", 10),
-			WriteTimeout: getEnvAsInt("SERVER_WRITE_TIMEOUT", 10),
+			Port:              getEnv("SERVER_PORT", "8080"),
+			Host:              getEnv("SERVER_HOST", "0.0.0.0"),
+			ReadTimeout:       getEnvAsInt("SERVER_READ_TIMEOUT", 10),
+			WriteTimeout:      getEnvAsInt("SERVER_WRITE_TIMEOUT", 10),
+			IdleTimeout:       getEnvAsInt("SERVER_IDLE_TIMEOUT", 60),
+			ReadHeaderTimeout: getEnvAsInt("SERVER_READ_HEADER_TIMEOUT", 5),
+			TLSCertPath:       getEnv("SERVER_TLS_CERT_PATH", ""),
+			TLSKeyPath:        getEnv("SERVER_TLS_KEY_PATH", ""),
+			JSONCase:          getEnv("SERVER_JSON_CASE", JSONCaseSnake),
+			DefaultLanguage:   getEnv("SERVER_DEFAULT_LANGUAGE", LanguageEnglish),
 		},
 		Database: DatabaseConfig{
-			Host:     getEnv("DB_HOST", "localhost"),
-			Port:     getEnv("DB_PORT", "5432"),
-			User:     getEnv("DB_USER", "delivery_user"),
-			Password: getEnv("DB_PASSWORD", "delivery_pass"),
-			DBName:   getEnv("DB_NAME", "delivery_system"),
-			SSLMode:  getEnv("DB_SSL_MODE", "disable"),
+			Host:             getEnv("DB_HOST", "localhost"),
+			Port:             getEnv("DB_PORT", "5432"),
+			User:             getEnv("DB_USER", "delivery_user"),
+			Password:         getEnv("DB_PASSWORD", "delivery_pass"),
+			DBName:           getEnv("DB_NAME", "delivery_system"),
+			SSLMode:          getEnv("DB_SSL_MODE", "disable"),
+			AutoMigrate:      getEnvAsBool("DB_AUTO_MIGRATE", false),
+			MigrationsDir:    getEnv("DB_MIGRATIONS_DIR", "migrations"),
+			RetryMaxAttempts: getEnvAsInt("DB_RETRY_MAX_ATTEMPTS", 3),
+			RetryBaseDelayMs: getEnvAsInt("DB_RETRY_BASE_DELAY_MS", 50),
+			RetryMaxDelayMs:  getEnvAsInt("DB_RETRY_MAX_DELAY_MS", 1000),
 		},
 		Redis: RedisConfig{
-			Host:     getEnv("REDIS_HOST", "localhost"),
-			Port:     getEnv("REDIS_PORT", "6379"),
-			Password: getEnv("REDIS_PASSWORD", ""),
-			DB:       getEnvAsInt("REDIS_DB", 0),
+			Host:      getEnv("REDIS_HOST", "localhost"),
+			Port:      getEnv("REDIS_PORT", "6379"),
+			Password:  getEnv("REDIS_PASSWORD", ""),
+			DB:        getEnvAsInt("REDIS_DB", 0),
+			KeyPrefix: getEnv("REDIS_KEY_PREFIX", ""),
+		},
+		Cache: CacheConfig{
+			DefaultTTL:         getEnvAsInt("CACHE_DEFAULT_TTL", 900),
+			StatsTTL:           getEnvAsInt("CACHE_STATS_TTL", 60),
+			OperationTimeoutMs: getEnvAsInt("CACHE_OPERATION_TIMEOUT_MS", 500),
+			LocationTTL:        getEnvAsInt("CACHE_LOCATION_TTL", 120),
+			SizeScanLimit:      getEnvAsInt("CACHE_SIZE_SCAN_LIMIT", 10000),
+		},
+		Orders: OrderConfig{
+			MaxItemsPerOrder:     getEnvAsInt("ORDER_MAX_ITEMS", 50),
+			MaxQuantityPerItem:   getEnvAsInt("ORDER_MAX_QUANTITY_PER_ITEM", 100),
+			MaxItemPriceCents:    getEnvAsInt("ORDER_MAX_ITEM_PRICE_CENTS", 10000000),
+			DedupEnabled:         getEnvAsBool("ORDER_DEDUP_ENABLED", false),
+			DedupWindow:          getEnvAsInt("ORDER_DEDUP_WINDOW", 60),
+			MaxDeliveryRadiusKm:  getEnvAsFloat("ORDER_MAX_DELIVERY_RADIUS_KM", 30),
+			ClientStatuses:       getEnvAsList("ORDER_CLIENT_STATUSES", ""),
+			CourierHiddenFields:  getEnvAsList("ORDER_COURIER_HIDDEN_FIELDS", "cancellation_comment,delivery_cost_overridden"),
+			CustomerHiddenFields: getEnvAsList("ORDER_CUSTOMER_HIDDEN_FIELDS", "priority,cancellation_comment,delivery_cost_overridden,sla_breached_at"),
 		},
 		Kafka: KafkaConfig{
-			Brokers: strings.Split(getEnv("KAFKA_BROKERS", "localhost:9092"), ","),
-			GroupID: getEnv("KAFKA_GROUP_ID", "delivery-service"),
+			Brokers:           strings.Split(getEnv("KAFKA_BROKERS", "localhost:9092"), ","),
+			GroupID:           getEnv("KAFKA_GROUP_ID", "delivery-service"),
+			DeadLetterTopic:   getEnv("KAFKA_DEAD_LETTER_TOPIC", ""),
+			HandlerRetries:    getEnvAsInt("KAFKA_HANDLER_RETRIES", 3),
+			InitialOffset:     getEnv("KAFKA_INITIAL_OFFSET", "oldest"),
+			SessionTimeout:    getEnvAsInt("KAFKA_SESSION_TIMEOUT", 10),
+			HeartbeatInterval: getEnvAsInt("KAFKA_HEARTBEAT_INTERVAL", 3),
+			PublishTimeout:    getEnvAsInt("KAFKA_PUBLISH_TIMEOUT", 2),
+			MaxMessageBytes:   getEnvAsInt("KAFKA_MAX_MESSAGE_BYTES", 1048576),
 			Topics: Topics{
-				Orders:    getEnv("KAFKA_TOPIC_ORDERS", "orders"),
-				Couriers:  getEnv("KAFKA_TOPIC_COURIERS", "couriers"),
-				Locations: getEnv("KAFKA_TOPIC_LOCATIONS", "locations"),
+				Orders:      getEnv("KAFKA_TOPIC_ORDERS", "orders"),
+				Couriers:    getEnv("KAFKA_TOPIC_COURIERS", "couriers"),
+				Locations:   getEnv("KAFKA_TOPIC_LOCATIONS", "locations"),
+				EventTopics: getEnvAsMap("KAFKA_EVENT_TOPICS"),
 			},
 		},
 		Logger: LoggerConfig{
-			Level:  getEnv("LOG_LEVEL", "info"),
-			Format: getEnv("LOG_FORMAT", "json"),
-			File:   getEnv("LOG_FILE", ""),
+			Level:      getEnv("LOG_LEVEL", "info"),
+			Format:     getEnv("LOG_FORMAT", "json"),
+			File:       getEnv("LOG_FILE", ""),
+			MaxSizeMB:  getEnvAsInt("LOG_MAX_SIZE_MB", 100),
+			MaxBackups: getEnvAsInt("LOG_MAX_BACKUPS", 5),
+			MaxAgeDays: getEnvAsInt("LOG_MAX_AGE_DAYS", 30),
+			RedactPII:  getEnvAsBool("LOG_REDACT_PII", false),
+		},
+		Metrics: MetricsConfig{
+			OrderStatusRefreshInterval: getEnvAsInt("METRICS_ORDER_STATUS_REFRESH_INTERVAL", 30),
+		},
+		SLA: SLAConfig{
+			DeliveryTimeout: getEnvAsInt("SLA_DELIVERY_TIMEOUT", 3600),
+			CheckInterval:   getEnvAsInt("SLA_CHECK_INTERVAL", 60),
+		},
+		Escalation: EscalationConfig{
+			UnassignedTimeout: getEnvAsInt("ESCALATION_UNASSIGNED_TIMEOUT", 600),
+			CheckInterval:     getEnvAsInt("ESCALATION_CHECK_INTERVAL", 60),
+			BumpPriority:      getEnvAsBool("ESCALATION_BUMP_PRIORITY", true),
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:             getEnvAsBool("RATE_LIMIT_ENABLED", true),
+			DefaultRPM:          getEnvAsInt("RATE_LIMIT_DEFAULT_RPM", 100),
+			VIPRPM:              getEnvAsInt("RATE_LIMIT_VIP_RPM", 1000),
+			BanDuration:         getEnvAsInt("RATE_LIMIT_BAN_DURATION", 300),
+			BanThreshold:        getEnvAsInt("RATE_LIMIT_BAN_THRESHOLD", 20),
+			BanEscalationFactor: getEnvAsFloat("RATE_LIMIT_BAN_ESCALATION_FACTOR", 2),
+			MaxBanDuration:      getEnvAsInt("RATE_LIMIT_MAX_BAN_DURATION", 3600),
+			BanHistoryTTL:       getEnvAsInt("RATE_LIMIT_BAN_HISTORY_TTL", 86400),
+			WindowSeconds:       getEnvAsInt("RATE_LIMIT_WINDOW_SECONDS", 60),
+			WarningThreshold:    getEnvAsFloat("RATE_LIMIT_WARNING_THRESHOLD", 0.1),
+			RetryAfterFormat:    getEnv("RATE_LIMIT_RETRY_AFTER_FORMAT", RetryAfterFormatSeconds),
+			MaxConcurrentPerIP:  getEnvAsInt("RATE_LIMIT_MAX_CONCURRENT_PER_IP", 20),
+			ConcurrencyTTL:      getEnvAsInt("RATE_LIMIT_CONCURRENCY_TTL", 300),
+			TrustedProxies:      getEnvAsList("RATE_LIMIT_TRUSTED_PROXIES", ""),
+		},
+		DeliveryPricing: DeliveryPricingConfig{
+			BasePrice:       getEnvAsFloat("DELIVERY_BASE_PRICE", 100),
+			PricePerKm:      getEnvAsFloat("DELIVERY_PRICE_PER_KM", 30),
+			MinPrice:        getEnvAsFloat("DELIVERY_MIN_PRICE", 150),
+			MaxPrice:        getEnvAsFloat("DELIVERY_MAX_PRICE", 1500),
+			FreeWeightGrams: getEnvAsInt("DELIVERY_FREE_WEIGHT_GRAMS", 5000),
+			PricePerKg:      getEnvAsFloat("DELIVERY_PRICE_PER_KG", 20),
+		},
+		Assignment: AssignmentConfig{
+			MaxAssignmentDistanceKm: getEnvAsFloat("ASSIGNMENT_MAX_DISTANCE_KM", 10),
+			QueueRetryInterval:      getEnvAsInt("ASSIGNMENT_QUEUE_RETRY_INTERVAL", 30),
+			QueueBatchSize:          getEnvAsInt("ASSIGNMENT_QUEUE_BATCH_SIZE", 50),
+			MaxQueueAge:             getEnvAsInt("ASSIGNMENT_MAX_QUEUE_AGE", 3600),
+			AvailabilityGracePeriod: getEnvAsInt("ASSIGNMENT_AVAILABILITY_GRACE_PERIOD", 30),
+		},
+		Webhooks: WebhookConfig{
+			Timeout:             getEnvAsInt("WEBHOOK_TIMEOUT", 5),
+			MaxRetries:          getEnvAsInt("WEBHOOK_MAX_RETRIES", 3),
+			RetryBackoff:        getEnvAsInt("WEBHOOK_RETRY_BACKOFF", 1),
+			DeadLetterTopic:     getEnv("WEBHOOK_DEAD_LETTER_TOPIC", "webhooks.dead_letter"),
+			AllowPrivateTargets: getEnvAsBool("WEBHOOK_ALLOW_PRIVATE_TARGETS", false),
+			Workers:             getEnvAsInt("WEBHOOK_WORKERS", 4),
+			QueueSize:           getEnvAsInt("WEBHOOK_QUEUE_SIZE", 1000),
+		},
+		Admin: AdminConfig{
+			Token: getEnv("ADMIN_TOKEN", ""),
+		},
+		Auth: AuthConfig{
+			APIKeys: getEnvAsMap("AUTH_API_KEYS"),
+		},
+
+		LoadShedding: LoadSheddingConfig{
+			Enabled:       getEnvAsBool("LOAD_SHEDDING_ENABLED", true),
+			CheckInterval: getEnvAsInt("LOAD_SHEDDING_CHECK_INTERVAL", 1),
+			RetryAfter:    getEnvAsInt("LOAD_SHEDDING_RETRY_AFTER", 5),
+		},
+		Debug: DebugConfig{
+			PprofEnabled: getEnvAsBool("ENABLE_PPROF", false),
+			PprofAddr:    getEnv("PPROF_ADDR", "127.0.0.1:6060"),
+		},
+		Pagination: PaginationConfig{
+			DefaultPageSize: getEnvAsInt("PAGINATION_DEFAULT_PAGE_SIZE", 50),
+			MaxPageSize:     getEnvAsInt("PAGINATION_MAX_PAGE_SIZE", 100),
 		},
 	}
 }
@@ -118,3 +528,48 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+// getEnvAsBool получает значение переменной окружения как bool с значением по умолчанию
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseBool(valueStr); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvAsFloat получает значение переменной окружения как float64 с значением по умолчанию
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseFloat(valueStr, 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvAsList получает значение переменной окружения в формате value1,value2 или значение по умолчанию, пропуская пустые значения
+func getEnvAsList(key, defaultValue string) []string {
+	var values []string
+	for _, value := range strings.Split(getEnv(key, defaultValue), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getEnvAsMap получает значение переменной окружения в формате key1=value1,key2=value2
+func getEnvAsMap(key string) map[string]string {
+	result := make(map[string]string)
+	for _, pair := range strings.Split(getEnv(key, ""), ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if k != "" && v != "" {
+			result[k] = v
+		}
+	}
+	return result
+}
@@ -14,6 +14,8 @@ import (
 // DB представляет подключение к базе данных
 type DB struct {
 	*sql.DB
+	retry retryPolicy
+	log   *logger.Logger
 }
 
 // Connect создает подключение к базе данных
@@ -38,7 +40,7 @@ func Connect(cfg *config.DatabaseConfig, log *logger.Logger) (*DB, error) {
 
 	log.Info("Successfully connected to database")
 
-	return &DB{DB: db}, nil
+	return &DB{DB: db, retry: newRetryPolicy(cfg), log: log}, nil
 }
 
 // Close закрывает подключение к базе данных
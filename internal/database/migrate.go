@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"delivery-system/internal/logger"
+)
+
+// migrationLockID ключ advisory-блокировки, под которой применяются миграции.
+// Не дает нескольким экземплярам сервиса одновременно менять схему при раскатке
+const migrationLockID = 72439105
+
+// upSuffix суффикс файлов миграций, применяемых при запуске
+const upSuffix = ".up.sql"
+
+// Migrate применяет еще не примененные миграции *.up.sql из каталога dir в порядке имен файлов.
+// Примененные версии хранятся в таблице schema_migrations; каждая миграция выполняется в отдельной транзакции
+func (db *DB) Migrate(ctx context.Context, dir string, log *logger.Logger) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+upSuffix))
+	if err != nil {
+		return fmt.Errorf("failed to list migrations: %w", err)
+	}
+	sort.Strings(files)
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID)
+
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version VARCHAR(255) PRIMARY KEY,
+			applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	applied := 0
+	for _, file := range files {
+		version := strings.TrimSuffix(filepath.Base(file), upSuffix)
+
+		var exists bool
+		err := conn.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = $1)", version).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("failed to check migration %s: %w", version, err)
+		}
+		if exists {
+			continue
+		}
+
+		if err := applyMigration(ctx, conn, file, version); err != nil {
+			return err
+		}
+
+		log.WithField("version", version).Info("Migration applied")
+		applied++
+	}
+
+	log.WithField("applied", applied).Info("Database migrations completed")
+	return nil
+}
+
+// applyMigration выполняет файл миграции и отмечает версию примененной в одной транзакции
+func applyMigration(ctx context.Context, conn *sql.Conn, file, version string) error {
+	script, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read migration %s: %w", version, err)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, string(script)); err != nil {
+		return fmt.Errorf("failed to apply migration %s: %w", version, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version) VALUES ($1)", version); err != nil {
+		return fmt.Errorf("failed to record migration %s: %w", version, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %s: %w", version, err)
+	}
+
+	return nil
+}
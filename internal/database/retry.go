@@ -0,0 +1,161 @@
+package database
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"syscall"
+	"time"
+
+	"delivery-system/internal/config"
+
+	"github.com/lib/pq"
+)
+
+// Коды ошибок PostgreSQL, после которых операцию можно безопасно повторить
+const (
+	pqSerializationFailure = "40001"
+	pqDeadlockDetected     = "40P01"
+	pqAdminShutdown        = "57P01"
+	pqCannotConnectNow     = "57P03"
+	// pqConnectionException класс ошибок соединения (08000, 08003, 08006, ...)
+	pqConnectionException = "08"
+)
+
+// retryPolicy задает число попыток и ограниченную экспоненциальную задержку между ними
+type retryPolicy struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+}
+
+// newRetryPolicy создает политику повторов из конфигурации базы данных
+func newRetryPolicy(cfg *config.DatabaseConfig) retryPolicy {
+	policy := retryPolicy{
+		maxAttempts: max(cfg.RetryMaxAttempts, 1),
+		baseDelay:   time.Duration(max(cfg.RetryBaseDelayMs, 0)) * time.Millisecond,
+		maxDelay:    time.Duration(max(cfg.RetryMaxDelayMs, 0)) * time.Millisecond,
+	}
+	if policy.maxDelay < policy.baseDelay {
+		policy.maxDelay = policy.baseDelay
+	}
+	return policy
+}
+
+// delay возвращает задержку перед повтором с номером attempt (начиная с 1): baseDelay * 2^(attempt-1),
+// но не больше maxDelay, со случайным разбросом до половины значения, чтобы повторы не совпадали
+func (p retryPolicy) delay(attempt int) time.Duration {
+	d := p.baseDelay
+	for i := 1; i < attempt && d < p.maxDelay; i++ {
+		d *= 2
+	}
+	d = min(d, p.maxDelay)
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// Retry выполняет идемпотентную операцию op и повторяет ее, пока она завершается временной ошибкой
+// (см. IsRetryable) и не исчерпан лимит попыток DB_RETRY_MAX_ATTEMPTS. Возвращает ошибку последней попытки.
+// Операции с транзакцией должны целиком выполняться внутри op и завершаться через Commit
+func (db *DB) Retry(op func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = op(); err == nil || !IsRetryable(err) || attempt >= db.retry.maxAttempts {
+			return err
+		}
+
+		delay := db.retry.delay(attempt)
+		if db.log != nil {
+			db.log.WithError(err).WithFields(map[string]interface{}{
+				"attempt": attempt,
+				"delay":   delay.String(),
+			}).Warn("Retrying database operation after transient error")
+		}
+		time.Sleep(delay)
+	}
+}
+
+// QueryWithRetry выполняет запрос на чтение через Retry
+func (db *DB) QueryWithRetry(query string, args ...interface{}) (*sql.Rows, error) {
+	var rows *sql.Rows
+	err := db.Retry(func() (err error) {
+		rows, err = db.Query(query, args...)
+		return err
+	})
+	return rows, err
+}
+
+// QueryRowWithRetry подготавливает запрос на чтение одной строки. Запрос выполняется при вызове Scan
+// и повторяется вместе со считыванием через Retry
+func (db *DB) QueryRowWithRetry(query string, args ...interface{}) *RetryRow {
+	return &RetryRow{db: db, query: query, args: args}
+}
+
+// RetryRow представляет строку, возвращаемую QueryRowWithRetry
+type RetryRow struct {
+	db    *DB
+	query string
+	args  []interface{}
+}
+
+// Scan выполняет запрос и считывает строку в dest, как (*sql.Row).Scan
+func (r *RetryRow) Scan(dest ...interface{}) error {
+	return r.db.Retry(func() error {
+		return r.db.QueryRow(r.query, r.args...).Scan(dest...)
+	})
+}
+
+// commitError ошибка фиксации транзакции. Если соединение оборвалось во время COMMIT, неизвестно,
+// применилась ли транзакция, поэтому такую ошибку повторять нельзя
+type commitError struct {
+	err error
+}
+
+func (e *commitError) Error() string { return e.err.Error() }
+
+func (e *commitError) Unwrap() error { return e.err }
+
+// Commit фиксирует транзакцию. Ошибку фиксации можно повторить через Retry, только если PostgreSQL
+// гарантированно откатил транзакцию (сбой сериализации или взаимоблокировка)
+func Commit(tx *sql.Tx) error {
+	if err := tx.Commit(); err != nil {
+		return &commitError{err: err}
+	}
+	return nil
+}
+
+// IsRetryable сообщает, что ошибка временная и операцию можно повторить: сбой сериализации,
+// взаимоблокировка, перезапуск или недоступность сервера, обрыв соединения
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var pqErr *pq.Error
+	isPQErr := errors.As(err, &pqErr)
+	if isPQErr && (pqErr.Code == pqSerializationFailure || pqErr.Code == pqDeadlockDetected) {
+		return true
+	}
+
+	var commitErr *commitError
+	if errors.As(err, &commitErr) {
+		return false
+	}
+
+	if isPQErr {
+		return pqErr.Code == pqAdminShutdown || pqErr.Code == pqCannotConnectNow ||
+			pqErr.Code.Class() == pqConnectionException
+	}
+
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.As(err, &netErr)
+}
@@ -0,0 +1,52 @@
+package geo
+
+import "math"
+
+// EarthRadiusKm средний радиус Земли в километрах
+const EarthRadiusKm = 6371.0
+
+// kmPerDegreeLat количество километров в одном градусе широты
+const kmPerDegreeLat = 111.0
+
+// DistanceKm вычисляет расстояние между двумя точками по формуле гаверсинусов
+func DistanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	dLat := toRadians(lat2 - lat1)
+	dLon := toRadians(lon2 - lon1)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * EarthRadiusKm * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// BoundingBox возвращает прямоугольник, гарантированно содержащий круг заданного радиуса.
+// Используется для предварительной фильтрации в SQL перед точным расчетом расстояния
+func BoundingBox(lat, lon, radiusKm float64) (minLat, maxLat, minLon, maxLon float64) {
+	dLat := radiusKm / kmPerDegreeLat
+	minLat = math.Max(lat-dLat, -90)
+	maxLat = math.Min(lat+dLat, 90)
+
+	cosLat := math.Cos(toRadians(lat))
+	if cosLat < 0.01 || minLat == -90 || maxLat == 90 {
+		// Вблизи полюсов долгота не ограничивает область поиска
+		return minLat, maxLat, -180, 180
+	}
+
+	dLon := radiusKm / (kmPerDegreeLat * cosLat)
+	return minLat, maxLat, math.Max(lon-dLon, -180), math.Min(lon+dLon, 180)
+}
+
+// ValidLat проверяет, что широта лежит в диапазоне [-90, 90]
+func ValidLat(lat float64) bool {
+	return lat >= -90 && lat <= 90
+}
+
+// ValidLon проверяет, что долгота лежит в диапазоне [-180, 180]
+func ValidLon(lon float64) bool {
+	return lon >= -180 && lon <= 180
+}
+
+// toRadians переводит градусы в радианы
+func toRadians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+
+	"delivery-system/internal/logger"
+	"delivery-system/internal/services"
+)
+
+// AssignmentHandler представляет обработчик автоматического назначения курьеров
+type AssignmentHandler struct {
+	autoAssignService *services.AutoAssignService
+	log               *logger.Logger
+}
+
+// NewAssignmentHandler создает новый обработчик автоматического назначения
+func NewAssignmentHandler(autoAssignService *services.AutoAssignService, log *logger.Logger) *AssignmentHandler {
+	return &AssignmentHandler{
+		autoAssignService: autoAssignService,
+		log:               log,
+	}
+}
+
+// AutoAssign назначает заказу ближайшего свободного курьера. Если свободных курьеров нет,
+// заказ ставится в очередь и ответ возвращается со статусом 202 Accepted
+func (h *AssignmentHandler) AutoAssign(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	orderID, err := extractUUIDFromPath(r.URL.Path, "/api/orders/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	result, err := h.autoAssignService.AutoAssign(r.Context(), orderID)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to auto-assign order")
+		return
+	}
+
+	if result.Queued {
+		writeJSONResponse(w, http.StatusAccepted, result)
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, result)
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// contextKey представляет тип ключей контекста запроса
+type contextKey string
+
+const (
+	userIDContextKey contextKey = "user_id"
+	roleContextKey   contextKey = "role"
+)
+
+// Role представляет роль аутентифицированного клиента API, от которой зависит набор видимых полей
+type Role string
+
+const (
+	RoleCourier  Role = "courier"
+	RoleCustomer Role = "customer"
+)
+
+// ContextWithUserID сохраняет ID аутентифицированного пользователя в контексте запроса.
+// Вызывается из middleware аутентификации APIKeyAuth
+func ContextWithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDContextKey, userID)
+}
+
+// UserIDFromContext возвращает ID аутентифицированного пользователя, если он есть
+func UserIDFromContext(ctx context.Context) (string, bool) {
+	userID, ok := ctx.Value(userIDContextKey).(string)
+	return userID, ok && userID != ""
+}
+
+// ContextWithRole сохраняет роль аутентифицированного клиента в контексте запроса.
+// Вызывается из middleware аутентификации APIKeyAuth; запросы без роли видят все поля
+func ContextWithRole(ctx context.Context, role Role) context.Context {
+	return context.WithValue(ctx, roleContextKey, role)
+}
+
+// RoleFromContext возвращает роль аутентифицированного клиента, если она есть
+func RoleFromContext(ctx context.Context) (Role, bool) {
+	role, ok := ctx.Value(roleContextKey).(Role)
+	return role, ok && role != ""
+}
+
+// AdminAuth возвращает middleware, пропускающий только запросы с токеном администратора
+// в заголовке "Authorization: Bearer <token>". Пустой токен отключает административные эндпоинты
+func AdminAuth(token string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				writeErrorResponse(w, http.StatusForbidden, "Admin API is disabled")
+				return
+			}
+
+			provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				writeErrorResponse(w, http.StatusUnauthorized, "Invalid admin token")
+				return
+			}
+
+			next(w, r)
+		}
+	}
+}
+
+// apiKeyPrincipal клиент API, которому выдан ключ
+type apiKeyPrincipal struct {
+	role   Role
+	userID string
+}
+
+// APIKeyAuth возвращает middleware, который по ключу из заголовка "X-API-Key" сохраняет в контексте
+// запроса роль и ID клиента. apiKeys сопоставляет ключу значение "<роль>:<ID пользователя>"; для курьера
+// ID должен быть ID курьера. Запросы без заголовка выполняются без роли, с неизвестным ключом - отклоняются
+func APIKeyAuth(apiKeys map[string]string) (func(http.HandlerFunc) http.HandlerFunc, error) {
+	// Ключи хранятся по хешу, чтобы поиск не зависел от содержимого ключа
+	principals := make(map[[sha256.Size]byte]apiKeyPrincipal, len(apiKeys))
+	for key, value := range apiKeys {
+		role, userID, _ := strings.Cut(value, ":")
+		switch Role(role) {
+		case RoleCourier:
+			if _, err := uuid.Parse(userID); err != nil {
+				return nil, fmt.Errorf("API key for role %s must specify a courier ID", role)
+			}
+		case RoleCustomer:
+			if userID == "" {
+				return nil, fmt.Errorf("API key for role %s must specify a user ID", role)
+			}
+		default:
+			return nil, fmt.Errorf("unknown API key role %q", role)
+		}
+		principals[sha256.Sum256([]byte(key))] = apiKeyPrincipal{role: Role(role), userID: userID}
+	}
+
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get("X-API-Key")
+			if key == "" {
+				next(w, r)
+				return
+			}
+
+			principal, ok := principals[sha256.Sum256([]byte(key))]
+			if !ok {
+				writeErrorResponse(w, http.StatusUnauthorized, "Invalid API key")
+				return
+			}
+
+			ctx := ContextWithRole(ContextWithUserID(r.Context(), principal.userID), principal.role)
+			next(w, r.WithContext(ctx))
+		}
+	}, nil
+}
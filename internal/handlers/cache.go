@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+
+	"delivery-system/internal/logger"
+	"delivery-system/internal/services"
+)
+
+// CacheHandler представляет обработчик информации о кеше
+type CacheHandler struct {
+	cacheService *services.CacheService
+	log          *logger.Logger
+}
+
+// NewCacheHandler создает новый обработчик информации о кеше
+func NewCacheHandler(cacheService *services.CacheService, log *logger.Logger) *CacheHandler {
+	return &CacheHandler{
+		cacheService: cacheService,
+		log:          log,
+	}
+}
+
+// cacheMetricsResponse представляет статистику кеша вместе с количеством ключей по префиксам
+type cacheMetricsResponse struct {
+	services.CacheMetrics
+	Size *services.CacheSize `json:"size,omitempty"`
+}
+
+// GetMetrics возвращает статистику использования кеша
+func (h *CacheHandler) GetMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	response := cacheMetricsResponse{CacheMetrics: h.cacheService.Metrics()}
+
+	size, err := h.cacheService.Size(r.Context())
+	if err != nil {
+		// Размер кеша необязателен: счетчики возвращаются и при недоступном Redis
+		h.log.WithError(err).Warn("Failed to count cache keys")
+	} else {
+		response.Size = size
+	}
+
+	writeJSONResponse(w, http.StatusOK, response)
+}
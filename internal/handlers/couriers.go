@@ -1,12 +1,18 @@
 package handlers
 
 import (
+	"context"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
-	"strings"
+	"time"
 
+	"delivery-system/internal/config"
+	"delivery-system/internal/geo"
 	"delivery-system/internal/kafka"
 	"delivery-system/internal/logger"
 	"delivery-system/internal/models"
@@ -16,20 +22,39 @@ import (
 	"github.com/google/uuid"
 )
 
+// Ограничения пакетной загрузки местоположений
+const (
+	maxLocationBatchSize = 500
+	// maxLocationClockSkew допустимое опережение часов устройства курьера
+	maxLocationClockSkew = time.Minute
+)
+
+// Количество альтернативных кандидатов в предпросмотре автоматического назначения
+const (
+	defaultPreviewAlternatives = 4
+	maxPreviewAlternatives     = 20
+)
+
 // CourierHandler представляет обработчик курьеров
 type CourierHandler struct {
 	courierService *services.CourierService
 	producer       *kafka.Producer
-	redisClient    *redis.Client
+	cacheService   *services.CacheService
+	locationCache  *services.CourierLocationCache
+	cacheCfg       *config.CacheConfig
+	pageCfg        *config.PaginationConfig
 	log            *logger.Logger
 }
 
 // NewCourierHandler создает новый обработчик курьеров
-func NewCourierHandler(courierService *services.CourierService, producer *kafka.Producer, redisClient *redis.Client, log *logger.Logger) *CourierHandler {
+func NewCourierHandler(courierService *services.CourierService, producer *kafka.Producer, cacheService *services.CacheService, locationCache *services.CourierLocationCache, cacheCfg *config.CacheConfig, pageCfg *config.PaginationConfig, log *logger.Logger) *CourierHandler {
 	return &CourierHandler{
 		courierService: courierService,
 		producer:       producer,
-		redisClient:    redisClient,
+		cacheService:   cacheService,
+		locationCache:  locationCache,
+		cacheCfg:       cacheCfg,
+		pageCfg:        pageCfg,
 		log:            log,
 	}
 }
@@ -43,7 +68,7 @@ func (h *CourierHandler) CreateCourier(w http.ResponseWriter, r *http.Request) {
 
 	var req models.CreateCourierRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		writeDecodeErrorResponse(w, err)
 		return
 	}
 
@@ -63,11 +88,12 @@ func (h *CourierHandler) CreateCourier(w http.ResponseWriter, r *http.Request) {
 
 	// Кеширование курьера в Redis
 	cacheKey := redis.GenerateKey(redis.KeyPrefixCourier, courier.ID.String())
-	if err := h.redisClient.Set(r.Context(), cacheKey, courier, defaultCacheTTL); err != nil {
+	if err := h.cacheService.Set(r.Context(), cacheKey, courier); err != nil {
 		h.log.WithError(err).Error("Failed to cache courier")
 	}
 
 	h.log.WithField("courier_id", courier.ID).Info("Courier created successfully")
+	w.Header().Set("Location", "/api/couriers/"+courier.ID.String())
 	writeJSONResponse(w, http.StatusCreated, courier)
 }
 
@@ -86,36 +112,64 @@ func (h *CourierHandler) GetCourier(w http.ResponseWriter, r *http.Request) {
 
 	// Попытка получить из кеша
 	cacheKey := redis.GenerateKey(redis.KeyPrefixCourier, courierID.String())
-	var courier models.Courier
-	if err := h.redisClient.Get(r.Context(), cacheKey, &courier); err == nil {
+	courier := &models.Courier{}
+	if err := h.cacheService.Get(r.Context(), cacheKey, courier); err == nil {
 		h.log.WithField("courier_id", courierID).Debug("Courier retrieved from cache")
-		writeJSONResponse(w, http.StatusOK, &courier)
+	} else {
+		// Получение из базы данных
+		courier, err = h.courierService.GetCourier(courierID)
+		if err != nil {
+			writeServiceError(w, h.log, err, "Failed to get courier")
+			return
+		}
+
+		// Кеширование курьера
+		if err := h.cacheService.Set(r.Context(), cacheKey, courier); err != nil {
+			h.log.WithError(err).Error("Failed to cache courier")
+		}
+	}
+
+	// Местоположение из событий location.updated может быть свежее сохраненного в базе данных
+	h.locationCache.Apply(r.Context(), courier)
+
+	if r.URL.Query().Get("include") != "stats" {
+		writeJSONResponse(w, http.StatusOK, courier)
 		return
 	}
 
-	// Получение из базы данных
-	courierPtr, err := h.courierService.GetCourier(courierID)
+	stats, err := h.getCourierStats(r.Context(), courierID)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			writeErrorResponse(w, http.StatusNotFound, "Courier not found")
-		} else {
-			h.log.WithError(err).Error("Failed to get courier")
-			writeErrorResponse(w, http.StatusInternalServerError, "Failed to get courier")
-		}
+		h.log.WithError(err).Error("Failed to get courier stats")
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to get courier stats")
 		return
 	}
 
-	// Кеширование курьера
-	if err := h.redisClient.Set(r.Context(), cacheKey, courierPtr, defaultCacheTTL); err != nil {
-		h.log.WithError(err).Error("Failed to cache courier")
+	writeJSONResponse(w, http.StatusOK, &models.CourierDetails{Courier: *courier, Stats: stats})
+}
+
+// getCourierStats возвращает статистику курьера, используя короткоживущий кеш
+func (h *CourierHandler) getCourierStats(ctx context.Context, courierID uuid.UUID) (*models.CourierStats, error) {
+	cacheKey := redis.GenerateKey(redis.KeyPrefixCourierStats, courierID.String())
+	stats := &models.CourierStats{}
+	if err := h.cacheService.Get(ctx, cacheKey, stats); err == nil {
+		return stats, nil
+	}
+
+	stats, err := h.courierService.GetCourierStats(courierID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.cacheService.SetWithTTL(ctx, cacheKey, stats, time.Duration(h.cacheCfg.StatsTTL)*time.Second); err != nil {
+		h.log.WithError(err).Error("Failed to cache courier stats")
 	}
 
-	writeJSONResponse(w, http.StatusOK, courierPtr)
+	return stats, nil
 }
 
-// UpdateCourierStatus обновляет статус курьера
+// UpdateCourierStatus обновляет статус курьера (PUT или PATCH) и, если переданы координаты, его местоположение
 func (h *CourierHandler) UpdateCourierStatus(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPut {
+	if r.Method != http.MethodPut && r.Method != http.MethodPatch {
 		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
@@ -128,18 +182,19 @@ func (h *CourierHandler) UpdateCourierStatus(w http.ResponseWriter, r *http.Requ
 
 	var req models.UpdateCourierStatusRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		writeDecodeErrorResponse(w, err)
+		return
+	}
+
+	if err := validateUpdateCourierStatusRequest(&req); err != nil {
+		writeValidationErrorResponse(w, r, h.log, err)
 		return
 	}
 
 	// Получение текущего курьера для определения старого статуса
 	currentCourier, err := h.courierService.GetCourier(courierID)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			writeErrorResponse(w, http.StatusNotFound, "Courier not found")
-		} else {
-			writeErrorResponse(w, http.StatusInternalServerError, "Failed to get courier")
-		}
+		writeServiceError(w, h.log, err, "Failed to get courier")
 		return
 	}
 
@@ -147,30 +202,25 @@ func (h *CourierHandler) UpdateCourierStatus(w http.ResponseWriter, r *http.Requ
 
 	// Обновление статуса
 	if err := h.courierService.UpdateCourierStatus(courierID, &req); err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			writeErrorResponse(w, http.StatusNotFound, "Courier not found")
-		} else {
-			h.log.WithError(err).Error("Failed to update courier status")
-			writeErrorResponse(w, http.StatusInternalServerError, "Failed to update courier status")
-		}
+		writeServiceError(w, h.log, err, "Failed to update courier status")
 		return
 	}
 
 	// Публикация события изменения статуса курьера
-	if err := h.producer.PublishCourierStatusChanged(courierID, oldStatus, req.Status); err != nil {
+	if err := h.producer.PublishCourierStatusChanged(r.Context(), courierID, oldStatus, req.Status); err != nil {
 		h.log.WithError(err).Error("Failed to publish courier status changed event")
 	}
 
 	// Публикация события обновления местоположения (если предоставлены координаты)
 	if req.CurrentLat != nil && req.CurrentLon != nil {
-		if err := h.producer.PublishLocationUpdated(courierID, *req.CurrentLat, *req.CurrentLon); err != nil {
+		if err := h.producer.PublishLocationUpdated(r.Context(), courierID, *req.CurrentLat, *req.CurrentLon); err != nil {
 			h.log.WithError(err).Error("Failed to publish location updated event")
 		}
 	}
 
 	// Инвалидация кеша
 	cacheKey := redis.GenerateKey(redis.KeyPrefixCourier, courierID.String())
-	if err := h.redisClient.Delete(r.Context(), cacheKey); err != nil {
+	if err := h.cacheService.Delete(r.Context(), cacheKey); err != nil {
 		h.log.WithError(err).Error("Failed to invalidate courier cache")
 	}
 
@@ -194,28 +244,106 @@ func (h *CourierHandler) GetCouriers(w http.ResponseWriter, r *http.Request) {
 		status = &s
 	}
 
-	limit := 50 // По умолчанию
-	if limitStr := query.Get("limit"); limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
-			limit = l
+	skill := query.Get("skill")
+	if skill != "" {
+		if err := models.ValidateSkills([]string{skill}); err != nil {
+			writeErrorResponsef(w, http.StatusBadRequest, "Invalid skill: %s", skill)
+			return
 		}
 	}
 
-	offset := 0
-	if offsetStr := query.Get("offset"); offsetStr != "" {
-		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
-			offset = o
+	limit, offset := parsePagination(query, h.pageCfg)
+
+	// Поиск курьеров в радиусе от точки
+	if query.Has("lat") || query.Has("lon") || query.Has("radius") {
+		lat, lon, radius, err := parseProximityQuery(query)
+		if err != nil {
+			writeErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		couriers, err := h.courierService.GetCouriersNearby(status, skill, lat, lon, radius, limit, offset)
+		if err != nil {
+			h.log.WithError(err).Error("Failed to get couriers nearby")
+			writeErrorResponse(w, http.StatusInternalServerError, "Failed to get couriers")
+			return
 		}
+		couriers = h.applyCachedLocations(r.Context(), couriers, lat, lon, radius)
+
+		entries := make([]listEntry, len(couriers))
+		for i, courier := range couriers {
+			entries[i] = courierListEntry(&courier.Courier)
+		}
+
+		setPaginationHeaders(w, limit, offset)
+		writeConditionalList(w, r, couriers, entries)
+		return
 	}
 
-	couriers, err := h.courierService.GetCouriers(status, limit, offset)
+	couriers, err := h.courierService.GetCouriers(status, skill, limit, offset)
 	if err != nil {
 		h.log.WithError(err).Error("Failed to get couriers")
 		writeErrorResponse(w, http.StatusInternalServerError, "Failed to get couriers")
 		return
 	}
 
-	writeJSONResponse(w, http.StatusOK, couriers)
+	entries := make([]listEntry, len(couriers))
+	for i, courier := range couriers {
+		entries[i] = courierListEntry(courier)
+	}
+
+	setPaginationHeaders(w, limit, offset)
+	writeConditionalList(w, r, couriers, entries)
+}
+
+// courierListEntry возвращает элемент списка курьеров для условных заголовков.
+// Обновление местоположения меняет last_seen_at, поэтому учитывается более позднее из двух времен
+func courierListEntry(courier *models.Courier) listEntry {
+	entry := listEntry{id: courier.ID, updatedAt: courier.UpdatedAt}
+	if courier.LastSeenAt != nil && courier.LastSeenAt.After(entry.updatedAt) {
+		entry.updatedAt = *courier.LastSeenAt
+	}
+	return entry
+}
+
+// applyCachedLocations подставляет свежие местоположения из кеша в результаты поиска рядом с точкой,
+// пересчитывает расстояние и исключает курьеров, уехавших за пределы радиуса
+func (h *CourierHandler) applyCachedLocations(ctx context.Context, couriers []*models.CourierWithDistance, lat, lon, radius float64) []*models.CourierWithDistance {
+	result := couriers[:0]
+	for _, courier := range couriers {
+		if h.locationCache.Apply(ctx, &courier.Courier) {
+			courier.DistanceKm = geo.DistanceKm(lat, lon, *courier.CurrentLat, *courier.CurrentLon)
+			if courier.DistanceKm > radius {
+				continue
+			}
+		}
+		result = append(result, courier)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].DistanceKm < result[j].DistanceKm
+	})
+	return result
+}
+
+// parseProximityQuery разбирает параметры поиска по местоположению: lat, lon и radius (км)
+func parseProximityQuery(query url.Values) (lat, lon, radius float64, err error) {
+	lat, err = strconv.ParseFloat(query.Get("lat"), 64)
+	if err != nil || !geo.ValidLat(lat) {
+		return 0, 0, 0, fmt.Errorf("lat must be a number between -90 and 90")
+	}
+
+	lon, err = strconv.ParseFloat(query.Get("lon"), 64)
+	if err != nil || !geo.ValidLon(lon) {
+		return 0, 0, 0, fmt.Errorf("lon must be a number between -180 and 180")
+	}
+
+	radius, err = strconv.ParseFloat(query.Get("radius"), 64)
+	if err != nil || radius <= 0 {
+		return 0, 0, 0, fmt.Errorf("radius must be a positive number of kilometers")
+	}
+
+	return lat, lon, radius, nil
 }
 
 // GetAvailableCouriers получает список доступных курьеров
@@ -250,9 +378,10 @@ func (h *CourierHandler) AssignOrderToCourier(w http.ResponseWriter, r *http.Req
 
 	var req struct {
 		OrderID uuid.UUID `json:"order_id"`
+		Force   bool      `json:"force"` // назначить даже при превышении максимального расстояния
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		writeDecodeErrorResponse(w, err)
 		return
 	}
 
@@ -262,20 +391,13 @@ func (h *CourierHandler) AssignOrderToCourier(w http.ResponseWriter, r *http.Req
 	}
 
 	// Назначение заказа курьеру
-	if err := h.courierService.AssignOrderToCourier(req.OrderID, courierID); err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			writeErrorResponse(w, http.StatusNotFound, err.Error())
-		} else if strings.Contains(err.Error(), "not available") {
-			writeErrorResponse(w, http.StatusBadRequest, err.Error())
-		} else {
-			h.log.WithError(err).Error("Failed to assign order to courier")
-			writeErrorResponse(w, http.StatusInternalServerError, "Failed to assign order to courier")
-		}
+	if err := h.courierService.AssignOrderToCourier(req.OrderID, courierID, req.Force); err != nil {
+		writeServiceError(w, h.log, err, "Failed to assign order to courier")
 		return
 	}
 
 	// Публикация события назначения курьера
-	if err := h.producer.PublishCourierAssigned(req.OrderID, courierID); err != nil {
+	if err := h.producer.PublishCourierAssigned(r.Context(), req.OrderID, courierID); err != nil {
 		h.log.WithError(err).Error("Failed to publish courier assigned event")
 	}
 
@@ -283,13 +405,328 @@ func (h *CourierHandler) AssignOrderToCourier(w http.ResponseWriter, r *http.Req
 	courierCacheKey := redis.GenerateKey(redis.KeyPrefixCourier, courierID.String())
 	orderCacheKey := redis.GenerateKey(redis.KeyPrefixOrder, req.OrderID.String())
 
-	h.redisClient.Delete(r.Context(), courierCacheKey)
-	h.redisClient.Delete(r.Context(), orderCacheKey)
+	h.cacheService.Delete(r.Context(), courierCacheKey)
+	h.cacheService.Delete(r.Context(), orderCacheKey)
 
 	h.log.WithField("order_id", req.OrderID).WithField("courier_id", courierID).Info("Order assigned to courier")
 	writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Order assigned to courier successfully"})
 }
 
+// RecordLocationsBatch сохраняет пакет точек, накопленных приложением курьера без связи
+func (h *CourierHandler) RecordLocationsBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	courierID, err := extractUUIDFromPath(r.URL.Path, "/api/couriers/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid courier ID")
+		return
+	}
+
+	var req models.BatchLocationUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeErrorResponse(w, err)
+		return
+	}
+
+	if err := validateLocationPoints(req.Points, time.Now()); err != nil {
+		writeValidationErrorResponse(w, r, h.log, err)
+		return
+	}
+
+	updated, err := h.courierService.RecordLocations(courierID, req.Points)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to record courier locations")
+		return
+	}
+
+	latest := req.Points[len(req.Points)-1]
+	if updated {
+		if err := h.producer.PublishLocationUpdated(r.Context(), courierID, latest.Lat, latest.Lon); err != nil {
+			h.log.WithError(err).Error("Failed to publish location updated event")
+		}
+
+		cacheKey := redis.GenerateKey(redis.KeyPrefixCourier, courierID.String())
+		if err := h.cacheService.Delete(r.Context(), cacheKey); err != nil {
+			h.log.WithError(err).Error("Failed to invalidate courier cache")
+		}
+	}
+
+	writeJSONResponse(w, http.StatusOK, models.BatchLocationUpdateResponse{
+		Accepted:      len(req.Points),
+		Latest:        latest,
+		LatestApplied: updated,
+	})
+}
+
+// validateUpdateCourierStatusRequest проверяет, что статус известен, а координаты переданы вместе
+// и лежат в допустимом диапазоне
+func validateUpdateCourierStatusRequest(req *models.UpdateCourierStatusRequest) error {
+	verr := &ValidationError{}
+
+	if !req.Status.IsValid() {
+		verr.Add("status", "status must be one of offline, available, busy")
+	}
+
+	if (req.CurrentLat == nil) != (req.CurrentLon == nil) {
+		verr.Add("current_lat", "current_lat and current_lon must be provided together")
+	} else if req.CurrentLat != nil {
+		if !geo.ValidLat(*req.CurrentLat) {
+			verr.Add("current_lat", "current_lat must be between -90 and 90")
+		}
+		if !geo.ValidLon(*req.CurrentLon) {
+			verr.Add("current_lon", "current_lon must be between -180 and 180")
+		}
+	}
+
+	return verr.Err()
+}
+
+// validateLocationPoints проверяет размер пакета, координаты и возрастание времени точек
+func validateLocationPoints(points []models.LocationPoint, now time.Time) error {
+	verr := &ValidationError{}
+
+	if len(points) == 0 {
+		verr.Add("points", "at least one point is required")
+		return verr.Err()
+	}
+	if len(points) > maxLocationBatchSize {
+		verr.Add("points", "batch cannot contain more than %d points", maxLocationBatchSize)
+		return verr.Err()
+	}
+
+	for i, point := range points {
+		field := fmt.Sprintf("points[%d]", i)
+		if !geo.ValidLat(point.Lat) {
+			verr.Add(field+".lat", "lat must be between -90 and 90")
+		}
+		if !geo.ValidLon(point.Lon) {
+			verr.Add(field+".lon", "lon must be between -180 and 180")
+		}
+		switch {
+		case point.Timestamp.IsZero():
+			verr.Add(field+".timestamp", "timestamp is required")
+		case point.Timestamp.After(now.Add(maxLocationClockSkew)):
+			verr.Add(field+".timestamp", "timestamp cannot be in the future")
+		case i > 0 && !point.Timestamp.After(points[i-1].Timestamp):
+			verr.Add(field+".timestamp", "points must be ordered by ascending timestamp")
+		}
+	}
+
+	return verr.Err()
+}
+
+// GetCourierDeliveries возвращает историю доставок курьера с длительностью каждой доставки и итогами
+func (h *CourierHandler) GetCourierDeliveries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	courierID, err := extractUUIDFromPath(r.URL.Path, "/api/couriers/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid courier ID")
+		return
+	}
+
+	query := r.URL.Query()
+
+	// Пока в истории только доставленные заказы; параметр принимается для совместимости с будущими статусами
+	if status := query.Get("status"); status != "" && status != string(models.OrderStatusDelivered) {
+		writeErrorResponsef(w, http.StatusBadRequest, "Invalid status: expected %s", models.OrderStatusDelivered)
+		return
+	}
+
+	from, to, ok := parseTimeRange(w, query)
+	if !ok {
+		return
+	}
+
+	limit, offset := parsePagination(query, h.pageCfg)
+
+	deliveries, err := h.courierService.GetCourierDeliveries(courierID, from, to, limit, offset)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to get courier deliveries")
+		return
+	}
+
+	setPaginationHeaders(w, limit, offset)
+	writeJSONResponse(w, http.StatusOK, deliveries)
+}
+
+// Форматы отчета об эффективности курьеров
+const (
+	reportFormatJSON = "json"
+	reportFormatCSV  = "csv"
+)
+
+// courierReportCSVHeader заголовок CSV-отчета об эффективности курьеров, в порядке полей courierReportCSVRecord
+var courierReportCSVHeader = []string{
+	"courier_id", "name", "delivered_orders", "average_delivery_duration_seconds", "total_earnings", "cancelled_orders",
+}
+
+// GetCourierReport выгружает отчет об эффективности всех курьеров за период в JSON или CSV (параметр format).
+// CSV записывается в ответ по мере чтения строк из базы данных
+func (h *CourierHandler) GetCourierReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+
+	format := query.Get("format")
+	if format == "" {
+		format = reportFormatJSON
+	}
+	if format != reportFormatJSON && format != reportFormatCSV {
+		writeErrorResponsef(w, http.StatusBadRequest, "Invalid format: expected %s or %s", reportFormatJSON, reportFormatCSV)
+		return
+	}
+
+	from, to, ok := parseTimeRange(w, query)
+	if !ok {
+		return
+	}
+
+	if format == reportFormatJSON {
+		report := []*models.CourierReportRow{}
+		err := h.courierService.StreamCourierReport(from, to, func(row *models.CourierReportRow) error {
+			report = append(report, row)
+			return nil
+		})
+		if err != nil {
+			writeServiceError(w, h.log, err, "Failed to get courier report")
+			return
+		}
+
+		writeJSONResponse(w, http.StatusOK, report)
+		return
+	}
+
+	// Заголовки ответа отправляются с первой строкой, чтобы ошибка запроса к базе данных
+	// до начала выгрузки еще могла вернуться обычным ответом об ошибке
+	var csvWriter *csv.Writer
+	start := func() error {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="courier-report.csv"`)
+		w.WriteHeader(http.StatusOK)
+		csvWriter = csv.NewWriter(w)
+		return csvWriter.Write(courierReportCSVHeader)
+	}
+
+	err := h.courierService.StreamCourierReport(from, to, func(row *models.CourierReportRow) error {
+		if csvWriter == nil {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		return csvWriter.Write(courierReportCSVRecord(row))
+	})
+	if err != nil && csvWriter == nil {
+		writeServiceError(w, h.log, err, "Failed to get courier report")
+		return
+	}
+	if err != nil {
+		// Часть отчета уже отправлена, поэтому ответ обрывается: клиент не получит неполный файл как полный
+		h.log.WithError(err).Error("Failed to stream courier report")
+		panic(http.ErrAbortHandler)
+	}
+
+	if csvWriter == nil {
+		if err := start(); err != nil {
+			h.log.WithError(err).Error("Failed to write courier report")
+			return
+		}
+	}
+	csvWriter.Flush()
+	if err := csvWriter.Error(); err != nil {
+		h.log.WithError(err).Error("Failed to write courier report")
+	}
+}
+
+// courierReportCSVRecord преобразует строку отчета в запись CSV; пустое значение означает отсутствие данных
+func courierReportCSVRecord(row *models.CourierReportRow) []string {
+	avgDuration := ""
+	if row.AverageDeliveryDurationSeconds != nil {
+		avgDuration = strconv.FormatFloat(*row.AverageDeliveryDurationSeconds, 'f', 0, 64)
+	}
+	return []string{
+		row.CourierID.String(),
+		row.Name,
+		strconv.Itoa(row.DeliveredOrders),
+		avgDuration,
+		row.TotalEarnings.String(),
+		strconv.Itoa(row.CancelledOrders),
+	}
+}
+
+// parseTimeRange разбирает необязательные параметры from и to в формате RFC 3339.
+// При ошибке отвечает 400 и возвращает ok = false
+func parseTimeRange(w http.ResponseWriter, query url.Values) (from, to *time.Time, ok bool) {
+	for name, dest := range map[string]**time.Time{"from": &from, "to": &to} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			writeErrorResponsef(w, http.StatusBadRequest, "Invalid %s: expected RFC 3339 time", name)
+			return nil, nil, false
+		}
+		*dest = &t
+	}
+	if from != nil && to != nil && !from.Before(*to) {
+		writeErrorResponse(w, http.StatusBadRequest, "from must be before to")
+		return nil, nil, false
+	}
+	return from, to, true
+}
+
+// PreviewAutoAssignment показывает, какого курьера выбрало бы автоматическое назначение заказа,
+// и следующих по порядку кандидатов. Заказ и курьеры не изменяются
+func (h *CourierHandler) PreviewAutoAssignment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	orderID, err := extractUUIDFromPath(r.URL.Path, "/api/orders/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	alternatives := defaultPreviewAlternatives
+	if altStr := r.URL.Query().Get("alternatives"); altStr != "" {
+		n, err := strconv.Atoi(altStr)
+		if err != nil || n < 0 || n > maxPreviewAlternatives {
+			writeErrorResponsef(w, http.StatusBadRequest, "alternatives must be between 0 and %d", maxPreviewAlternatives)
+			return
+		}
+		alternatives = n
+	}
+
+	ranked, err := h.courierService.RankCouriersForOrder(orderID, alternatives+1)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to preview auto-assignment")
+		return
+	}
+
+	preview := &models.AutoAssignPreview{
+		OrderID:      orderID,
+		Alternatives: []*models.CourierWithDistance{},
+	}
+	if len(ranked) > 0 {
+		preview.Candidate = ranked[0]
+		preview.Alternatives = ranked[1:]
+	}
+
+	writeJSONResponse(w, http.StatusOK, preview)
+}
+
 // validateCreateCourierRequest валидирует запрос на создание курьера
 func (h *CourierHandler) validateCreateCourierRequest(req *models.CreateCourierRequest) error {
 	if req.Name == "" {
@@ -298,5 +735,133 @@ func (h *CourierHandler) validateCreateCourierRequest(req *models.CreateCourierR
 	if req.Phone == "" {
 		return fmt.Errorf("courier phone is required")
 	}
+	if err := models.ValidateSkills(req.Skills); err != nil {
+		return fmt.Errorf("skills: %w", err)
+	}
 	return nil
 }
+
+// StartShift открывает рабочую смену курьера
+func (h *CourierHandler) StartShift(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	courierID, err := extractUUIDFromPath(r.URL.Path, "/api/couriers/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid courier ID")
+		return
+	}
+
+	shift, oldStatus, err := h.courierService.StartShift(courierID)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to start courier shift")
+		return
+	}
+
+	if oldStatus == models.CourierStatusOffline {
+		if err := h.producer.PublishCourierStatusChanged(r.Context(), courierID, oldStatus, models.CourierStatusAvailable); err != nil {
+			h.log.WithError(err).Error("Failed to publish courier status changed event")
+		}
+	}
+
+	cacheKey := redis.GenerateKey(redis.KeyPrefixCourier, courierID.String())
+	if err := h.cacheService.Delete(r.Context(), cacheKey); err != nil {
+		h.log.WithError(err).Error("Failed to invalidate courier cache")
+	}
+
+	writeJSONResponse(w, http.StatusCreated, shift)
+}
+
+// EndShift закрывает рабочую смену курьера
+func (h *CourierHandler) EndShift(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	courierID, err := extractUUIDFromPath(r.URL.Path, "/api/couriers/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid courier ID")
+		return
+	}
+
+	shift, oldStatus, err := h.courierService.EndShift(courierID)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to end courier shift")
+		return
+	}
+
+	if oldStatus != models.CourierStatusOffline {
+		if err := h.producer.PublishCourierStatusChanged(r.Context(), courierID, oldStatus, models.CourierStatusOffline); err != nil {
+			h.log.WithError(err).Error("Failed to publish courier status changed event")
+		}
+	}
+
+	cacheKey := redis.GenerateKey(redis.KeyPrefixCourier, courierID.String())
+	if err := h.cacheService.Delete(r.Context(), cacheKey); err != nil {
+		h.log.WithError(err).Error("Failed to invalidate courier cache")
+	}
+
+	writeJSONResponse(w, http.StatusOK, shift)
+}
+
+// DeactivateCourier отключает курьера, который больше не работает в компании
+func (h *CourierHandler) DeactivateCourier(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	courierID, err := extractUUIDFromPath(r.URL.Path, "/api/couriers/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid courier ID")
+		return
+	}
+
+	oldStatus, err := h.courierService.DeactivateCourier(courierID)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to deactivate courier")
+		return
+	}
+
+	if oldStatus != models.CourierStatusOffline {
+		if err := h.producer.PublishCourierStatusChanged(r.Context(), courierID, oldStatus, models.CourierStatusOffline); err != nil {
+			h.log.WithError(err).Error("Failed to publish courier status changed event")
+		}
+	}
+
+	cacheKey := redis.GenerateKey(redis.KeyPrefixCourier, courierID.String())
+	if err := h.cacheService.Delete(r.Context(), cacheKey); err != nil {
+		h.log.WithError(err).Error("Failed to invalidate courier cache")
+	}
+
+	writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Courier deactivated successfully"})
+}
+
+// ReactivateCourier возвращает отключенного курьера в работу
+func (h *CourierHandler) ReactivateCourier(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	courierID, err := extractUUIDFromPath(r.URL.Path, "/api/couriers/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid courier ID")
+		return
+	}
+
+	if err := h.courierService.ReactivateCourier(courierID); err != nil {
+		writeServiceError(w, h.log, err, "Failed to reactivate courier")
+		return
+	}
+
+	cacheKey := redis.GenerateKey(redis.KeyPrefixCourier, courierID.String())
+	if err := h.cacheService.Delete(r.Context(), cacheKey); err != nil {
+		h.log.WithError(err).Error("Failed to invalidate courier cache")
+	}
+
+	writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Courier reactivated successfully"})
+}
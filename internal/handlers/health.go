@@ -2,10 +2,15 @@ package handlers
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"sync/atomic"
 	"time"
 
+	"delivery-system/internal/buildinfo"
 	"delivery-system/internal/database"
+	"delivery-system/internal/kafka"
+	"delivery-system/internal/logger"
 	"delivery-system/internal/redis"
 )
 
@@ -13,22 +18,37 @@ import (
 type HealthHandler struct {
 	db          *database.DB
 	redisClient *redis.Client
+	kafkaHealth *kafka.HealthChecker
+	consumer    *kafka.Consumer
+	log         *logger.Logger
+	// migrationsPending выставляется, пока при запуске применяются миграции
+	migrationsPending atomic.Bool
 }
 
 // NewHealthHandler создает новый обработчик здоровья
-func NewHealthHandler(db *database.DB, redisClient *redis.Client) *HealthHandler {
+func NewHealthHandler(db *database.DB, redisClient *redis.Client, kafkaHealth *kafka.HealthChecker, consumer *kafka.Consumer, log *logger.Logger) *HealthHandler {
 	return &HealthHandler{
 		db:          db,
 		redisClient: redisClient,
+		kafkaHealth: kafkaHealth,
+		consumer:    consumer,
+		log:         log,
 	}
 }
 
+// SetMigrationsPending отмечает, что миграции еще применяются и приложение не готово принимать трафик
+func (h *HealthHandler) SetMigrationsPending(pending bool) {
+	h.migrationsPending.Store(pending)
+}
+
 // HealthResponse представляет ответ проверки здоровья
 type HealthResponse struct {
-	Status   string            `json:"status"`
-	Services map[string]string `json:"services"`
-	Version  string            `json:"version"`
-	Uptime   string            `json:"uptime"`
+	Status    string            `json:"status"`
+	Services  map[string]string `json:"services"`
+	Version   string            `json:"version"`
+	Commit    string            `json:"commit"`
+	BuildTime string            `json:"build_time"`
+	Uptime    string            `json:"uptime"`
 }
 
 var startTime = time.Now()
@@ -62,14 +82,42 @@ func (h *HealthHandler) Health(w http.ResponseWriter, r *http.Request) {
 		services["redis"] = "healthy"
 	}
 
-	// Kafka проверку можно добавить позже
-	services["kafka"] = "not checked"
+	// Проверка Kafka
+	if err := h.kafkaHealth.Health(ctx); err != nil {
+		services["kafka"] = "unhealthy: " + err.Error()
+		overallStatus = "unhealthy"
+	} else {
+		services["kafka"] = "healthy"
+	}
+
+	// Consumer без назначенных партиций не обрабатывает события, но HTTP API продолжает работать
+	if h.consumer.Ready() {
+		services["kafka_consumer"] = fmt.Sprintf("healthy: %d partitions assigned", h.consumer.AssignedPartitions())
+	} else {
+		services["kafka_consumer"] = "degraded: no partitions assigned"
+		if overallStatus == "healthy" {
+			overallStatus = "degraded"
+		}
+	}
 
+	// Проверка записи логов: недоступный файл логов не мешает обслуживать запросы
+	if err := h.log.CheckWritable(); err != nil {
+		services["logs"] = "degraded: " + err.Error()
+		if overallStatus == "healthy" {
+			overallStatus = "degraded"
+		}
+	} else {
+		services["logs"] = "healthy"
+	}
+
+	build := buildinfo.Get()
 	response := HealthResponse{
-		Status:   overallStatus,
-		Services: services,
-		Version:  "1.0.0",
-		Uptime:   time.Since(startTime).String(),
+		Status:    overallStatus,
+		Services:  services,
+		Version:   build.Version,
+		Commit:    build.Commit,
+		BuildTime: build.BuildTime,
+		Uptime:    time.Since(startTime).String(),
 	}
 
 	statusCode := http.StatusOK
@@ -87,6 +135,12 @@ func (h *HealthHandler) Readiness(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Пока применяются миграции, схема БД может быть в промежуточном состоянии
+	if h.migrationsPending.Load() {
+		writeErrorResponse(w, http.StatusServiceUnavailable, "Database migrations in progress")
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
 	defer cancel()
 
@@ -101,6 +155,23 @@ func (h *HealthHandler) Readiness(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.kafkaHealth.Health(ctx); err != nil {
+		writeErrorResponse(w, http.StatusServiceUnavailable, "Kafka not ready")
+		return
+	}
+
+	// Пока группа не назначила партиции, события не обрабатываются
+	if !h.consumer.Ready() {
+		writeErrorResponse(w, http.StatusServiceUnavailable, "Kafka consumer not ready")
+		return
+	}
+
+	// Недоступный файл логов не снимает приложение с балансировки, но отражается в ответе
+	if err := h.log.CheckWritable(); err != nil {
+		writeJSONResponse(w, http.StatusOK, map[string]string{"status": "degraded", "logs": err.Error()})
+		return
+	}
+
 	writeJSONResponse(w, http.StatusOK, map[string]string{"status": "ready"})
 }
 
@@ -116,3 +187,13 @@ func (h *HealthHandler) Liveness(w http.ResponseWriter, r *http.Request) {
 		"uptime": time.Since(startTime).String(),
 	})
 }
+
+// Version возвращает информацию о версии и сборке приложения
+func (h *HealthHandler) Version(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, buildinfo.Get())
+}
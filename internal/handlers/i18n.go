@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"delivery-system/internal/config"
+)
+
+// Localize возвращает middleware, выбирающий язык сообщений об ошибках по заголовку Accept-Language.
+// Если клиент не указал поддерживаемый язык, используется defaultLanguage
+func Localize(defaultLanguage string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			language := preferredLanguage(r.Header.Get("Accept-Language"))
+			if language == "" {
+				language = defaultLanguage
+			}
+
+			pw := withPreferences(w)
+			pw.language = language
+			pw.Header().Add("Vary", "Accept-Language")
+			next(pw, r)
+		}
+	}
+}
+
+// preferredLanguage выбирает из заголовка Accept-Language поддерживаемый язык с наибольшим весом.
+// Возвращает пустую строку, если ни один из перечисленных языков не поддерживается
+func preferredLanguage(acceptLanguage string) string {
+	best, bestWeight := "", 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+
+		weight := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			weight = parsed
+		}
+
+		// Региональные варианты (ru-RU, en-US) сводятся к основному языку
+		primary, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if _, ok := messageCatalog[primary]; !ok || weight <= bestWeight {
+			continue
+		}
+		best, bestWeight = primary, weight
+	}
+	return best
+}
+
+// messageCatalog содержит переводы сообщений об ошибках. Идентификатором сообщения служит
+// его английский текст (для сообщений с параметрами - строка формата), поэтому английский
+// каталог пуст, а сообщения без перевода возвращаются как есть
+var messageCatalog = map[string]map[string]string{
+	config.LanguageEnglish: {},
+	config.LanguageRussian: {
+		"Method not allowed":                        "Метод не поддерживается",
+		"Invalid request body":                      "Некорректное тело запроса",
+		"Request body is empty":                     "Тело запроса пустое",
+		"Request body contains incomplete JSON":     "Тело запроса содержит незавершенный JSON",
+		"Invalid order ID":                          "Некорректный ID заказа",
+		"Invalid courier ID":                        "Некорректный ID курьера",
+		"Invalid item ID":                           "Некорректный ID позиции",
+		"Invalid subscription ID":                   "Некорректный ID подписки",
+		"Order ID is required":                      "Не указан ID заказа",
+		"Order IDs are required":                    "Не указаны ID заказов",
+		"Validation failed":                         "Ошибка валидации",
+		"Rate limit exceeded":                       "Превышен лимит запросов",
+		"Too many concurrent requests":              "Слишком много одновременных запросов",
+		"Service is overloaded, please retry later": "Сервис перегружен, повторите запрос позже",
+		"Invalid admin token":                       "Неверный токен администратора",
+		"Invalid API key":                           "Неверный ключ API",
+		"Admin API is disabled":                     "Административное API отключено",
+
+		"Some orders cannot change status, no orders were updated":   "Часть заказов не может перейти в статус, ни один заказ не изменен",
+		"Order was modified concurrently":                            "Заказ был изменен параллельно",
+		"Order version is required: send version or If-Match header": "Требуется версия заказа: передайте version или заголовок If-Match",
+		"Invalid sla filter: expected breached":                      "Некорректный фильтр sla: ожидается breached",
+		"Malformed JSON at byte offset %d":                           "Синтаксическая ошибка JSON на позиции %d (в байтах)",
+		"Invalid type for field %s: expected %s, got %s":             "Неверный тип поля %s: ожидается %s, получено %s",
+		"Invalid request body: expected %s, got %s":                  "Некорректное тело запроса: ожидается %s, получено %s",
+		"Invalid skill: %s":                                          "Некорректный навык: %s",
+		"Unknown preset: %s":                                         "Неизвестный пресет: %s",
+		"Invalid sort: expected %s or %s":                            "Некорректная сортировка: ожидается %s или %s",
+		"Search query must be at least %d characters long":           "Поисковый запрос должен содержать не менее %d символов",
+		"Cannot update more than %d orders at once":                  "Нельзя изменить более %d заказов за раз",
+		"Cannot request more than %d orders at once":                 "Нельзя запросить более %d заказов за раз",
+		"alternatives must be between 0 and %d":                      "alternatives должен быть от 0 до %d",
+
+		"Database not ready":              "База данных не готова",
+		"Redis not ready":                 "Redis не готов",
+		"Kafka not ready":                 "Kafka не готова",
+		"Kafka consumer not ready":        "Consumer Kafka не готов",
+		"Database migrations in progress": "Выполняются миграции базы данных",
+
+		"Failed to create order":                "Не удалось создать заказ",
+		"Failed to get order":                   "Не удалось получить заказ",
+		"Failed to update order statuses":       "Не удалось изменить статусы заказов",
+		"Failed to update order status":         "Не удалось обновить статус заказа",
+		"Failed to get order timeline":          "Не удалось получить хронологию заказа",
+		"Failed to mark order ready":            "Не удалось отметить готовность заказа",
+		"Failed to submit delivery proof":       "Не удалось подтвердить доставку",
+		"Failed to unassign order":              "Не удалось снять курьера с заказа",
+		"Failed to remove order item":           "Не удалось удалить позицию заказа",
+		"Failed to update order item":           "Не удалось изменить позицию заказа",
+		"Failed to recalculate delivery cost":   "Не удалось пересчитать стоимость доставки",
+		"Failed to preview auto-assignment":     "Не удалось подобрать курьера",
+		"Failed to auto-assign order":           "Не удалось автоматически назначить курьера",
+		"Failed to subscribe to order offers":   "Не удалось подписаться на предложения заказов",
+		"Failed to get courier deliveries":      "Не удалось получить историю доставок курьера",
+		"Invalid status: expected %s":           "Некорректный статус: ожидается %s",
+		"Invalid %s: expected RFC 3339 time":    "Некорректный параметр %s: ожидается время в формате RFC 3339",
+		"Failed to get courier report":          "Не удалось получить отчет по курьерам",
+		"Invalid format: expected %s or %s":     "Некорректный формат: ожидается %s или %s",
+		"from must be before to":                "from должен быть раньше to",
+		"Failed to get courier":                 "Не удалось получить курьера",
+		"Failed to update courier status":       "Не удалось обновить статус курьера",
+		"Failed to record courier locations":    "Не удалось сохранить местоположения курьера",
+		"Failed to assign order to courier":     "Не удалось назначить заказ курьеру",
+		"Failed to start courier shift":         "Не удалось начать смену курьера",
+		"Failed to end courier shift":           "Не удалось завершить смену курьера",
+		"Failed to deactivate courier":          "Не удалось отключить курьера",
+		"Failed to reactivate courier":          "Не удалось вернуть курьера в работу",
+		"Failed to get webhook subscription":    "Не удалось получить подписку на webhook",
+		"Failed to update webhook subscription": "Не удалось обновить подписку на webhook",
+		"Failed to delete webhook subscription": "Не удалось удалить подписку на webhook",
+		"Failed to get orders":                  "Не удалось получить заказы",
+		"Failed to get unassigned orders":       "Не удалось получить заказы без курьера",
+		"Failed to get orders by status":        "Не удалось получить заказы по статусу",
+		"Failed to get couriers":                "Не удалось получить курьеров",
+		"Failed to get available couriers":      "Не удалось получить доступных курьеров",
+		"Failed to get courier stats":           "Не удалось получить статистику курьеров",
+		"Failed to create courier":              "Не удалось создать курьера",
+		"Failed to get rate limit status":       "Не удалось получить состояние лимита запросов",
+		"Failed to list rate limit bans":        "Не удалось получить список блокировок",
+		"Failed to get webhook subscriptions":   "Не удалось получить подписки на webhooks",
+		"Failed to create webhook subscription": "Не удалось создать подписку на webhook",
+	},
+}
+
+// localize возвращает перевод сообщения на язык, выбранный middleware Localize, и язык результата
+func localize(w http.ResponseWriter, message string) (string, string) {
+	prefs := responsePreferences(w)
+	if prefs == nil || prefs.language == "" {
+		return message, config.LanguageEnglish
+	}
+	if translated, ok := messageCatalog[prefs.language][message]; ok {
+		return translated, prefs.language
+	}
+	return message, config.LanguageEnglish
+}
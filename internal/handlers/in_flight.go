@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// InFlightTracker считает запросы, обрабатываемые сервером в данный момент.
+// Используется при остановке сервера, чтобы показать, завершения каких запросов он ждал
+type InFlightTracker struct {
+	active atomic.Int64
+}
+
+// NewInFlightTracker создает новый счетчик активных запросов
+func NewInFlightTracker() *InFlightTracker {
+	return &InFlightTracker{}
+}
+
+// Wrap оборачивает обработчик подсчетом активных запросов
+func (t *InFlightTracker) Wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		t.active.Add(1)
+		defer t.active.Add(-1)
+		next(w, r)
+	}
+}
+
+// Count возвращает количество запросов, обрабатываемых в данный момент
+func (t *InFlightTracker) Count() int64 {
+	return t.active.Load()
+}
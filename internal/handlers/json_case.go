@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"delivery-system/internal/config"
+)
+
+// JSONCase возвращает middleware, выбирающий стиль ключей JSON в ответе. Клиент может запросить
+// стиль параметром заголовка Accept, например "Accept: application/json; case=camel",
+// иначе используется defaultCase (config.JSONCaseSnake или config.JSONCaseCamel)
+func JSONCase(defaultCase string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			jsonCase := requestedJSONCase(r.Header.Get("Accept"))
+			if jsonCase == "" {
+				jsonCase = defaultCase
+			}
+
+			if jsonCase == config.JSONCaseCamel {
+				pw := withPreferences(w)
+				pw.jsonCase = jsonCase
+				w = pw
+			}
+			next(w, r)
+		}
+	}
+}
+
+// requestedJSONCase возвращает стиль ключей из параметра case заголовка Accept или пустую строку
+func requestedJSONCase(accept string) string {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || (mediaType != "application/json" && mediaType != "*/*") {
+			continue
+		}
+		switch params["case"] {
+		case config.JSONCaseSnake, config.JSONCaseCamel:
+			return params["case"]
+		}
+	}
+	return ""
+}
+
+// encodeJSON кодирует data в JSON с учетом стиля ключей, выбранного middleware JSONCase
+func encodeJSON(w http.ResponseWriter, data interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(data); err != nil {
+		return nil, err
+	}
+
+	if prefs := responsePreferences(w); prefs == nil || prefs.jsonCase != config.JSONCaseCamel {
+		return buf.Bytes(), nil
+	}
+
+	// Ключи преобразуются после кодирования, чтобы не дублировать теги json в моделях
+	decoder := json.NewDecoder(&buf)
+	decoder.UseNumber()
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err := json.NewEncoder(&out).Encode(camelizeKeys(generic, reflect.ValueOf(data))); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// camelizeKeys рекурсивно переводит ключи объектов из snake_case в camelCase. source - исходное значение,
+// из которого получен value: по нему ключи структур и ответов вида map[string]interface{} переводятся,
+// а ключи словарей с данными (например, map[models.OrderStatus]int) остаются без изменений, чтобы
+// совпадать со значениями, которые клиент отправляет в запросах. Без source переводятся все ключи
+func camelizeKeys(value interface{}, source reflect.Value) interface{} {
+	source = indirect(source)
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		var fields map[string]reflect.Value
+		if source.Kind() == reflect.Struct {
+			fields = jsonFields(source)
+		}
+		keepKeys := source.Kind() == reflect.Map && !isObjectMap(source.Type())
+
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			var itemSource reflect.Value
+			if fields != nil {
+				itemSource = fields[key]
+			} else if source.Kind() == reflect.Map {
+				itemSource = mapIndex(source, key)
+			}
+
+			if !keepKeys {
+				key = snakeToCamel(key)
+			}
+			result[key] = camelizeKeys(item, itemSource)
+		}
+		return result
+	case []interface{}:
+		for i, item := range v {
+			var itemSource reflect.Value
+			if (source.Kind() == reflect.Slice || source.Kind() == reflect.Array) && i < source.Len() {
+				itemSource = source.Index(i)
+			}
+			v[i] = camelizeKeys(item, itemSource)
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+// marshalerType тип json.Marshaler: представление таких значений в JSON не следует из их полей
+var marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// indirect разыменовывает указатели и интерфейсы. Для nil и значений со своей реализацией
+// json.Marshaler возвращает пустое значение: их ключи переводятся без учета исходного типа
+func indirect(value reflect.Value) reflect.Value {
+	for value.IsValid() && (value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface) {
+		if value.IsNil() || value.Type().Implements(marshalerType) {
+			return reflect.Value{}
+		}
+		value = value.Elem()
+	}
+	if value.IsValid() && (value.Type().Implements(marshalerType) || reflect.PointerTo(value.Type()).Implements(marshalerType)) {
+		return reflect.Value{}
+	}
+	return value
+}
+
+// isObjectMap сообщает, что словарь собирает ответ из полей (map[string]interface{}), а не хранит данные
+func isObjectMap(t reflect.Type) bool {
+	return t.Key().Kind() == reflect.String && t.Key().PkgPath() == "" && t.Elem().Kind() == reflect.Interface
+}
+
+// mapIndex возвращает значение словаря по ключу из JSON или пустое значение, если ключ не строковый
+func mapIndex(m reflect.Value, key string) reflect.Value {
+	if m.Type().Key().Kind() != reflect.String {
+		return reflect.Value{}
+	}
+	return m.MapIndex(reflect.ValueOf(key).Convert(m.Type().Key()))
+}
+
+// jsonFields сопоставляет ключи JSON структуры значениям ее полей, включая поля встроенных структур
+func jsonFields(value reflect.Value) map[string]reflect.Value {
+	fields := make(map[string]reflect.Value, value.NumField())
+	var embedded []reflect.Value
+
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			if inner := indirect(value.Field(i)); inner.Kind() == reflect.Struct {
+				embedded = append(embedded, inner)
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = value.Field(i)
+	}
+
+	// Поля самой структуры имеют приоритет над одноименными полями встроенных, как в encoding/json
+	for _, inner := range embedded {
+		for name, field := range jsonFields(inner) {
+			if _, ok := fields[name]; !ok {
+				fields[name] = field
+			}
+		}
+	}
+	return fields
+}
+
+// snakeToCamel переводит "delivery_address" в "deliveryAddress"
+func snakeToCamel(key string) string {
+	if !strings.Contains(key, "_") {
+		return key
+	}
+
+	var b strings.Builder
+	b.Grow(len(key))
+	upper := false
+	for i, r := range key {
+		switch {
+		case r == '_' && i > 0:
+			upper = true
+		case upper:
+			b.WriteString(strings.ToUpper(string(r)))
+			upper = false
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
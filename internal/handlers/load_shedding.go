@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"delivery-system/internal/config"
+	"delivery-system/internal/database"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/metrics"
+	"delivery-system/internal/redis"
+)
+
+// Причины сброса нагрузки
+const (
+	shedReasonDatabase = "database_pool_saturated"
+	shedReasonRedis    = "redis_unavailable"
+)
+
+// LoadShedder отклоняет некритичные запросы с 503, пока пул соединений с базой данных исчерпан
+// или Redis недоступен, чтобы запросы не копились в очереди до истечения таймаутов.
+// Состояние зависимостей обновляется в фоне (Run), проверка в middleware ничего не стоит
+type LoadShedder struct {
+	db          *database.DB
+	redisClient *redis.Client
+	cfg         *config.LoadSheddingConfig
+	shed        *metrics.Vec
+	log         *logger.Logger
+
+	// reason причина сброса нагрузки, пустая строка - сервис не перегружен
+	reason atomic.Value
+	// lastWaitCount число ожиданий свободного соединения на момент предыдущей проверки
+	lastWaitCount int64
+}
+
+// NewLoadShedder создает middleware сброса нагрузки
+func NewLoadShedder(db *database.DB, redisClient *redis.Client, cfg *config.LoadSheddingConfig, registry *metrics.Registry, log *logger.Logger) *LoadShedder {
+	s := &LoadShedder{
+		db:          db,
+		redisClient: redisClient,
+		cfg:         cfg,
+		shed:        registry.NewCounter("delivery_requests_shed_total", "Requests rejected with 503 while dependencies are overloaded", "reason"),
+		log:         log,
+	}
+	s.reason.Store("")
+	return s
+}
+
+// Run периодически проверяет пул соединений с базой данных и доступность Redis до отмены контекста
+func (s *LoadShedder) Run(ctx context.Context) {
+	interval := time.Duration(s.cfg.CheckInterval) * time.Second
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	s.lastWaitCount = s.db.Stats().WaitCount
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.update(ctx, interval)
+		}
+	}
+}
+
+// update определяет причину перегрузки и логирует ее смену
+func (s *LoadShedder) update(ctx context.Context, interval time.Duration) {
+	reason := ""
+
+	// Пул исчерпан, если все соединения заняты и с прошлой проверки запросы ждали соединения
+	stats := s.db.Stats()
+	waited := stats.WaitCount > s.lastWaitCount
+	s.lastWaitCount = stats.WaitCount
+	if waited && stats.MaxOpenConnections > 0 && stats.InUse >= stats.MaxOpenConnections {
+		reason = shedReasonDatabase
+	}
+
+	if reason == "" {
+		pingCtx, cancel := context.WithTimeout(ctx, interval)
+		if err := s.redisClient.Health(pingCtx); err != nil {
+			reason = shedReasonRedis
+		}
+		cancel()
+	}
+
+	previous := s.reason.Swap(reason).(string)
+	switch {
+	case reason != "" && previous == "":
+		s.log.WithField("reason", reason).
+			WithField("db_in_use", stats.InUse).
+			WithField("db_wait_count", stats.WaitCount).
+			Warn("Load shedding started")
+	case reason == "" && previous != "":
+		s.log.WithField("reason", previous).Info("Load shedding stopped")
+	}
+}
+
+// Wrap оборачивает обработчик сбросом нагрузки. Проверки здоровья, метрики, административные
+// запросы и изменяющие запросы (не GET) выполняются всегда; чтение под /api/ отклоняется
+// с 503 и Retry-After, пока зависимости перегружены
+func (s *LoadShedder) Wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reason := s.reason.Load().(string)
+		if reason == "" || isCriticalRequest(r) {
+			next(w, r)
+			return
+		}
+
+		s.shed.Inc(reason)
+		w.Header().Set("Retry-After", strconv.Itoa(max(s.cfg.RetryAfter, 1)))
+		writeErrorResponse(w, http.StatusServiceUnavailable, "Service is overloaded, please retry later")
+	}
+}
+
+// isCriticalRequest сообщает, что запрос не отклоняется при перегрузке
+func isCriticalRequest(r *http.Request) bool {
+	return r.Method != http.MethodGet ||
+		!strings.HasPrefix(r.URL.Path, "/api/") ||
+		strings.HasPrefix(r.URL.Path, "/api/admin/")
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"delivery-system/internal/logger"
+	"delivery-system/internal/services"
+)
+
+// offerStreamHeartbeat интервал комментариев-пингов в потоке, не дающих прокси закрыть простаивающее соединение
+const offerStreamHeartbeat = 15 * time.Second
+
+// OfferHandler представляет обработчик потока предложений заказов курьерам
+type OfferHandler struct {
+	offerHub *services.OrderOfferHub
+	log      *logger.Logger
+}
+
+// NewOfferHandler создает новый обработчик предложений заказов
+func NewOfferHandler(offerHub *services.OrderOfferHub, log *logger.Logger) *OfferHandler {
+	return &OfferHandler{
+		offerHub: offerHub,
+		log:      log,
+	}
+}
+
+// StreamOffers отправляет курьеру новые заказы рядом с ним в формате Server-Sent Events.
+// Каждое предложение передается событием order_offer с JSON в поле data
+func (h *OfferHandler) StreamOffers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	courierID, err := extractUUIDFromPath(r.URL.Path, "/api/couriers/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid courier ID")
+		return
+	}
+
+	offers, err := h.offerHub.Subscribe(r.Context(), courierID)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to subscribe to order offers")
+		return
+	}
+
+	// Поток открыт дольше WriteTimeout сервера, поэтому дедлайн записи для него снимается
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		h.log.WithError(err).Warn("Failed to clear write deadline for offer stream")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		h.log.WithError(err).Error("Streaming is not supported")
+		return
+	}
+
+	h.log.WithField("courier_id", courierID).Info("Courier subscribed to order offers")
+	defer h.log.WithField("courier_id", courierID).Info("Courier unsubscribed from order offers")
+
+	heartbeat := time.NewTicker(offerStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case offer, ok := <-offers:
+			if !ok {
+				return
+			}
+
+			body, err := encodeJSON(w, offer)
+			if err != nil {
+				h.log.WithError(err).Error("Failed to encode order offer")
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: order_offer\nid: %s\ndata: %s\n\n", offer.OrderID, bytes.TrimSpace(body)); err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -1,12 +1,19 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"strconv"
+	"slices"
 	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"delivery-system/internal/config"
+	"delivery-system/internal/geo"
 	"delivery-system/internal/kafka"
 	"delivery-system/internal/logger"
 	"delivery-system/internal/models"
@@ -20,16 +27,20 @@ import (
 type OrderHandler struct {
 	orderService *services.OrderService
 	producer     *kafka.Producer
-	redisClient  *redis.Client
+	cacheService *services.CacheService
+	cfg          *config.OrderConfig
+	pageCfg      *config.PaginationConfig
 	log          *logger.Logger
 }
 
 // NewOrderHandler создает новый обработчик заказов
-func NewOrderHandler(orderService *services.OrderService, producer *kafka.Producer, redisClient *redis.Client, log *logger.Logger) *OrderHandler {
+func NewOrderHandler(orderService *services.OrderService, producer *kafka.Producer, cacheService *services.CacheService, cfg *config.OrderConfig, pageCfg *config.PaginationConfig, log *logger.Logger) *OrderHandler {
 	return &OrderHandler{
 		orderService: orderService,
 		producer:     producer,
-		redisClient:  redisClient,
+		cacheService: cacheService,
+		cfg:          cfg,
+		pageCfg:      pageCfg,
 		log:          log,
 	}
 }
@@ -43,39 +54,245 @@ func (h *OrderHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 
 	var req models.CreateOrderRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		writeDecodeErrorResponse(w, err)
 		return
 	}
 
 	// Валидация запроса
 	if err := h.validateCreateOrderRequest(&req); err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		writeValidationErrorResponse(w, r, h.log, err)
 		return
 	}
 
 	// Создание заказа
-	order, err := h.orderService.CreateOrder(&req)
+	order, duplicate, err := h.orderService.CreateOrder(r.Context(), &req)
 	if err != nil {
-		h.log.WithError(err).Error("Failed to create order")
-		writeErrorResponse(w, http.StatusInternalServerError, "Failed to create order")
+		writeServiceError(w, h.log, err, "Failed to create order")
+		return
+	}
+
+	if duplicate {
+		w.Header().Set("Location", "/api/orders/"+order.ID.String())
+		writeJSONResponse(w, http.StatusOK, h.projectOrder(r, order, order))
 		return
 	}
 
 	// Публикация события в Kafka
-	if err := h.producer.PublishOrderCreated(order); err != nil {
+	if err := h.producer.PublishOrderCreated(r.Context(), order); err != nil {
 		h.log.WithError(err).Error("Failed to publish order created event")
 		// Не возвращаем ошибку клиенту, так как заказ уже создан
 	}
 
 	// Кеширование заказа в Redis
 	cacheKey := redis.GenerateKey(redis.KeyPrefixOrder, order.ID.String())
-	if err := h.redisClient.Set(r.Context(), cacheKey, order, defaultCacheTTL); err != nil {
+	if err := h.cacheService.Set(r.Context(), cacheKey, order); err != nil {
 		h.log.WithError(err).Error("Failed to cache order")
 		// Не возвращаем ошибку клиенту
 	}
 
 	h.log.WithField("order_id", order.ID).Info("Order created successfully")
-	writeJSONResponse(w, http.StatusCreated, order)
+	w.Header().Set("Location", "/api/orders/"+order.ID.String())
+	writeJSONResponse(w, http.StatusCreated, h.projectOrder(r, order, order))
+}
+
+// maxBatchGetOrderIDs максимальное количество заказов в одном пакетном запросе
+const maxBatchGetOrderIDs = 100
+
+// BatchGetOrders получает несколько заказов за один запрос: сначала из кеша, остальные из базы данных
+func (h *OrderHandler) BatchGetOrders(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.BatchGetOrdersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeErrorResponse(w, err)
+		return
+	}
+
+	if len(req.IDs) == 0 {
+		writeErrorResponse(w, http.StatusBadRequest, "Order IDs are required")
+		return
+	}
+	if len(req.IDs) > maxBatchGetOrderIDs {
+		writeErrorResponsef(w, http.StatusBadRequest, "Cannot request more than %d orders at once", maxBatchGetOrderIDs)
+		return
+	}
+
+	ids := uniqueIDs(req.IDs)
+
+	found := make(map[uuid.UUID]*models.Order, len(ids))
+
+	// Получение закешированных заказов одним MGET
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = redis.GenerateKey(redis.KeyPrefixOrder, id.String())
+	}
+	cached, err := h.cacheService.GetMultiple(r.Context(), keys)
+	if err != nil {
+		h.log.WithError(err).Error("Failed to get orders from cache")
+	}
+	for i, id := range ids {
+		raw, ok := cached[keys[i]]
+		if !ok {
+			continue
+		}
+		var order models.Order
+		if err := json.Unmarshal([]byte(raw), &order); err != nil {
+			h.log.WithError(err).WithField("order_id", id).Warn("Failed to decode cached order")
+			continue
+		}
+		found[id] = &order
+	}
+
+	// Остальные заказы получаем из базы данных одним запросом
+	var missing []uuid.UUID
+	for _, id := range ids {
+		if _, ok := found[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		orders, err := h.orderService.GetOrdersByIDs(missing)
+		if err != nil {
+			h.log.WithError(err).Error("Failed to get orders")
+			writeErrorResponse(w, http.StatusInternalServerError, "Failed to get orders")
+			return
+		}
+		for _, order := range orders {
+			found[order.ID] = order
+			cacheKey := redis.GenerateKey(redis.KeyPrefixOrder, order.ID.String())
+			if err := h.cacheService.Set(r.Context(), cacheKey, order); err != nil {
+				h.log.WithError(err).Error("Failed to cache order")
+			}
+		}
+	}
+
+	orders := make([]*models.Order, 0, len(ids))
+	notFound := []uuid.UUID{}
+	for _, id := range ids {
+		if order, ok := found[id]; ok {
+			orders = append(orders, order)
+		} else {
+			notFound = append(notFound, id)
+		}
+	}
+
+	// Форма ответа совпадает с models.BatchGetOrdersResponse, но заказы
+	// проходят через проекцию по роли запрашивающего.
+	writeJSONResponse(w, http.StatusOK, struct {
+		Orders   interface{} `json:"orders"`
+		NotFound []uuid.UUID `json:"not_found"`
+	}{
+		Orders:   h.projectOrders(r, orders),
+		NotFound: notFound,
+	})
+}
+
+// uniqueIDs убирает повторы, сохраняя порядок запроса
+func uniqueIDs(ids []uuid.UUID) []uuid.UUID {
+	unique := make([]uuid.UUID, 0, len(ids))
+	seen := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// bulkStatusConflictResponse представляет ответ с ошибкой на отклоненное массовое изменение статуса
+// вместе с результатом проверки каждого заказа
+type bulkStatusConflictResponse struct {
+	ErrorResponse
+	models.BulkUpdateOrderStatusResponse
+}
+
+// maxBulkStatusOrderIDs максимальное количество заказов в одном массовом изменении статуса
+const maxBulkStatusOrderIDs = 100
+
+// BulkUpdateOrderStatus переводит несколько заказов в один статус в одной транзакции.
+// Если хотя бы один заказ не найден или не может перейти в статус, ни один заказ не изменяется
+// и возвращается 409 с результатом проверки каждого заказа
+func (h *OrderHandler) BulkUpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.BulkUpdateOrderStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeErrorResponse(w, err)
+		return
+	}
+
+	if len(req.OrderIDs) == 0 {
+		writeErrorResponse(w, http.StatusBadRequest, "Order IDs are required")
+		return
+	}
+	if len(req.OrderIDs) > maxBulkStatusOrderIDs {
+		writeErrorResponsef(w, http.StatusBadRequest, "Cannot update more than %d orders at once", maxBulkStatusOrderIDs)
+		return
+	}
+
+	update := &models.UpdateOrderStatusRequest{
+		Status:        req.Status,
+		Reason:        req.Reason,
+		ReasonComment: req.ReasonComment,
+	}
+	if err := h.validateUpdateOrderStatusRequest(update); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	results, applied, err := h.orderService.BulkUpdateOrderStatus(uniqueIDs(req.OrderIDs), update)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to update order statuses")
+		return
+	}
+
+	response := models.BulkUpdateOrderStatusResponse{Applied: applied, Status: req.Status, Results: results}
+	if !applied {
+		message, language := localize(w, "Some orders cannot change status, no orders were updated")
+		w.Header().Set("Content-Language", language)
+		writeJSONResponse(w, http.StatusConflict, bulkStatusConflictResponse{
+			ErrorResponse: ErrorResponse{
+				Error:   http.StatusText(http.StatusConflict),
+				Code:    models.ErrorCodeConflict,
+				Message: message,
+			},
+			BulkUpdateOrderStatusResponse: response,
+		})
+		return
+	}
+
+	cacheKeys := make([]string, 0, len(results))
+	for _, result := range results {
+		if err := h.producer.PublishOrderStatusChanged(r.Context(), result.OrderID, result.OldStatus, req.Status, result.CourierID); err != nil {
+			h.log.WithError(err).WithField("order_id", result.OrderID).Error("Failed to publish order status changed event")
+		}
+
+		if req.Status == models.OrderStatusCancelled {
+			var reason models.CancellationReason
+			if req.Reason != nil {
+				reason = *req.Reason
+			}
+			if err := h.producer.PublishOrderCancelled(r.Context(), result.OrderID, reason, req.ReasonComment); err != nil {
+				h.log.WithError(err).WithField("order_id", result.OrderID).Error("Failed to publish order cancelled event")
+			}
+		}
+
+		cacheKeys = append(cacheKeys, redis.GenerateKey(redis.KeyPrefixOrder, result.OrderID.String()))
+	}
+
+	if err := h.cacheService.Delete(r.Context(), cacheKeys...); err != nil {
+		h.log.WithError(err).Error("Failed to invalidate order cache")
+	}
+
+	h.log.WithField("orders", len(results)).WithField("new_status", req.Status).Info("Order statuses updated in bulk")
+	writeJSONResponse(w, http.StatusOK, response)
 }
 
 // GetOrder получает заказ по ID
@@ -94,30 +311,55 @@ func (h *OrderHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
 	// Попытка получить из кеша
 	cacheKey := redis.GenerateKey(redis.KeyPrefixOrder, orderID.String())
 	var order models.Order
-	if err := h.redisClient.Get(r.Context(), cacheKey, &order); err == nil {
+	if err := h.cacheService.Get(r.Context(), cacheKey, &order); err == nil {
 		h.log.WithField("order_id", orderID).Debug("Order retrieved from cache")
-		writeJSONResponse(w, http.StatusOK, &order)
+		writeOrderWithETag(w, r, &order, h.projectOrder(r, &order, orderRepresentation(r, &order)))
 		return
 	}
 
 	// Получение из базы данных
 	orderPtr, err := h.orderService.GetOrder(orderID)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			writeErrorResponse(w, http.StatusNotFound, "Order not found")
-		} else {
-			h.log.WithError(err).Error("Failed to get order")
-			writeErrorResponse(w, http.StatusInternalServerError, "Failed to get order")
-		}
+		writeServiceError(w, h.log, err, "Failed to get order")
 		return
 	}
 
 	// Кеширование заказа
-	if err := h.redisClient.Set(r.Context(), cacheKey, orderPtr, defaultCacheTTL); err != nil {
+	if err := h.cacheService.Set(r.Context(), cacheKey, orderPtr); err != nil {
 		h.log.WithError(err).Error("Failed to cache order")
 	}
 
-	writeJSONResponse(w, http.StatusOK, orderPtr)
+	writeOrderWithETag(w, r, orderPtr, h.projectOrder(r, orderPtr, orderRepresentation(r, orderPtr)))
+}
+
+// orderRepresentation возвращает тело ответа с заказом: с расшифровкой стоимости
+// при include=breakdown, иначе сам заказ
+func orderRepresentation(r *http.Request, order *models.Order) interface{} {
+	if r.URL.Query().Get("include") != "breakdown" {
+		return order
+	}
+	return &models.OrderDetails{Order: *order, Breakdown: order.CostBreakdown()}
+}
+
+// writeOrderWithETag отправляет представление заказа body с заголовком ETag заказа
+// или 304, если версия у клиента актуальна
+func writeOrderWithETag(w http.ResponseWriter, r *http.Request, order *models.Order, body interface{}) {
+	etag := orderETag(order)
+	w.Header().Set("ETag", etag)
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, body)
+}
+
+// orderETag вычисляет слабый ETag заказа по его версии, статусу и времени последнего изменения
+func orderETag(order *models.Order) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%d:%s:%d",
+		order.ID, order.Version, order.Status, order.UpdatedAt.UnixNano())))
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
 }
 
 // UpdateOrderStatus обновляет статус заказа
@@ -135,42 +377,63 @@ func (h *OrderHandler) UpdateOrderStatus(w http.ResponseWriter, r *http.Request)
 
 	var req models.UpdateOrderStatusRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		writeDecodeErrorResponse(w, err)
+		return
+	}
+
+	// Валидация запроса
+	if err := h.validateUpdateOrderStatusRequest(&req); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	// Получение текущего заказа для определения старого статуса
 	currentOrder, err := h.orderService.GetOrder(orderID)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			writeErrorResponse(w, http.StatusNotFound, "Order not found")
-		} else {
-			writeErrorResponse(w, http.StatusInternalServerError, "Failed to get order")
-		}
+		writeServiceError(w, h.log, err, "Failed to get order")
 		return
 	}
 
 	oldStatus := currentOrder.Status
 
+	// Ожидаемая версия берется из тела запроса или из заголовка If-Match
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && req.Version == nil {
+		if !etagMatches(ifMatch, orderETag(currentOrder)) {
+			writeErrorResponse(w, http.StatusConflict, "Order was modified concurrently")
+			return
+		}
+		req.Version = &currentOrder.Version
+	}
+	if req.Version == nil {
+		writeErrorResponse(w, http.StatusPreconditionRequired, "Order version is required: send version or If-Match header")
+		return
+	}
+
 	// Обновление статуса
 	if err := h.orderService.UpdateOrderStatus(orderID, &req); err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			writeErrorResponse(w, http.StatusNotFound, "Order not found")
-		} else {
-			h.log.WithError(err).Error("Failed to update order status")
-			writeErrorResponse(w, http.StatusInternalServerError, "Failed to update order status")
-		}
+		writeServiceError(w, h.log, err, "Failed to update order status")
 		return
 	}
 
 	// Публикация события изменения статуса
-	if err := h.producer.PublishOrderStatusChanged(orderID, oldStatus, req.Status, req.CourierID); err != nil {
+	if err := h.producer.PublishOrderStatusChanged(r.Context(), orderID, oldStatus, req.Status, req.CourierID); err != nil {
 		h.log.WithError(err).Error("Failed to publish order status changed event")
 	}
 
+	// Отдельное событие отмены для потребителей (возвраты, освобождение остатков)
+	if req.Status == models.OrderStatusCancelled {
+		var reason models.CancellationReason
+		if req.Reason != nil {
+			reason = *req.Reason
+		}
+		if err := h.producer.PublishOrderCancelled(r.Context(), orderID, reason, req.ReasonComment); err != nil {
+			h.log.WithError(err).Error("Failed to publish order cancelled event")
+		}
+	}
+
 	// Инвалидация кеша
 	cacheKey := redis.GenerateKey(redis.KeyPrefixOrder, orderID.String())
-	if err := h.redisClient.Delete(r.Context(), cacheKey); err != nil {
+	if err := h.cacheService.Delete(r.Context(), cacheKey); err != nil {
 		h.log.WithError(err).Error("Failed to invalidate order cache")
 	}
 
@@ -178,6 +441,304 @@ func (h *OrderHandler) UpdateOrderStatus(w http.ResponseWriter, r *http.Request)
 	writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Order status updated successfully"})
 }
 
+// GetOrderTimeline возвращает хронологию заказа: смены статусов, назначения курьеров,
+// местоположения курьера и подтверждение доставки в порядке времени
+func (h *OrderHandler) GetOrderTimeline(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	orderID, err := extractUUIDFromPath(r.URL.Path, "/api/orders/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	timeline, err := h.orderService.GetOrderTimeline(orderID)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to get order timeline")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, timeline)
+}
+
+// MarkOrderReady отмечает заказ готовым к выдаче курьеру
+func (h *OrderHandler) MarkOrderReady(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	orderID, err := extractUUIDFromPath(r.URL.Path, "/api/orders/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	order, err := h.orderService.MarkOrderReady(orderID)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to mark order ready")
+		return
+	}
+
+	// Публикация события изменения статуса
+	if err := h.producer.PublishOrderStatusChanged(r.Context(), orderID, order.Status, models.OrderStatusReady, order.CourierID); err != nil {
+		h.log.WithError(err).Error("Failed to publish order status changed event")
+	}
+
+	// Отдельное событие для приложения курьера
+	if err := h.producer.PublishOrderReadyForPickup(r.Context(), order); err != nil {
+		h.log.WithError(err).Error("Failed to publish order ready for pickup event")
+	}
+
+	// Инвалидация кеша
+	cacheKey := redis.GenerateKey(redis.KeyPrefixOrder, orderID.String())
+	if err := h.cacheService.Delete(r.Context(), cacheKey); err != nil {
+		h.log.WithError(err).Error("Failed to invalidate order cache")
+	}
+
+	h.log.WithField("order_id", orderID).Info("Order marked ready for pickup")
+	writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Order marked ready successfully"})
+}
+
+// SubmitDeliveryProof принимает подтверждение доставки и завершает заказ
+func (h *OrderHandler) SubmitDeliveryProof(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	orderID, err := extractUUIDFromPath(r.URL.Path, "/api/orders/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	var req models.SubmitDeliveryProofRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeErrorResponse(w, err)
+		return
+	}
+
+	if err := validateSubmitDeliveryProofRequest(&req); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	order, proof, err := h.orderService.SubmitDeliveryProof(orderID, &req)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to submit delivery proof")
+		return
+	}
+
+	// Публикация события изменения статуса
+	if err := h.producer.PublishOrderStatusChanged(r.Context(), orderID, order.Status, models.OrderStatusDelivered, order.CourierID); err != nil {
+		h.log.WithError(err).Error("Failed to publish order status changed event")
+	}
+
+	// Инвалидация кеша
+	cacheKey := redis.GenerateKey(redis.KeyPrefixOrder, orderID.String())
+	if err := h.cacheService.Delete(r.Context(), cacheKey); err != nil {
+		h.log.WithError(err).Error("Failed to invalidate order cache")
+	}
+
+	h.log.WithField("order_id", orderID).Info("Order delivered with proof")
+	writeJSONResponse(w, http.StatusCreated, proof)
+}
+
+// UnassignOrder снимает курьера с заказа и возвращает заказ в пул
+func (h *OrderHandler) UnassignOrder(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	orderID, err := extractUUIDFromPath(r.URL.Path, "/api/orders/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	oldStatus, courierID, err := h.orderService.UnassignOrder(orderID)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to unassign order")
+		return
+	}
+
+	// Публикация события изменения статуса
+	if err := h.producer.PublishOrderStatusChanged(r.Context(), orderID, oldStatus, models.OrderStatusCreated, nil); err != nil {
+		h.log.WithError(err).Error("Failed to publish order status changed event")
+	}
+
+	// Инвалидация кеша заказа и курьера
+	orderCacheKey := redis.GenerateKey(redis.KeyPrefixOrder, orderID.String())
+	courierCacheKey := redis.GenerateKey(redis.KeyPrefixCourier, courierID.String())
+
+	h.cacheService.Delete(r.Context(), orderCacheKey)
+	h.cacheService.Delete(r.Context(), courierCacheKey)
+
+	h.log.WithField("order_id", orderID).WithField("courier_id", courierID).Info("Order unassigned")
+	writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Order unassigned successfully"})
+}
+
+// RemoveOrderItem удаляет позицию из заказа
+func (h *OrderHandler) RemoveOrderItem(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	orderID, err := extractUUIDFromPath(r.URL.Path, "/api/orders/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	itemID, err := extractUUIDFromPath(r.URL.Path, "/api/orders/"+orderID.String()+"/items/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid item ID")
+		return
+	}
+
+	oldAmount, newAmount, err := h.orderService.RemoveOrderItem(orderID, itemID)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to remove order item")
+		return
+	}
+
+	// Публикация события изменения суммы заказа
+	if err := h.producer.PublishOrderAmountChanged(r.Context(), orderID, oldAmount, newAmount); err != nil {
+		h.log.WithError(err).Error("Failed to publish order amount changed event")
+	}
+
+	// Инвалидация кеша
+	cacheKey := redis.GenerateKey(redis.KeyPrefixOrder, orderID.String())
+	if err := h.cacheService.Delete(r.Context(), cacheKey); err != nil {
+		h.log.WithError(err).Error("Failed to invalidate order cache")
+	}
+
+	h.log.WithField("order_id", orderID).WithField("item_id", itemID).Info("Order item removed")
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"message":      "Order item removed successfully",
+		"total_amount": newAmount,
+	})
+}
+
+// RecalculateDeliveryCost пересчитывает стоимость доставки заказа по текущим тарифам
+func (h *OrderHandler) RecalculateDeliveryCost(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	orderID, err := extractUUIDFromPath(r.URL.Path, "/api/orders/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	oldCost, newCost, err := h.orderService.RecalculateDeliveryCost(orderID)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to recalculate delivery cost")
+		return
+	}
+
+	// Инвалидация кеша
+	cacheKey := redis.GenerateKey(redis.KeyPrefixOrder, orderID.String())
+	if err := h.cacheService.Delete(r.Context(), cacheKey); err != nil {
+		h.log.WithError(err).Error("Failed to invalidate order cache")
+	}
+
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"message":                "Delivery cost recalculated successfully",
+		"delivery_cost":          newCost,
+		"previous_delivery_cost": oldCost,
+	})
+}
+
+// UpdateOrderItem меняет количество или цену одной позиции заказа
+func (h *OrderHandler) UpdateOrderItem(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	orderID, err := extractUUIDFromPath(r.URL.Path, "/api/orders/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	itemID, err := extractUUIDFromPath(r.URL.Path, "/api/orders/"+orderID.String()+"/items/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid item ID")
+		return
+	}
+
+	var req models.UpdateOrderItemRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeErrorResponse(w, err)
+		return
+	}
+
+	if err := h.validateUpdateOrderItemRequest(&req); err != nil {
+		writeValidationErrorResponse(w, r, h.log, err)
+		return
+	}
+
+	item, oldAmount, newAmount, err := h.orderService.UpdateOrderItem(orderID, itemID, &req)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to update order item")
+		return
+	}
+
+	// Публикация события изменения суммы заказа
+	if oldAmount != newAmount {
+		if err := h.producer.PublishOrderAmountChanged(r.Context(), orderID, oldAmount, newAmount); err != nil {
+			h.log.WithError(err).Error("Failed to publish order amount changed event")
+		}
+	}
+
+	// Инвалидация кеша
+	cacheKey := redis.GenerateKey(redis.KeyPrefixOrder, orderID.String())
+	if err := h.cacheService.Delete(r.Context(), cacheKey); err != nil {
+		h.log.WithError(err).Error("Failed to invalidate order cache")
+	}
+
+	h.log.WithField("order_id", orderID).WithField("item_id", itemID).Info("Order item updated")
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"item":         item,
+		"total_amount": newAmount,
+	})
+}
+
+// validateUpdateOrderItemRequest проверяет изменение позиции по тем же ограничениям, что и при создании заказа
+func (h *OrderHandler) validateUpdateOrderItemRequest(req *models.UpdateOrderItemRequest) error {
+	verr := &ValidationError{}
+
+	if req.Quantity == nil && req.Price == nil {
+		verr.Add("quantity", "quantity or price is required")
+	}
+	if req.Quantity != nil {
+		if *req.Quantity <= 0 {
+			verr.Add("quantity", "quantity must be positive")
+		} else if h.cfg.MaxQuantityPerItem > 0 && *req.Quantity > h.cfg.MaxQuantityPerItem {
+			verr.Add("quantity", "quantity cannot exceed %d", h.cfg.MaxQuantityPerItem)
+		}
+	}
+	if req.Price != nil {
+		if *req.Price < 0 {
+			verr.Add("price", "price cannot be negative")
+		} else if h.cfg.MaxItemPriceCents > 0 && req.Price.Cents() > int64(h.cfg.MaxItemPriceCents) {
+			verr.Add("price", "price cannot exceed %s", models.Money(h.cfg.MaxItemPriceCents))
+		}
+	}
+
+	return verr.Err()
+}
+
 // GetOrders получает список заказов с фильтрацией
 func (h *OrderHandler) GetOrders(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -187,73 +748,312 @@ func (h *OrderHandler) GetOrders(w http.ResponseWriter, r *http.Request) {
 
 	query := r.URL.Query()
 
+	// Пресет задает набор фильтров и сортировку, явные параметры запроса имеют приоритет
+	filter := &models.OrderFilter{}
+	if presetName := query.Get("preset"); presetName != "" {
+		preset, ok := orderPresets[presetName]
+		if !ok {
+			writeErrorResponsef(w, http.StatusBadRequest, "Unknown preset: %s", presetName)
+			return
+		}
+		preset.apply(filter, time.Now())
+	}
+
 	// Парсинг параметров фильтрации
-	var status *models.OrderStatus
 	if statusStr := query.Get("status"); statusStr != "" {
-		s := models.OrderStatus(statusStr)
-		status = &s
+		filter.Statuses = []models.OrderStatus{models.OrderStatus(statusStr)}
 	}
 
-	var courierID *uuid.UUID
 	if courierIDStr := query.Get("courier_id"); courierIDStr != "" {
 		id, err := uuid.Parse(courierIDStr)
 		if err != nil {
 			writeErrorResponse(w, http.StatusBadRequest, "Invalid courier ID")
 			return
 		}
-		courierID = &id
+		filter.CourierID = &id
+		filter.Unassigned = false
 	}
 
-	limit := 50 // По умолчанию
-	if limitStr := query.Get("limit"); limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
-			limit = l
+	// Фильтр заказов с нарушенным сроком доставки
+	if sla := query.Get("sla"); sla != "" {
+		if sla != "breached" {
+			writeErrorResponse(w, http.StatusBadRequest, "Invalid sla filter: expected breached")
+			return
 		}
+		filter.SLABreached = true
 	}
 
-	offset := 0
-	if offsetStr := query.Get("offset"); offsetStr != "" {
-		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
-			offset = o
+	limit, offset := parsePagination(query, h.pageCfg)
+
+	filter.Search = sanitizeSearchQuery(query.Get("q"))
+	if filter.Search != "" {
+		if utf8.RuneCountInString(filter.Search) < minOrderSearchLength {
+			writeErrorResponsef(w, http.StatusBadRequest, "Search query must be at least %d characters long", minOrderSearchLength)
+			return
 		}
+		if limit > maxOrderSearchResults {
+			limit = maxOrderSearchResults
+		}
+		// Результаты поиска по умолчанию упорядочены по релевантности, а не по сортировке пресета
+		filter.Sort = ""
+	}
+
+	if sortStr := query.Get("sort"); sortStr != "" {
+		sort := models.OrderSort(sortStr)
+		if !sort.IsValid() {
+			writeErrorResponsef(w, http.StatusBadRequest, "Invalid sort: expected %s or %s", models.OrderSortCreatedDesc, models.OrderSortCreatedAsc)
+			return
+		}
+		filter.Sort = sort
 	}
 
-	orders, err := h.orderService.GetOrders(status, courierID, limit, offset)
+	orders, err := h.orderService.GetOrders(filter, limit, offset)
 	if err != nil {
 		h.log.WithError(err).Error("Failed to get orders")
 		writeErrorResponse(w, http.StatusInternalServerError, "Failed to get orders")
 		return
 	}
 
-	writeJSONResponse(w, http.StatusOK, orders)
+	entries := make([]listEntry, len(orders))
+	for i, order := range orders {
+		entries[i] = listEntry{id: order.ID, updatedAt: order.UpdatedAt}
+	}
+
+	setPaginationHeaders(w, limit, offset)
+	writeConditionalList(w, r, h.projectOrders(r, orders), entries)
+}
+
+// GetUnassignedOrders возвращает очередь диспетчера: созданные заказы без курьера, начиная с самых старых,
+// с временем ожидания каждого заказа. Время ожидания меняется с каждым запросом, поэтому ответ не кешируется
+func (h *OrderHandler) GetUnassignedOrders(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	limit, offset := parsePagination(r.URL.Query(), h.pageCfg)
+
+	orders, err := h.orderService.GetUnassignedOrders(limit, offset)
+	if err != nil {
+		h.log.WithError(err).Error("Failed to get unassigned orders")
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to get unassigned orders")
+		return
+	}
+
+	now := time.Now()
+	queue := make([]interface{}, len(orders))
+	for i, order := range orders {
+		queue[i] = h.projectOrder(r, order, &models.UnassignedOrder{
+			Order:       *order,
+			WaitSeconds: max(int64(now.Sub(order.CreatedAt)/time.Second), 0),
+		})
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	setPaginationHeaders(w, limit, offset)
+	writeJSONResponse(w, http.StatusOK, queue)
+}
+
+// orderPreset представляет именованный набор фильтров и сортировки списка заказов
+type orderPreset struct {
+	statuses     []models.OrderStatus
+	unassigned   bool
+	createdToday bool
+	sort         models.OrderSort
+}
+
+// orderPresets пресеты списка заказов для типовых представлений диспетчера (?preset=<имя>)
+var orderPresets = map[string]orderPreset{
+	// Заказы, ожидающие назначения курьера, начиная с самых старых
+	"unassigned": {
+		statuses:   []models.OrderStatus{models.OrderStatusCreated, models.OrderStatusAccepted, models.OrderStatusPreparing, models.OrderStatusReady},
+		unassigned: true,
+		sort:       models.OrderSortCreatedAsc,
+	},
+	// Заказы, созданные с начала текущих суток
+	"today": {
+		createdToday: true,
+		sort:         models.OrderSortCreatedDesc,
+	},
+	// Незавершенные заказы, начиная с самых старых
+	"active": {
+		statuses: []models.OrderStatus{models.OrderStatusCreated, models.OrderStatusAccepted, models.OrderStatusPreparing,
+			models.OrderStatusReady, models.OrderStatusInDelivery},
+		sort: models.OrderSortCreatedAsc,
+	},
+}
+
+// apply заполняет фильтр условиями пресета
+func (p orderPreset) apply(filter *models.OrderFilter, now time.Time) {
+	filter.Statuses = p.statuses
+	filter.Unassigned = p.unassigned
+	filter.Sort = p.sort
+	if p.createdToday {
+		startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		filter.CreatedFrom = &startOfDay
+	}
+}
+
+// Ограничения поиска заказов по строке
+const (
+	minOrderSearchLength  = 2
+	maxOrderSearchLength  = 100
+	maxOrderSearchResults = 50
+)
+
+// sanitizeSearchQuery удаляет управляющие символы, схлопывает пробелы
+// и обрезает строку поиска до maxOrderSearchLength символов
+func sanitizeSearchQuery(value string) string {
+	value = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, value)
+	value = strings.Join(strings.Fields(value), " ")
+
+	if runes := []rune(value); len(runes) > maxOrderSearchLength {
+		value = strings.TrimSpace(string(runes[:maxOrderSearchLength]))
+	}
+	return value
 }
 
-// validateCreateOrderRequest валидирует запрос на создание заказа
+// maxSKULength максимальная длина SKU товара каталога
+const maxSKULength = 64
+
+// validateCreateOrderRequest валидирует запрос на создание заказа, собирая все ошибки,
+// и нормализует адрес доставки
 func (h *OrderHandler) validateCreateOrderRequest(req *models.CreateOrderRequest) error {
+	verr := &ValidationError{}
+
 	if req.CustomerName == "" {
-		return fmt.Errorf("customer name is required")
+		verr.Add("customer_name", "customer name is required")
 	}
 	if req.CustomerPhone == "" {
-		return fmt.Errorf("customer phone is required")
+		verr.Add("customer_phone", "customer phone is required")
 	}
-	if req.DeliveryAddress == "" {
-		return fmt.Errorf("delivery address is required")
+	if address, err := models.NormalizeAddress(req.DeliveryAddress); err != nil {
+		verr.Add("delivery_address", "%s", err.Error())
+	} else {
+		req.DeliveryAddress = address
+	}
+	if (req.PickupLat == nil) != (req.PickupLon == nil) {
+		verr.Add("pickup_lat", "pickup_lat and pickup_lon must be provided together")
+	} else if req.PickupLat != nil {
+		if !geo.ValidLat(*req.PickupLat) {
+			verr.Add("pickup_lat", "pickup_lat must be between -90 and 90")
+		}
+		if !geo.ValidLon(*req.PickupLon) {
+			verr.Add("pickup_lon", "pickup_lon must be between -180 and 180")
+		}
+	}
+	if (req.DeliveryLat == nil) != (req.DeliveryLon == nil) {
+		verr.Add("delivery_lat", "delivery_lat and delivery_lon must be provided together")
+	} else if req.DeliveryLat != nil {
+		if !geo.ValidLat(*req.DeliveryLat) {
+			verr.Add("delivery_lat", "delivery_lat must be between -90 and 90")
+		}
+		if !geo.ValidLon(*req.DeliveryLon) {
+			verr.Add("delivery_lon", "delivery_lon must be between -180 and 180")
+		}
+	}
+	if err := models.ValidateSkills(req.RequiredSkills); err != nil {
+		verr.Add("required_skills", "%s", err.Error())
 	}
 	if len(req.Items) == 0 {
-		return fmt.Errorf("order items are required")
+		verr.Add("items", "order items are required")
+	}
+	if h.cfg.MaxItemsPerOrder > 0 && len(req.Items) > h.cfg.MaxItemsPerOrder {
+		verr.Add("items", "order cannot contain more than %d items", h.cfg.MaxItemsPerOrder)
+		// Не проверяем каждую позицию слишком большого заказа
+		return verr.Err()
 	}
 
 	for i, item := range req.Items {
-		if item.Name == "" {
-			return fmt.Errorf("item %d: name is required", i+1)
+		if len(item.SKU) > maxSKULength {
+			verr.Add(fmt.Sprintf("items[%d].sku", i), "sku cannot be longer than %d characters", maxSKULength)
+		}
+		if item.Name == "" && item.SKU == "" {
+			verr.Add(fmt.Sprintf("items[%d].name", i), "name is required")
 		}
 		if item.Quantity <= 0 {
-			return fmt.Errorf("item %d: quantity must be positive", i+1)
+			verr.Add(fmt.Sprintf("items[%d].quantity", i), "quantity must be positive")
+		} else if h.cfg.MaxQuantityPerItem > 0 && item.Quantity > h.cfg.MaxQuantityPerItem {
+			verr.Add(fmt.Sprintf("items[%d].quantity", i), "quantity cannot exceed %d", h.cfg.MaxQuantityPerItem)
+		}
+		if item.SKU != "" {
+			// Цена и вес позиции из каталога берутся из него, а не из запроса
+			continue
+		}
+		if item.WeightGrams < 0 {
+			verr.Add(fmt.Sprintf("items[%d].weight_grams", i), "weight cannot be negative")
 		}
 		if item.Price < 0 {
-			return fmt.Errorf("item %d: price cannot be negative", i+1)
+			verr.Add(fmt.Sprintf("items[%d].price", i), "price cannot be negative")
+		} else if h.cfg.MaxItemPriceCents > 0 && item.Price.Cents() > int64(h.cfg.MaxItemPriceCents) {
+			verr.Add(fmt.Sprintf("items[%d].price", i), "price cannot exceed %s", models.Money(h.cfg.MaxItemPriceCents))
 		}
 	}
 
+	return verr.Err()
+}
+
+// maxProofRefLength максимальная длина ссылки на изображение подтверждения доставки
+const maxProofRefLength = 2048
+
+// validateSubmitDeliveryProofRequest проверяет ссылки на фото и подпись
+func validateSubmitDeliveryProofRequest(req *models.SubmitDeliveryProofRequest) error {
+	if err := validateProofRef("photo_ref", req.PhotoRef); err != nil {
+		return err
+	}
+	if req.SignatureRef != nil {
+		if err := validateProofRef("signature_ref", *req.SignatureRef); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateProofRef проверяет ссылку на загруженное изображение: URL или ключ в хранилище без пробелов
+func validateProofRef(field, value string) error {
+	if value == "" {
+		return fmt.Errorf("%s is required", field)
+	}
+	if len(value) > maxProofRefLength {
+		return fmt.Errorf("%s must not exceed %d characters", field, maxProofRefLength)
+	}
+	if strings.IndexFunc(value, unicode.IsSpace) >= 0 {
+		return fmt.Errorf("%s must not contain whitespace", field)
+	}
+	return nil
+}
+
+// validateUpdateOrderStatusRequest валидирует запрос на обновление статуса заказа: неизвестные
+// и не разрешенные клиентам (ORDER_CLIENT_STATUSES) статусы отклоняются до обращения к сервису
+func (h *OrderHandler) validateUpdateOrderStatusRequest(req *models.UpdateOrderStatusRequest) error {
+	if !req.Status.IsValid() {
+		return fmt.Errorf("unknown order status: %q", req.Status)
+	}
+	if len(h.cfg.ClientStatuses) > 0 && !slices.Contains(h.cfg.ClientStatuses, string(req.Status)) {
+		return fmt.Errorf("order status %s cannot be set by clients", req.Status)
+	}
+
+	if req.Reason == nil {
+		if req.ReasonComment != "" {
+			return fmt.Errorf("reason comment requires a reason")
+		}
+		return nil
+	}
+
+	if req.Status != models.OrderStatusCancelled {
+		return fmt.Errorf("reason is only allowed when cancelling an order")
+	}
+	if !req.Reason.IsValid() {
+		return fmt.Errorf("unknown cancellation reason: %s", *req.Reason)
+	}
+	if *req.Reason == models.CancellationReasonOther && strings.TrimSpace(req.ReasonComment) == "" {
+		return fmt.Errorf("reason comment is required for reason %q", models.CancellationReasonOther)
+	}
+
 	return nil
 }
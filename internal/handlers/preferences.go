@@ -0,0 +1,30 @@
+package handlers
+
+import "net/http"
+
+// preferencesWriter передает в функции записи ответа параметры, выбранные middleware
+// по заголовкам запроса: стиль ключей JSON и язык сообщений об ошибках
+type preferencesWriter struct {
+	http.ResponseWriter
+	jsonCase string
+	language string
+}
+
+// withPreferences возвращает preferencesWriter для w, создавая его, если w еще не обернут
+func withPreferences(w http.ResponseWriter) *preferencesWriter {
+	if pw, ok := w.(*preferencesWriter); ok {
+		return pw
+	}
+	return &preferencesWriter{ResponseWriter: w}
+}
+
+// responsePreferences возвращает параметры ответа или nil, если middleware их не задавали
+func responsePreferences(w http.ResponseWriter) *preferencesWriter {
+	pw, _ := w.(*preferencesWriter)
+	return pw
+}
+
+// Unwrap возвращает исходный ResponseWriter для http.ResponseController
+func (w *preferencesWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
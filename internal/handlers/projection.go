@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"unicode"
+
+	"delivery-system/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// projectOrder возвращает представление заказа body для роли клиента API: поля из списка скрытых
+// для роли (ORDER_COURIER_HIDDEN_FIELDS, ORDER_CUSTOMER_HIDDEN_FIELDS) удаляются, а курьер видит
+// телефон клиента полностью, только если заказ назначен ему. Без роли body возвращается без изменений
+func (h *OrderHandler) projectOrder(r *http.Request, order *models.Order, body interface{}) interface{} {
+	role, ok := RoleFromContext(r.Context())
+	if !ok {
+		return body
+	}
+
+	var hidden []string
+	switch role {
+	case RoleCourier:
+		hidden = h.cfg.CourierHiddenFields
+	case RoleCustomer:
+		hidden = h.cfg.CustomerHiddenFields
+	}
+	maskPhone := role == RoleCourier && !assignedToViewer(r, order)
+	if len(hidden) == 0 && !maskPhone {
+		return body
+	}
+
+	fields, err := toJSONObject(body)
+	if err != nil {
+		// Представление заказа всегда кодируется в JSON-объект; при ошибке безопаснее скрыть заказ целиком
+		h.log.WithError(err).WithField("order_id", order.ID).Error("Failed to project order")
+		return map[string]interface{}{"id": order.ID}
+	}
+
+	for _, field := range hidden {
+		delete(fields, field)
+	}
+	if _, ok := fields["customer_phone"]; ok && maskPhone {
+		fields["customer_phone"] = maskPhoneNumber(order.CustomerPhone)
+	}
+	return fields
+}
+
+// projectOrders применяет projectOrder к каждому заказу списка
+func (h *OrderHandler) projectOrders(r *http.Request, orders []*models.Order) interface{} {
+	if _, ok := RoleFromContext(r.Context()); !ok {
+		return orders
+	}
+
+	projected := make([]interface{}, len(orders))
+	for i, order := range orders {
+		projected[i] = h.projectOrder(r, order, order)
+	}
+	return projected
+}
+
+// assignedToViewer сообщает, что заказ назначен курьеру, выполняющему запрос
+func assignedToViewer(r *http.Request, order *models.Order) bool {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok || order.CourierID == nil {
+		return false
+	}
+	courierID, err := uuid.Parse(userID)
+	return err == nil && courierID == *order.CourierID
+}
+
+// toJSONObject кодирует value в JSON и возвращает его как объект. Числа сохраняются без потери точности
+func toJSONObject(value interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// maskPhoneNumber заменяет звездочками все цифры телефона, кроме двух последних
+func maskPhoneNumber(phone string) string {
+	remaining := 0
+	for _, r := range phone {
+		if unicode.IsDigit(r) {
+			remaining++
+		}
+	}
+
+	masked := []rune(phone)
+	for i, r := range masked {
+		if !unicode.IsDigit(r) {
+			continue
+		}
+		if remaining > 2 {
+			masked[i] = '*'
+		}
+		remaining--
+	}
+	return string(masked)
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"delivery-system/internal/config"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/services"
+)
+
+// RateLimitMiddleware ограничивает частоту запросов клиентов
+type RateLimitMiddleware struct {
+	rateLimiter    *services.RateLimiterService
+	cfg            *config.RateLimitConfig
+	trustedProxies trustedProxies
+	log            *logger.Logger
+}
+
+// NewRateLimitMiddleware создает новый middleware ограничения частоты запросов. Адрес клиента
+// из X-Forwarded-For и X-Real-IP принимается только от прокси из trustedProxies
+func NewRateLimitMiddleware(rateLimiter *services.RateLimiterService, cfg *config.RateLimitConfig, trustedProxies []*net.IPNet, log *logger.Logger) *RateLimitMiddleware {
+	return &RateLimitMiddleware{
+		rateLimiter:    rateLimiter,
+		cfg:            cfg,
+		trustedProxies: trustedProxies,
+		log:            log,
+	}
+}
+
+// Wrap оборачивает обработчик проверкой лимита запросов
+func (m *RateLimitMiddleware) Wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		result, err := m.rateLimiter.CheckLimit(r.Context(), m.trustedProxies.rateLimitIdentifier(r))
+		if err != nil {
+			// При недоступности Redis пропускаем запрос, чтобы не блокировать сервис
+			m.log.WithError(err).Error("Failed to check rate limit")
+			next(w, r)
+			return
+		}
+
+		setRateLimitHeaders(w, result)
+
+		if !result.Allowed {
+			// Retry-After нужен и при блокировке, и при простом превышении лимита в окне
+			w.Header().Set("Retry-After", m.retryAfter(result.ResetAt))
+			writeErrorResponse(w, http.StatusTooManyRequests, "Rate limit exceeded")
+			return
+		}
+
+		// Предупреждаем клиента заранее, пока запросы еще не блокируются
+		if m.nearLimit(result) {
+			w.Header().Set("X-RateLimit-Warning", "true")
+			w.Header().Set("Warning", fmt.Sprintf(`199 - "Rate limit nearly exhausted: %d of %d requests remaining"`,
+				result.Remaining, result.Limit))
+		}
+
+		m.limitConcurrency(w, r, next)
+	}
+}
+
+// limitConcurrency выполняет запрос, если клиент с этого IP не превысил MaxConcurrentPerIP
+// одновременных запросов, и отвечает 429 в противном случае
+func (m *RateLimitMiddleware) limitConcurrency(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if m.cfg.MaxConcurrentPerIP <= 0 {
+		next(w, r)
+		return
+	}
+
+	ip := m.trustedProxies.clientIP(r)
+	acquired, err := m.rateLimiter.AcquireConcurrency(r.Context(), ip)
+	if err != nil {
+		// При недоступности Redis пропускаем запрос, как и при проверке частоты
+		m.log.WithError(err).Error("Failed to check concurrent request limit")
+		next(w, r)
+		return
+	}
+	if !acquired {
+		// Место может освободиться в любой момент, поэтому предлагаем повторить запрос через секунду
+		w.Header().Set("Retry-After", m.retryAfter(time.Now().Add(time.Second)))
+		writeErrorResponse(w, http.StatusTooManyRequests, "Too many concurrent requests")
+		return
+	}
+
+	defer func() {
+		// Контекст запроса к этому моменту может быть отменен, а место нужно освободить в любом случае
+		if err := m.rateLimiter.ReleaseConcurrency(context.Background(), ip); err != nil {
+			m.log.WithError(err).Error("Failed to release concurrent request slot")
+		}
+	}()
+	next(w, r)
+}
+
+// retryAfter форматирует значение заголовка Retry-After: число секунд (не меньше одной)
+// или HTTP-дата, в зависимости от настройки RetryAfterFormat
+func (m *RateLimitMiddleware) retryAfter(resetAt time.Time) string {
+	if m.cfg.RetryAfterFormat == config.RetryAfterFormatHTTPDate {
+		return resetAt.UTC().Format(http.TimeFormat)
+	}
+
+	seconds := int(math.Ceil(time.Until(resetAt).Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return strconv.Itoa(seconds)
+}
+
+// nearLimit сообщает, опустился ли остаток запросов ниже порога предупреждения
+func (m *RateLimitMiddleware) nearLimit(result *services.RateLimitResult) bool {
+	if m.cfg.WarningThreshold <= 0 || result.Limit <= 0 {
+		return false
+	}
+	return float64(result.Remaining) < float64(result.Limit)*m.cfg.WarningThreshold
+}
+
+// RateLimitHandler представляет обработчик информации о лимитах запросов
+type RateLimitHandler struct {
+	rateLimiter    *services.RateLimiterService
+	trustedProxies trustedProxies
+	log            *logger.Logger
+}
+
+// NewRateLimitHandler создает новый обработчик информации о лимитах запросов
+func NewRateLimitHandler(rateLimiter *services.RateLimiterService, trustedProxies []*net.IPNet, log *logger.Logger) *RateLimitHandler {
+	return &RateLimitHandler{
+		rateLimiter:    rateLimiter,
+		trustedProxies: trustedProxies,
+		log:            log,
+	}
+}
+
+// GetStatus возвращает текущее состояние лимита запросов клиента, не расходуя квоту
+func (h *RateLimitHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	result, err := h.rateLimiter.GetStatus(r.Context(), h.trustedProxies.rateLimitIdentifier(r))
+	if err != nil {
+		h.log.WithError(err).Error("Failed to get rate limit status")
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to get rate limit status")
+		return
+	}
+
+	setRateLimitHeaders(w, result)
+	writeJSONResponse(w, http.StatusOK, result)
+}
+
+// ListBans возвращает клиентов, заблокированных лимитером, с оставшимся временем блокировки
+func (h *RateLimitHandler) ListBans(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	bans, err := h.rateLimiter.GetActiveBans(r.Context())
+	if err != nil {
+		h.log.WithError(err).Error("Failed to list rate limit bans")
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to list rate limit bans")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, bans)
+}
+
+// setRateLimitHeaders устанавливает стандартные заголовки лимита запросов
+func setRateLimitHeaders(w http.ResponseWriter, result *services.RateLimitResult) {
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(result.ResetAt.Unix(), 10))
+}
+
+// rateLimitIdentifier возвращает ключ клиента для лимитера: ID пользователя
+// для аутентифицированных запросов и IP адрес для анонимных
+func (p trustedProxies) rateLimitIdentifier(r *http.Request) string {
+	if userID, ok := UserIDFromContext(r.Context()); ok {
+		return "user:" + userID
+	}
+	return "ip:" + p.clientIP(r)
+}
+
+// trustedProxies подсети прокси, которым разрешено передавать адрес клиента в заголовках
+type trustedProxies []*net.IPNet
+
+// clientIP определяет IP адрес клиента. Заголовки прокси учитываются, только если запрос пришел
+// от доверенного прокси: иначе клиент мог бы подставить любой адрес, обойти лимит или добиться
+// блокировки чужого адреса. В X-Forwarded-For берется самый правый адрес, не принадлежащий
+// доверенным прокси, так как левые элементы списка клиент может дописать сам
+func (p trustedProxies) clientIP(r *http.Request) string {
+	peer, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		peer = r.RemoteAddr
+	}
+	if !p.contains(peer) {
+		return peer
+	}
+
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		ip := peer
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if net.ParseIP(hop) == nil {
+				// Некорректный элемент мог записать только клиент: дальше списку доверять нельзя
+				break
+			}
+			ip = hop
+			if !p.contains(hop) {
+				break
+			}
+		}
+		return ip
+	}
+
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(realIP) != nil {
+		return realIP
+	}
+	return peer
+}
+
+// contains сообщает, входит ли адрес в одну из подсетей доверенных прокси
+func (p trustedProxies) contains(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range p {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader - заголовок, в котором передается идентификатор запроса
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength ограничивает длину идентификатора, принятого от клиента
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// RequestID присваивает каждому запросу идентификатор для корреляции логов.
+// Идентификатор из заголовка X-Request-ID используется как есть, иначе генерируется новый;
+// в обоих случаях он возвращается клиенту в том же заголовке
+func RequestID(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" || len(id) > maxRequestIDLength {
+			id = uuid.NewString()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		next(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	}
+}
+
+// RequestIDFromContext возвращает идентификатор запроса или пустую строку, если он не назначен
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
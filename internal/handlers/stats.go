@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+
+	"delivery-system/internal/logger"
+	"delivery-system/internal/services"
+)
+
+// StatsHandler представляет обработчик статистики
+type StatsHandler struct {
+	statsService *services.StatsService
+	log          *logger.Logger
+}
+
+// NewStatsHandler создает новый обработчик статистики
+func NewStatsHandler(statsService *services.StatsService, log *logger.Logger) *StatsHandler {
+	return &StatsHandler{
+		statsService: statsService,
+		log:          log,
+	}
+}
+
+// GetOrdersByStatus возвращает количество заказов в каждом статусе
+func (h *StatsHandler) GetOrdersByStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	counts, err := h.statsService.GetOrdersByStatus(r.Context())
+	if err != nil {
+		h.log.WithError(err).Error("Failed to get orders by status")
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to get orders by status")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, counts)
+}
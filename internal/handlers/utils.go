@@ -1,45 +1,285 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/google/uuid"
-)
+	"delivery-system/internal/config"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+	"delivery-system/internal/services"
 
-// Константы
-const (
-	defaultCacheTTL = 15 * time.Minute
+	"github.com/google/uuid"
 )
 
 // ErrorResponse представляет структуру ответа с ошибкой
 type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message"`
+	Error   string           `json:"error"`
+	Code    models.ErrorCode `json:"code"`
+	Message string           `json:"message"`
+	Errors  []FieldError     `json:"errors,omitempty"`
 }
 
 // writeJSONResponse отправляет JSON ответ
 func writeJSONResponse(w http.ResponseWriter, statusCode int, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-
-	if err := json.NewEncoder(w).Encode(data); err != nil {
+	body, err := encodeJSON(w, data)
+	if err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(body)
 }
 
-// writeErrorResponse отправляет ответ с ошибкой
+// writeErrorResponse отправляет ответ с ошибкой и общим кодом, соответствующим HTTP статусу
 func writeErrorResponse(w http.ResponseWriter, statusCode int, message string) {
+	writeErrorResponseWithCode(w, statusCode, statusErrorCode(statusCode), message)
+}
+
+// WriteErrorResponse отправляет ответ с ошибкой в формате API для обработчиков вне пакета handlers
+func WriteErrorResponse(w http.ResponseWriter, statusCode int, message string) {
+	writeErrorResponse(w, statusCode, message)
+}
+
+// writeErrorResponsef отправляет ответ с ошибкой, сообщение которой собирается по строке формата.
+// Переводится строка формата, поэтому сообщения с параметрами тоже локализуются
+func writeErrorResponsef(w http.ResponseWriter, statusCode int, format string, args ...interface{}) {
+	translated, language := localize(w, format)
+	writeLocalizedError(w, statusCode, statusErrorCode(statusCode), fmt.Sprintf(translated, args...), language)
+}
+
+// writeErrorResponseWithCode отправляет ответ с ошибкой и указанным кодом
+func writeErrorResponseWithCode(w http.ResponseWriter, statusCode int, code models.ErrorCode, message string) {
+	message, language := localize(w, message)
+	writeLocalizedError(w, statusCode, code, message, language)
+}
+
+// writeLocalizedError отправляет ответ с ошибкой, сообщение которой уже переведено на language
+func writeLocalizedError(w http.ResponseWriter, statusCode int, code models.ErrorCode, message, language string) {
+	w.Header().Set("Content-Language", language)
 	response := ErrorResponse{
 		Error:   http.StatusText(statusCode),
+		Code:    code,
 		Message: message,
 	}
 	writeJSONResponse(w, statusCode, response)
 }
 
+// writeServiceError отправляет ответ для ошибки сервиса. Ошибки бизнес-логики возвращаются
+// клиенту с их кодом и сообщением, остальные логируются и скрываются за fallbackMessage
+func writeServiceError(w http.ResponseWriter, log *logger.Logger, err error, fallbackMessage string) {
+	var serviceErr *services.Error
+	if errors.As(err, &serviceErr) {
+		writeErrorResponseWithCode(w, serviceErrorStatus(err), serviceErr.Code, serviceErr.Message)
+		return
+	}
+
+	log.WithError(err).Error(fallbackMessage)
+	writeErrorResponse(w, http.StatusInternalServerError, fallbackMessage)
+}
+
+// serviceErrorStatus возвращает HTTP статус по категории ошибки бизнес-логики
+func serviceErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrCourierTooFar),
+		errors.Is(err, services.ErrOutOfRange),
+		errors.Is(err, services.ErrMissingLocation),
+		errors.Is(err, services.ErrUnknownReference):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, services.ErrCourierUnavailable),
+		errors.Is(err, services.ErrInvalidTransition),
+		errors.Is(err, services.ErrConflict):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// statusErrorCode возвращает общий код ошибки для HTTP статуса
+func statusErrorCode(statusCode int) models.ErrorCode {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return models.ErrorCodeBadRequest
+	case http.StatusUnauthorized:
+		return models.ErrorCodeUnauthorized
+	case http.StatusForbidden:
+		return models.ErrorCodeForbidden
+	case http.StatusNotFound:
+		return models.ErrorCodeNotFound
+	case http.StatusMethodNotAllowed:
+		return models.ErrorCodeMethodNotAllowed
+	case http.StatusConflict:
+		return models.ErrorCodeConflict
+	case http.StatusRequestEntityTooLarge:
+		return models.ErrorCodePayloadTooLarge
+	case http.StatusUnprocessableEntity:
+		return models.ErrorCodeUnprocessable
+	case http.StatusPreconditionRequired:
+		return models.ErrorCodePreconditionRequired
+	case http.StatusTooManyRequests:
+		return models.ErrorCodeRateLimited
+	case http.StatusServiceUnavailable:
+		return models.ErrorCodeServiceUnavailable
+	default:
+		return models.ErrorCodeInternal
+	}
+}
+
+// etagMatches проверяет, содержит ли заголовок If-None-Match указанный ETag.
+// Сравнение слабое: префикс W/ не учитывается
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+
+	target := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == target {
+			return true
+		}
+	}
+	return false
+}
+
+// listEntry представляет элемент списка для вычисления условных заголовков ответа
+type listEntry struct {
+	id        uuid.UUID
+	updatedAt time.Time
+}
+
+// writeConditionalList отправляет список с заголовками Cache-Control, ETag и Last-Modified
+// или 304, если у клиента актуальная версия. If-None-Match проверяется в приоритете:
+// ETag учитывает состав списка, а Last-Modified не меняется, когда элемент покидает выборку
+func writeConditionalList(w http.ResponseWriter, r *http.Request, data interface{}, entries []listEntry) {
+	hash := sha256.New()
+	var lastModified time.Time
+	for _, entry := range entries {
+		fmt.Fprintf(hash, "%s:%d;", entry.id, entry.updatedAt.UnixNano())
+		if entry.updatedAt.After(lastModified) {
+			lastModified = entry.updatedAt
+		}
+	}
+	etag := `W/"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("ETag", etag)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		if etagMatches(ifNoneMatch, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	} else if !lastModified.IsZero() {
+		// Last-Modified передается с точностью до секунды
+		if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !lastModified.Truncate(time.Second).After(since) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	writeJSONResponse(w, http.StatusOK, data)
+}
+
+// Заголовки ответа с фактически примененными параметрами постраничной выборки
+const (
+	headerPaginationLimit  = "X-Pagination-Limit"
+	headerPaginationOffset = "X-Pagination-Offset"
+)
+
+// parsePagination разбирает параметры limit и offset. Некорректные значения заменяются
+// значениями по умолчанию, а limit больше MaxPageSize уменьшается до него
+func parsePagination(query url.Values, cfg *config.PaginationConfig) (limit, offset int) {
+	limit = cfg.DefaultPageSize
+	if limitStr := query.Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = l
+		}
+	}
+	if cfg.MaxPageSize > 0 && limit > cfg.MaxPageSize {
+		limit = cfg.MaxPageSize
+	}
+
+	if offsetStr := query.Get("offset"); offsetStr != "" {
+		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
+			offset = o
+		}
+	}
+
+	return limit, offset
+}
+
+// setPaginationHeaders сообщает клиенту примененные limit и offset
+func setPaginationHeaders(w http.ResponseWriter, limit, offset int) {
+	w.Header().Set(headerPaginationLimit, strconv.Itoa(limit))
+	w.Header().Set(headerPaginationOffset, strconv.Itoa(offset))
+}
+
+// writeDecodeErrorResponse отправляет 400 с причиной, по которой не удалось разобрать JSON тела запроса:
+// пустое или оборванное тело, синтаксическая ошибка с позицией в байтах или поле с неверным типом
+func writeDecodeErrorResponse(w http.ResponseWriter, err error) {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.Is(err, io.EOF):
+		writeErrorResponse(w, http.StatusBadRequest, "Request body is empty")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		writeErrorResponse(w, http.StatusBadRequest, "Request body contains incomplete JSON")
+	case errors.As(err, &syntaxErr):
+		writeErrorResponsef(w, http.StatusBadRequest, "Malformed JSON at byte offset %d", syntaxErr.Offset)
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		writeErrorResponsef(w, http.StatusBadRequest, "Invalid type for field %s: expected %s, got %s",
+			typeErr.Field, jsonTypeName(typeErr.Type), typeErr.Value)
+	case errors.As(err, &typeErr):
+		writeErrorResponsef(w, http.StatusBadRequest, "Invalid request body: expected %s, got %s",
+			jsonTypeName(typeErr.Type), typeErr.Value)
+	default:
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+	}
+}
+
+// jsonTypeName возвращает название типа JSON, в который кодируется тип Go
+func jsonTypeName(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
 // extractUUIDFromPath извлекает UUID из пути URL
 func extractUUIDFromPath(path, prefix string) (uuid.UUID, error) {
 	if !strings.HasPrefix(path, prefix) {
@@ -66,7 +306,7 @@ func extractUUIDFromPath(path, prefix string) (uuid.UUID, error) {
 // enableCORS включает CORS заголовки
 func enableCORS(w http.ResponseWriter) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 }
 
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// FieldError представляет ошибку валидации конкретного поля запроса
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError накапливает ошибки валидации по всем полям запроса
+type ValidationError struct {
+	Errors []FieldError
+}
+
+// Add добавляет ошибку валидации поля
+func (e *ValidationError) Add(field, format string, args ...interface{}) {
+	e.Errors = append(e.Errors, FieldError{
+		Field:   field,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// HasErrors сообщает, были ли найдены ошибки
+func (e *ValidationError) HasErrors() bool {
+	return len(e.Errors) > 0
+}
+
+// Err возвращает ошибку, если она содержит хотя бы одно нарушение, иначе nil
+func (e *ValidationError) Err() error {
+	if !e.HasErrors() {
+		return nil
+	}
+	return e
+}
+
+// Fields возвращает имена полей, не прошедших валидацию, без повторов
+func (e *ValidationError) Fields() []string {
+	fields := make([]string, 0, len(e.Errors))
+	seen := make(map[string]bool, len(e.Errors))
+	for _, fe := range e.Errors {
+		if seen[fe.Field] {
+			continue
+		}
+		seen[fe.Field] = true
+		fields = append(fields, fe.Field)
+	}
+	return fields
+}
+
+// Error реализует интерфейс error
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		messages = append(messages, fe.Field+": "+fe.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// writeValidationErrorResponse отправляет ответ 400 с перечнем ошибок валидации.
+// Отклоненный запрос логируется с его идентификатором и именами полей, но без значений,
+// чтобы повторяющиеся ошибки интеграции клиентов были видны, а данные клиентов не попадали в логи
+func writeValidationErrorResponse(w http.ResponseWriter, r *http.Request, log *logger.Logger, err error) {
+	var validationErr *ValidationError
+	isValidationErr := errors.As(err, &validationErr)
+
+	entry := log.WithFields(logrus.Fields{
+		"request_id": RequestIDFromContext(r.Context()),
+		"method":     r.Method,
+		"path":       r.URL.Path,
+	})
+	if isValidationErr {
+		entry = entry.WithField("fields", validationErr.Fields())
+	}
+	entry.Info("Request validation failed")
+
+	if !isValidationErr {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	message, language := localize(w, "Validation failed")
+	w.Header().Set("Content-Language", language)
+
+	writeJSONResponse(w, http.StatusBadRequest, ErrorResponse{
+		Error:   http.StatusText(http.StatusBadRequest),
+		Code:    models.ErrorCodeValidationFailed,
+		Message: message,
+		Errors:  validationErr.Errors,
+	})
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"delivery-system/internal/config"
+	"delivery-system/internal/models"
+)
+
+const (
+	testMaxItemsPerOrder   = 3
+	testMaxQuantityPerItem = 10
+	testMaxItemPriceCents  = 100000
+)
+
+func newTestOrderHandler() *OrderHandler {
+	return &OrderHandler{cfg: &config.OrderConfig{
+		MaxItemsPerOrder:   testMaxItemsPerOrder,
+		MaxQuantityPerItem: testMaxQuantityPerItem,
+		MaxItemPriceCents:  testMaxItemPriceCents,
+	}}
+}
+
+func validCreateOrderRequest(items ...models.CreateOrderItemRequest) *models.CreateOrderRequest {
+	return &models.CreateOrderRequest{
+		CustomerName:    "Иван Петров",
+		CustomerPhone:   "+79991234567",
+		DeliveryAddress: "ул. Ленина, д. 1",
+		Items:           items,
+	}
+}
+
+func testItems(count int) []models.CreateOrderItemRequest {
+	items := make([]models.CreateOrderItemRequest, count)
+	for i := range items {
+		items[i] = models.CreateOrderItemRequest{Name: fmt.Sprintf("Товар %d", i+1), Quantity: 1, Price: 100}
+	}
+	return items
+}
+
+// assertValidationFields проверяет, что err содержит ошибки ровно для полей fields
+func assertValidationFields(t *testing.T, err error, fields ...string) {
+	t.Helper()
+
+	if len(fields) == 0 {
+		if err != nil {
+			t.Fatalf("expected no validation error, got %v", err)
+		}
+		return
+	}
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected validation error for %v, got %v", fields, err)
+	}
+	got := verr.Fields()
+	if len(got) != len(fields) {
+		t.Fatalf("expected errors for fields %v, got %v", fields, got)
+	}
+	for i := range fields {
+		if got[i] != fields[i] {
+			t.Fatalf("expected errors for fields %v, got %v", fields, got)
+		}
+	}
+}
+
+func TestValidateCreateOrderRequestLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		items  []models.CreateOrderItemRequest
+		fields []string
+	}{
+		{
+			name:  "items at limit",
+			items: testItems(testMaxItemsPerOrder),
+		},
+		{
+			name:   "items over limit",
+			items:  testItems(testMaxItemsPerOrder + 1),
+			fields: []string{"items"},
+		},
+		{
+			name:  "quantity at limit",
+			items: []models.CreateOrderItemRequest{{Name: "Товар", Quantity: testMaxQuantityPerItem, Price: 100}},
+		},
+		{
+			name:   "quantity over limit",
+			items:  []models.CreateOrderItemRequest{{Name: "Товар", Quantity: testMaxQuantityPerItem + 1, Price: 100}},
+			fields: []string{"items[0].quantity"},
+		},
+		{
+			name:   "zero quantity",
+			items:  []models.CreateOrderItemRequest{{Name: "Товар", Quantity: 0, Price: 100}},
+			fields: []string{"items[0].quantity"},
+		},
+		{
+			name:  "price at limit",
+			items: []models.CreateOrderItemRequest{{Name: "Товар", Quantity: 1, Price: testMaxItemPriceCents}},
+		},
+		{
+			name:   "price over limit",
+			items:  []models.CreateOrderItemRequest{{Name: "Товар", Quantity: 1, Price: testMaxItemPriceCents + 1}},
+			fields: []string{"items[0].price"},
+		},
+		{
+			name:  "price of catalog item is not checked",
+			items: []models.CreateOrderItemRequest{{SKU: "SKU-1", Quantity: 1, Price: testMaxItemPriceCents + 1}},
+		},
+		{
+			name: "errors are reported for every item",
+			items: []models.CreateOrderItemRequest{
+				{Name: "Товар", Quantity: testMaxQuantityPerItem + 1, Price: 100},
+				{Name: "Товар", Quantity: 1, Price: testMaxItemPriceCents + 1},
+			},
+			fields: []string{"items[0].quantity", "items[1].price"},
+		},
+	}
+
+	h := newTestOrderHandler()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := h.validateCreateOrderRequest(validCreateOrderRequest(tt.items...))
+			assertValidationFields(t, err, tt.fields...)
+		})
+	}
+}
+
+func TestValidateCreateOrderRequestZeroLimitsDisableChecks(t *testing.T) {
+	h := &OrderHandler{cfg: &config.OrderConfig{}}
+
+	items := testItems(testMaxItemsPerOrder + 1)
+	items[0].Quantity = testMaxQuantityPerItem + 1
+	items[1].Price = testMaxItemPriceCents + 1
+
+	err := h.validateCreateOrderRequest(validCreateOrderRequest(items...))
+	assertValidationFields(t, err)
+}
+
+func TestValidateUpdateOrderItemRequestLimits(t *testing.T) {
+	quantity := func(v int) *int { return &v }
+	price := func(v models.Money) *models.Money { return &v }
+
+	tests := []struct {
+		name   string
+		req    models.UpdateOrderItemRequest
+		fields []string
+	}{
+		{
+			name:   "empty request",
+			fields: []string{"quantity"},
+		},
+		{
+			name: "quantity at limit",
+			req:  models.UpdateOrderItemRequest{Quantity: quantity(testMaxQuantityPerItem)},
+		},
+		{
+			name:   "quantity over limit",
+			req:    models.UpdateOrderItemRequest{Quantity: quantity(testMaxQuantityPerItem + 1)},
+			fields: []string{"quantity"},
+		},
+		{
+			name: "price at limit",
+			req:  models.UpdateOrderItemRequest{Price: price(testMaxItemPriceCents)},
+		},
+		{
+			name:   "price over limit",
+			req:    models.UpdateOrderItemRequest{Price: price(testMaxItemPriceCents + 1)},
+			fields: []string{"price"},
+		},
+		{
+			name:   "negative price",
+			req:    models.UpdateOrderItemRequest{Price: price(-1)},
+			fields: []string{"price"},
+		},
+	}
+
+	h := newTestOrderHandler()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := h.validateUpdateOrderItemRequest(&tt.req)
+			assertValidationFields(t, err, tt.fields...)
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"delivery-system/internal/config"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+	"delivery-system/internal/services"
+	"delivery-system/internal/webhooks"
+)
+
+// minWebhookSecretLength минимальная длина секрета, переданного клиентом
+const minWebhookSecretLength = 16
+
+// WebhookHandler представляет обработчик подписок на webhooks
+type WebhookHandler struct {
+	webhookService *services.WebhookService
+	cfg            *config.WebhookConfig
+	log            *logger.Logger
+}
+
+// NewWebhookHandler создает новый обработчик подписок на webhooks
+func NewWebhookHandler(webhookService *services.WebhookService, cfg *config.WebhookConfig, log *logger.Logger) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+		cfg:            cfg,
+		log:            log,
+	}
+}
+
+// CreateSubscription создает подписку. Секрет для проверки подписи возвращается только в этом ответе
+func (h *WebhookHandler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.CreateWebhookSubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeErrorResponse(w, err)
+		return
+	}
+
+	if err := h.validateCreateSubscriptionRequest(r.Context(), &req); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	subscription, err := h.webhookService.CreateSubscription(&req)
+	if err != nil {
+		h.log.WithError(err).Error("Failed to create webhook subscription")
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to create webhook subscription")
+		return
+	}
+
+	w.Header().Set("Location", "/api/webhooks/"+subscription.ID.String())
+	writeJSONResponse(w, http.StatusCreated, subscription)
+}
+
+// GetSubscriptions возвращает список подписок
+func (h *WebhookHandler) GetSubscriptions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	subscriptions, err := h.webhookService.GetSubscriptions()
+	if err != nil {
+		h.log.WithError(err).Error("Failed to get webhook subscriptions")
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to get webhook subscriptions")
+		return
+	}
+
+	for _, subscription := range subscriptions {
+		subscription.Secret = ""
+	}
+
+	writeJSONResponse(w, http.StatusOK, subscriptions)
+}
+
+// GetSubscription возвращает подписку по ID
+func (h *WebhookHandler) GetSubscription(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	subscriptionID, err := extractUUIDFromPath(r.URL.Path, "/api/webhooks/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid subscription ID")
+		return
+	}
+
+	subscription, err := h.webhookService.GetSubscription(subscriptionID)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to get webhook subscription")
+		return
+	}
+
+	subscription.Secret = ""
+	writeJSONResponse(w, http.StatusOK, subscription)
+}
+
+// UpdateSubscription изменяет адрес, типы событий или активность подписки
+func (h *WebhookHandler) UpdateSubscription(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	subscriptionID, err := extractUUIDFromPath(r.URL.Path, "/api/webhooks/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid subscription ID")
+		return
+	}
+
+	var req models.UpdateWebhookSubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeErrorResponse(w, err)
+		return
+	}
+
+	if req.URL != nil {
+		if err := h.validateWebhookURL(r.Context(), *req.URL); err != nil {
+			writeErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if req.EventTypes != nil {
+		if err := validateWebhookEventTypes(req.EventTypes); err != nil {
+			writeErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	subscription, err := h.webhookService.UpdateSubscription(subscriptionID, &req)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to update webhook subscription")
+		return
+	}
+
+	subscription.Secret = ""
+	writeJSONResponse(w, http.StatusOK, subscription)
+}
+
+// DeleteSubscription удаляет подписку
+func (h *WebhookHandler) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	subscriptionID, err := extractUUIDFromPath(r.URL.Path, "/api/webhooks/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid subscription ID")
+		return
+	}
+
+	if err := h.webhookService.DeleteSubscription(subscriptionID); err != nil {
+		writeServiceError(w, h.log, err, "Failed to delete webhook subscription")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Webhook subscription deleted successfully"})
+}
+
+// validateCreateSubscriptionRequest валидирует запрос на создание подписки
+func (h *WebhookHandler) validateCreateSubscriptionRequest(ctx context.Context, req *models.CreateWebhookSubscriptionRequest) error {
+	if err := h.validateWebhookURL(ctx, req.URL); err != nil {
+		return err
+	}
+
+	if err := validateWebhookEventTypes(req.EventTypes); err != nil {
+		return err
+	}
+
+	if req.Secret != "" && len(req.Secret) < minWebhookSecretLength {
+		return fmt.Errorf("secret must be at least %d characters long", minWebhookSecretLength)
+	}
+
+	return nil
+}
+
+// validateWebhookURL проверяет, что адрес подписчика - абсолютный http(s) URL, хост которого
+// не разрешается в loopback, частные или link-local адреса (если они не разрешены настройкой)
+func (h *WebhookHandler) validateWebhookURL(ctx context.Context, rawURL string) error {
+	if rawURL == "" {
+		return fmt.Errorf("url is required")
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return fmt.Errorf("url must be an absolute http or https URL")
+	}
+
+	if h.cfg.AllowPrivateTargets {
+		return nil
+	}
+	if err := webhooks.CheckHost(ctx, parsed.Hostname()); err != nil {
+		if errors.Is(err, webhooks.ErrForbiddenTarget) {
+			return fmt.Errorf("url must not point to a loopback, private or link-local address")
+		}
+		return fmt.Errorf("url host cannot be resolved")
+	}
+
+	return nil
+}
+
+// validateWebhookEventTypes проверяет, что список типов событий не пуст и содержит только известные типы
+func validateWebhookEventTypes(eventTypes []models.EventType) error {
+	if len(eventTypes) == 0 {
+		return fmt.Errorf("at least one event type is required")
+	}
+
+	for _, eventType := range eventTypes {
+		if !eventType.IsValid() {
+			return fmt.Errorf("unknown event type: %s", eventType)
+		}
+	}
+
+	return nil
+}
@@ -3,11 +3,17 @@ package kafka
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"delivery-system/internal/config"
 	"delivery-system/internal/logger"
+	"delivery-system/internal/metrics"
 	"delivery-system/internal/models"
 
 	"github.com/IBM/sarama"
@@ -16,24 +22,56 @@ import (
 // EventHandler представляет обработчик событий
 type EventHandler func(ctx context.Context, event *models.Event) error
 
+// ConsumerMetrics представляет метрики обработки событий consumer'ом, размеченные по типу события
+type ConsumerMetrics struct {
+	Processed       *metrics.Vec
+	Unhandled       *metrics.Vec
+	HandlerDuration *metrics.HistogramVec
+}
+
+// NewConsumerMetrics регистрирует метрики обработки событий в реестре метрик
+func NewConsumerMetrics(registry *metrics.Registry) *ConsumerMetrics {
+	return &ConsumerMetrics{
+		Processed:       registry.NewCounter("delivery_kafka_events_processed_total", "Events passed to handlers by result", "event_type", "result"),
+		Unhandled:       registry.NewCounter("delivery_kafka_events_unhandled_total", "Events skipped because no handler is registered", "event_type"),
+		HandlerDuration: registry.NewHistogram("delivery_kafka_handler_duration_seconds", "Time spent in event handlers", nil, "event_type"),
+	}
+}
+
+// errPoisonMessage означает, что сообщение не может быть обработано ни при каком повторе
+var errPoisonMessage = errors.New("poison message")
+
 // Consumer представляет Kafka consumer
 type Consumer struct {
-	consumer sarama.ConsumerGroup
-	log      *logger.Logger
-	handlers map[models.EventType]EventHandler
-	topics   []string
-	ctx      context.Context
-	cancel   context.CancelFunc
-	wg       sync.WaitGroup
+	consumer        sarama.ConsumerGroup
+	log             *logger.Logger
+	handlers        map[models.EventType][]EventHandler
+	topics          []string
+	producer        *Producer
+	deadLetterTopic string
+	handlerRetries  int
+	maxMessageBytes int
+	metrics         *ConsumerMetrics
+	ctx             context.Context
+	cancel          context.CancelFunc
+	wg              sync.WaitGroup
+	// assignedPartitions количество партиций, назначенных в текущей сессии группы
+	assignedPartitions atomic.Int64
 }
 
-// NewConsumer создает новый Kafka consumer
-func NewConsumer(cfg *config.KafkaConfig, log *logger.Logger) (*Consumer, error) {
+// NewConsumer создает новый Kafka consumer. Producer используется для пересылки
+// непригодных к обработке сообщений в топик недоставленных сообщений и может быть nil
+func NewConsumer(cfg *config.KafkaConfig, producer *Producer, consumerMetrics *ConsumerMetrics, log *logger.Logger) (*Consumer, error) {
+	initialOffset, err := parseInitialOffset(cfg.InitialOffset)
+	if err != nil {
+		return nil, err
+	}
+
 	config := sarama.NewConfig()
 	config.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRoundRobin
-	config.Consumer.Offsets.Initial = sarama.OffsetOldest
-	config.Consumer.Group.Session.Timeout = 10000000000   // 10 секунд
-	config.Consumer.Group.Heartbeat.Interval = 3000000000 // 3 секунды
+	config.Consumer.Offsets.Initial = initialOffset
+	config.Consumer.Group.Session.Timeout = time.Duration(cfg.SessionTimeout) * time.Second
+	config.Consumer.Group.Heartbeat.Interval = time.Duration(cfg.HeartbeatInterval) * time.Second
 
 	consumer, err := sarama.NewConsumerGroup(cfg.Brokers, cfg.GroupID, config)
 	if err != nil {
@@ -42,23 +80,41 @@ func NewConsumer(cfg *config.KafkaConfig, log *logger.Logger) (*Consumer, error)
 
 	ctx, cancel := context.WithCancel(context.Background())
 
-	topics := []string{cfg.Topics.Orders, cfg.Topics.Couriers, cfg.Topics.Locations}
+	topics := cfg.Topics.All()
 
 	log.Info("Kafka consumer created successfully")
 
 	return &Consumer{
-		consumer: consumer,
-		log:      log,
-		handlers: make(map[models.EventType]EventHandler),
-		topics:   topics,
-		ctx:      ctx,
-		cancel:   cancel,
+		consumer:        consumer,
+		log:             log,
+		handlers:        make(map[models.EventType][]EventHandler),
+		topics:          topics,
+		producer:        producer,
+		deadLetterTopic: cfg.DeadLetterTopic,
+		handlerRetries:  cfg.HandlerRetries,
+		maxMessageBytes: cfg.MaxMessageBytes,
+		metrics:         consumerMetrics,
+		ctx:             ctx,
+		cancel:          cancel,
 	}, nil
 }
 
-// RegisterHandler регистрирует обработчик для определенного типа события
+// parseInitialOffset преобразует настройку начального смещения для новой группы потребителей
+func parseInitialOffset(value string) (int64, error) {
+	switch strings.ToLower(value) {
+	case "", "oldest":
+		return sarama.OffsetOldest, nil
+	case "newest":
+		return sarama.OffsetNewest, nil
+	default:
+		return 0, fmt.Errorf("invalid Kafka initial offset %q: expected oldest or newest", value)
+	}
+}
+
+// RegisterHandler регистрирует обработчик для определенного типа события.
+// Обработчики одного типа вызываются в порядке регистрации
 func (c *Consumer) RegisterHandler(eventType models.EventType, handler EventHandler) {
-	c.handlers[eventType] = handler
+	c.handlers[eventType] = append(c.handlers[eventType], handler)
 	c.log.WithField("event_type", eventType).Info("Event handler registered")
 }
 
@@ -90,16 +146,72 @@ func (c *Consumer) Stop() error {
 	return c.consumer.Close()
 }
 
-// Setup реализует интерфейс sarama.ConsumerGroupHandler
-func (c *Consumer) Setup(sarama.ConsumerGroupSession) error {
+// Setup реализует интерфейс sarama.ConsumerGroupHandler. Вызывается после каждой перебалансировки
+// группы и записывает в лог назначенные этому экземпляру партиции
+func (c *Consumer) Setup(session sarama.ConsumerGroupSession) error {
+	claims := session.Claims()
+	count := countPartitions(claims)
+	c.assignedPartitions.Store(int64(count))
+
+	entry := c.log.WithField("member_id", session.MemberID()).
+		WithField("generation_id", session.GenerationID()).
+		WithField("partitions", formatClaims(claims))
+	if count == 0 {
+		// Экземпляров в группе больше, чем партиций, - этот экземпляр события не читает
+		entry.Warn("Kafka consumer group rebalanced, no partitions assigned")
+	} else {
+		entry.WithField("partition_count", count).Info("Kafka consumer group rebalanced, partitions assigned")
+	}
 	return nil
 }
 
-// Cleanup реализует интерфейс sarama.ConsumerGroupHandler
-func (c *Consumer) Cleanup(sarama.ConsumerGroupSession) error {
+// Cleanup реализует интерфейс sarama.ConsumerGroupHandler. Вызывается перед перебалансировкой
+// или остановкой, когда партиции текущей сессии освобождаются
+func (c *Consumer) Cleanup(session sarama.ConsumerGroupSession) error {
+	c.assignedPartitions.Store(0)
+	c.log.WithField("member_id", session.MemberID()).
+		WithField("generation_id", session.GenerationID()).
+		WithField("partitions", formatClaims(session.Claims())).
+		Info("Kafka consumer partitions released")
 	return nil
 }
 
+// Ready сообщает, назначена ли consumer'у хотя бы одна партиция в текущей сессии группы
+func (c *Consumer) Ready() bool {
+	return c.assignedPartitions.Load() > 0
+}
+
+// AssignedPartitions возвращает количество партиций, назначенных в текущей сессии группы
+func (c *Consumer) AssignedPartitions() int {
+	return int(c.assignedPartitions.Load())
+}
+
+// countPartitions возвращает общее количество партиций в назначении
+func countPartitions(claims map[string][]int32) int {
+	count := 0
+	for _, partitions := range claims {
+		count += len(partitions)
+	}
+	return count
+}
+
+// formatClaims форматирует назначение партиций для лога, например "orders:[0 1],couriers:[2]"
+func formatClaims(claims map[string][]int32) string {
+	topics := make([]string, 0, len(claims))
+	for topic := range claims {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+
+	parts := make([]string, 0, len(topics))
+	for _, topic := range topics {
+		partitions := append([]int32(nil), claims[topic]...)
+		sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+		parts = append(parts, fmt.Sprintf("%s:%v", topic, partitions))
+	}
+	return strings.Join(parts, ",")
+}
+
 // ConsumeClaim реализует интерфейс sarama.ConsumerGroupHandler
 func (c *Consumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
 	for {
@@ -109,13 +221,32 @@ func (c *Consumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim saram
 				return nil
 			}
 
-			if err := c.processMessage(message); err != nil {
+			err := c.processMessage(session.Context(), message)
+			switch {
+			case err == nil:
+				session.MarkMessage(message, "")
+			case errors.Is(err, errPoisonMessage):
+				// Повтор не поможет: откладываем сообщение и идем дальше, чтобы не блокировать партицию
 				c.log.WithError(err).
 					WithField("topic", message.Topic).
 					WithField("partition", message.Partition).
 					WithField("offset", message.Offset).
-					Error("Failed to process message")
-			} else {
+					Error("Skipping malformed message")
+				c.sendToDeadLetter(session.Context(), message, err)
+				session.MarkMessage(message, "")
+			case session.Context().Err() != nil:
+				// Повторы прерваны перебалансировкой или остановкой: сообщение не отмечается
+				// и будет доставлено заново экземпляру, получившему партицию
+				return nil
+			default:
+				// Повторы исчерпаны. Следующая отметка в партиции закоммитила бы смещение за этим сообщением,
+				// поэтому оно сохраняется в топике недоставленных сообщений, а не теряется
+				c.log.WithError(err).
+					WithField("topic", message.Topic).
+					WithField("partition", message.Partition).
+					WithField("offset", message.Offset).
+					Error("Failed to process message, sending to dead letter topic")
+				c.sendToDeadLetter(session.Context(), message, err)
 				session.MarkMessage(message, "")
 			}
 
@@ -125,11 +256,29 @@ func (c *Consumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim saram
 	}
 }
 
-// processMessage обрабатывает полученное сообщение
-func (c *Consumer) processMessage(message *sarama.ConsumerMessage) error {
+// sendToDeadLetter пересылает сообщение в топик недоставленных сообщений, если он настроен
+func (c *Consumer) sendToDeadLetter(ctx context.Context, message *sarama.ConsumerMessage, reason error) {
+	if c.deadLetterTopic == "" || c.producer == nil {
+		return
+	}
+
+	if err := c.producer.PublishDeadLetter(ctx, c.deadLetterTopic, message, reason); err != nil {
+		c.log.WithError(err).Error("Failed to send message to dead letter topic")
+	}
+}
+
+// processMessage разбирает сообщение и передает событие всем обработчикам его типа. Каждый обработчик
+// повторяется при ошибке отдельно, поэтому сбой одного обработчика не вызывает повторно остальные.
+// Возвращает ошибки обработчиков, которые не удалось выполнить и после повторов
+func (c *Consumer) processMessage(ctx context.Context, message *sarama.ConsumerMessage) error {
+	// Размер проверяется до разбора, чтобы слишком большое сообщение не разворачивалось в память
+	if c.maxMessageBytes > 0 && len(message.Value) > c.maxMessageBytes {
+		return fmt.Errorf("%w: message size %d exceeds limit of %d bytes", errPoisonMessage, len(message.Value), c.maxMessageBytes)
+	}
+
 	var event models.Event
 	if err := json.Unmarshal(message.Value, &event); err != nil {
-		return fmt.Errorf("failed to unmarshal event: %w", err)
+		return fmt.Errorf("%w: failed to unmarshal event: %v", errPoisonMessage, err)
 	}
 
 	c.log.WithField("event_type", event.Type).
@@ -137,17 +286,27 @@ func (c *Consumer) processMessage(message *sarama.ConsumerMessage) error {
 		WithField("topic", message.Topic).
 		Debug("Processing event")
 
-	// Находим обработчик для данного типа события
-	handler, exists := c.handlers[event.Type]
+	// Находим обработчики для данного типа события
+	handlers, exists := c.handlers[event.Type]
 	if !exists {
 		c.log.WithField("event_type", event.Type).Warn("No handler registered for event type")
+		c.metrics.Unhandled.Inc(string(event.Type))
 		return nil // Не возвращаем ошибку, просто пропускаем событие
 	}
 
-	// Вызываем обработчик
-	if err := handler(c.ctx, &event); err != nil {
-		return fmt.Errorf("handler failed for event type %s: %w", event.Type, err)
+	// Вызываем обработчики
+	start := time.Now()
+	var failures []error
+	for i, handler := range handlers {
+		if err := c.runHandler(ctx, message, &event, handler); err != nil {
+			failures = append(failures, fmt.Errorf("handler %d failed for event type %s: %w", i, event.Type, err))
+		}
+	}
+	if len(failures) > 0 {
+		c.recordProcessed(event.Type, start, "error")
+		return errors.Join(failures...)
 	}
+	c.recordProcessed(event.Type, start, "success")
 
 	c.log.WithField("event_type", event.Type).
 		WithField("event_id", event.ID).
@@ -155,3 +314,31 @@ func (c *Consumer) processMessage(message *sarama.ConsumerMessage) error {
 
 	return nil
 }
+
+// runHandler вызывает обработчик события, повторяя его при ошибке до handlerRetries раз
+func (c *Consumer) runHandler(ctx context.Context, message *sarama.ConsumerMessage, event *models.Event, handler EventHandler) error {
+	err := handler(c.ctx, event)
+	for attempt := 1; err != nil && attempt <= c.handlerRetries; attempt++ {
+		c.log.WithError(err).
+			WithField("topic", message.Topic).
+			WithField("offset", message.Offset).
+			WithField("event_type", event.Type).
+			WithField("attempt", attempt).
+			Warn("Retrying event handler")
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(attempt) * time.Second):
+		}
+
+		err = handler(c.ctx, event)
+	}
+	return err
+}
+
+// recordProcessed учитывает результат и длительность вызова обработчиков события
+func (c *Consumer) recordProcessed(eventType models.EventType, start time.Time, result string) {
+	c.metrics.HandlerDuration.Observe(time.Since(start).Seconds(), string(eventType))
+	c.metrics.Processed.Inc(string(eventType), result)
+}
@@ -0,0 +1,93 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// defaultHealthTimeout используется, если у контекста проверки нет дедлайна
+const defaultHealthTimeout = 5 * time.Second
+
+// HealthChecker проверяет доступность брокеров Kafka
+type HealthChecker struct {
+	brokers []string
+}
+
+// NewHealthChecker создает новую проверку доступности брокеров Kafka
+func NewHealthChecker(brokers []string) *HealthChecker {
+	return &HealthChecker{brokers: brokers}
+}
+
+// Health запрашивает метаданные у брокеров и возвращает nil, если ответил хотя бы один.
+// Проверка не выходит за дедлайн контекста, даже если брокер не отвечает
+func (h *HealthChecker) Health(ctx context.Context) error {
+	if len(h.brokers) == 0 {
+		return errors.New("no Kafka brokers configured")
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultHealthTimeout)
+		defer cancel()
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- h.checkBrokers(ctx)
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("kafka health check timed out: %w", ctx.Err())
+	}
+}
+
+// checkBrokers последовательно опрашивает брокеры до первого успешного ответа
+func (h *HealthChecker) checkBrokers(ctx context.Context) error {
+	var lastErr error
+	for _, addr := range h.brokers {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if lastErr = checkBroker(ctx, addr); lastErr == nil {
+			return nil
+		}
+	}
+	return lastErr
+}
+
+// checkBroker открывает соединение с брокером и запрашивает метаданные
+// с таймаутами, ограниченными дедлайном контекста
+func checkBroker(ctx context.Context, addr string) error {
+	timeout := defaultHealthTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+		if timeout <= 0 {
+			return context.DeadlineExceeded
+		}
+	}
+
+	cfg := sarama.NewConfig()
+	cfg.Net.DialTimeout = timeout
+	cfg.Net.ReadTimeout = timeout
+	cfg.Net.WriteTimeout = timeout
+	cfg.Metadata.Retry.Max = 0
+
+	broker := sarama.NewBroker(addr)
+	if err := broker.Open(cfg); err != nil {
+		return fmt.Errorf("failed to connect to broker %s: %w", addr, err)
+	}
+	defer broker.Close()
+
+	if _, err := broker.GetMetadata(&sarama.MetadataRequest{}); err != nil {
+		return fmt.Errorf("failed to get metadata from broker %s: %w", addr, err)
+	}
+
+	return nil
+}
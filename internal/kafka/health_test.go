@@ -0,0 +1,92 @@
+package kafka
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// newSilentBroker запускает сервер, который принимает соединения, но ничего не отвечает,
+// как зависший брокер
+func newSilentBroker(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	var conns []net.Conn
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+
+	t.Cleanup(func() {
+		listener.Close()
+		<-done
+		for _, conn := range conns {
+			conn.Close()
+		}
+	})
+	return listener.Addr().String()
+}
+
+// newClosedAddr возвращает адрес, на котором никто не принимает соединения
+func newClosedAddr(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	return addr
+}
+
+func TestHealthUnreachableBrokerFailsWithinTimeout(t *testing.T) {
+	const timeout = 300 * time.Millisecond
+
+	tests := []struct {
+		name    string
+		brokers []string
+	}{
+		{name: "broker does not respond", brokers: []string{newSilentBroker(t)}},
+		{name: "connection refused", brokers: []string{newClosedAddr(t)}},
+		{name: "all brokers unavailable", brokers: []string{newClosedAddr(t), newSilentBroker(t)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := NewHealthChecker(tt.brokers).Health(ctx)
+			elapsed := time.Since(start)
+
+			if err == nil {
+				t.Fatal("expected health check to fail")
+			}
+			// Запас на планирование горутин, но заметно меньше таймаутов sarama по умолчанию
+			if elapsed > timeout+500*time.Millisecond {
+				t.Fatalf("health check took %s, expected to fail within %s", elapsed, timeout)
+			}
+		})
+	}
+}
+
+func TestHealthNoBrokers(t *testing.T) {
+	if err := NewHealthChecker(nil).Health(context.Background()); err == nil {
+		t.Fatal("expected error when no brokers are configured")
+	}
+}
@@ -1,11 +1,15 @@
 package kafka
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
 	"delivery-system/internal/config"
+	"delivery-system/internal/geo"
 	"delivery-system/internal/logger"
 	"delivery-system/internal/models"
 
@@ -13,11 +17,16 @@ import (
 	"github.com/google/uuid"
 )
 
+// ErrPublishTimeout возвращается, если брокер не подтвердил сообщение за KAFKA_PUBLISH_TIMEOUT
+// или контекст публикации был отменен раньше. Сообщение при этом еще может быть доставлено
+var ErrPublishTimeout = errors.New("kafka publish timed out")
+
 // Producer представляет Kafka producer
 type Producer struct {
-	producer sarama.SyncProducer
-	log      *logger.Logger
-	topics   *config.Topics
+	producer       sarama.SyncProducer
+	log            *logger.Logger
+	topics         *config.Topics
+	publishTimeout time.Duration
 }
 
 // NewProducer создает новый Kafka producer
@@ -36,9 +45,10 @@ func NewProducer(cfg *config.KafkaConfig, log *logger.Logger) (*Producer, error)
 	log.Info("Kafka producer created successfully")
 
 	return &Producer{
-		producer: producer,
-		log:      log,
-		topics:   &cfg.Topics,
+		producer:       producer,
+		log:            log,
+		topics:         &cfg.Topics,
+		publishTimeout: time.Duration(cfg.PublishTimeout) * time.Second,
 	}, nil
 }
 
@@ -48,7 +58,7 @@ func (p *Producer) Close() error {
 }
 
 // PublishOrderCreated публикует событие создания заказа
-func (p *Producer) PublishOrderCreated(order *models.Order) error {
+func (p *Producer) PublishOrderCreated(ctx context.Context, order *models.Order) error {
 	event := models.Event{
 		ID:        uuid.New(),
 		Type:      models.EventTypeOrderCreated,
@@ -59,14 +69,17 @@ func (p *Producer) PublishOrderCreated(order *models.Order) error {
 			CustomerPhone:   order.CustomerPhone,
 			DeliveryAddress: order.DeliveryAddress,
 			TotalAmount:     order.TotalAmount,
+			PickupLat:       order.PickupLat,
+			PickupLon:       order.PickupLon,
+			CreatedAt:       order.CreatedAt,
 		},
 	}
 
-	return p.publishEvent(p.topics.Orders, event)
+	return p.publishEvent(ctx, p.topics.Orders, event)
 }
 
 // PublishOrderStatusChanged публикует событие изменения статуса заказа
-func (p *Producer) PublishOrderStatusChanged(orderID uuid.UUID, oldStatus, newStatus models.OrderStatus, courierID *uuid.UUID) error {
+func (p *Producer) PublishOrderStatusChanged(ctx context.Context, orderID uuid.UUID, oldStatus, newStatus models.OrderStatus, courierID *uuid.UUID) error {
 	event := models.Event{
 		ID:        uuid.New(),
 		Type:      models.EventTypeOrderStatusChanged,
@@ -80,11 +93,98 @@ func (p *Producer) PublishOrderStatusChanged(orderID uuid.UUID, oldStatus, newSt
 		},
 	}
 
-	return p.publishEvent(p.topics.Orders, event)
+	return p.publishEvent(ctx, p.topics.Orders, event)
+}
+
+// PublishOrderCancelled публикует событие отмены заказа
+func (p *Producer) PublishOrderCancelled(ctx context.Context, orderID uuid.UUID, reason models.CancellationReason, comment string) error {
+	event := models.Event{
+		ID:        uuid.New(),
+		Type:      models.EventTypeOrderCancelled,
+		Timestamp: time.Now(),
+		Data: models.OrderCancelledEvent{
+			OrderID:       orderID,
+			Reason:        reason,
+			ReasonComment: comment,
+			Timestamp:     time.Now(),
+		},
+	}
+
+	return p.publishEvent(ctx, p.topics.Orders, event)
+}
+
+// PublishOrderUnassignedTimeout публикует событие о превышении времени ожидания назначения курьера
+func (p *Producer) PublishOrderUnassignedTimeout(ctx context.Context, orderID uuid.UUID, createdAt time.Time, priority int) error {
+	event := models.Event{
+		ID:        uuid.New(),
+		Type:      models.EventTypeOrderUnassignedTimeout,
+		Timestamp: time.Now(),
+		Data: models.OrderUnassignedTimeoutEvent{
+			OrderID:   orderID,
+			CreatedAt: createdAt,
+			Priority:  priority,
+			Timestamp: time.Now(),
+		},
+	}
+
+	return p.publishEvent(ctx, p.topics.Orders, event)
+}
+
+// PublishOrderSLABreached публикует событие о нарушении срока доставки заказа
+func (p *Producer) PublishOrderSLABreached(ctx context.Context, orderID uuid.UUID, status models.OrderStatus, courierID *uuid.UUID, createdAt, dueAt time.Time) error {
+	event := models.Event{
+		ID:        uuid.New(),
+		Type:      models.EventTypeOrderSLABreached,
+		Timestamp: time.Now(),
+		Data: models.OrderSLABreachedEvent{
+			OrderID:   orderID,
+			Status:    status,
+			CourierID: courierID,
+			CreatedAt: createdAt,
+			DueAt:     dueAt,
+			Timestamp: time.Now(),
+		},
+	}
+
+	return p.publishEvent(ctx, p.topics.Orders, event)
+}
+
+// PublishOrderReadyForPickup публикует событие готовности заказа к выдаче курьеру
+func (p *Producer) PublishOrderReadyForPickup(ctx context.Context, order *models.Order) error {
+	event := models.Event{
+		ID:        uuid.New(),
+		Type:      models.EventTypeOrderReadyForPickup,
+		Timestamp: time.Now(),
+		Data: models.OrderReadyForPickupEvent{
+			OrderID:         order.ID,
+			CourierID:       order.CourierID,
+			DeliveryAddress: order.DeliveryAddress,
+			Timestamp:       time.Now(),
+		},
+	}
+
+	return p.publishEvent(ctx, p.topics.Orders, event)
+}
+
+// PublishOrderAmountChanged публикует событие изменения суммы заказа
+func (p *Producer) PublishOrderAmountChanged(ctx context.Context, orderID uuid.UUID, oldAmount, newAmount models.Money) error {
+	event := models.Event{
+		ID:        uuid.New(),
+		Type:      models.EventTypeOrderAmountChanged,
+		Timestamp: time.Now(),
+		Data: models.OrderAmountChangedEvent{
+			OrderID:   orderID,
+			OldAmount: oldAmount,
+			NewAmount: newAmount,
+			Timestamp: time.Now(),
+		},
+	}
+
+	return p.publishEvent(ctx, p.topics.Orders, event)
 }
 
 // PublishCourierAssigned публикует событие назначения курьера
-func (p *Producer) PublishCourierAssigned(orderID, courierID uuid.UUID) error {
+func (p *Producer) PublishCourierAssigned(ctx context.Context, orderID, courierID uuid.UUID) error {
 	event := models.Event{
 		ID:        uuid.New(),
 		Type:      models.EventTypeCourierAssigned,
@@ -96,11 +196,11 @@ func (p *Producer) PublishCourierAssigned(orderID, courierID uuid.UUID) error {
 		},
 	}
 
-	return p.publishEvent(p.topics.Couriers, event)
+	return p.publishEvent(ctx, p.topics.Couriers, event)
 }
 
 // PublishCourierStatusChanged публикует событие изменения статуса курьера
-func (p *Producer) PublishCourierStatusChanged(courierID uuid.UUID, oldStatus, newStatus models.CourierStatus) error {
+func (p *Producer) PublishCourierStatusChanged(ctx context.Context, courierID uuid.UUID, oldStatus, newStatus models.CourierStatus) error {
 	event := models.Event{
 		ID:        uuid.New(),
 		Type:      models.EventTypeCourierStatusChanged,
@@ -113,11 +213,16 @@ func (p *Producer) PublishCourierStatusChanged(courierID uuid.UUID, oldStatus, n
 		},
 	}
 
-	return p.publishEvent(p.topics.Couriers, event)
+	return p.publishEvent(ctx, p.topics.Couriers, event)
 }
 
 // PublishLocationUpdated публикует событие обновления местоположения
-func (p *Producer) PublishLocationUpdated(courierID uuid.UUID, lat, lon float64) error {
+// Событие с координатами вне допустимого диапазона не публикуется
+func (p *Producer) PublishLocationUpdated(ctx context.Context, courierID uuid.UUID, lat, lon float64) error {
+	if !geo.ValidLat(lat) || !geo.ValidLon(lon) {
+		return fmt.Errorf("invalid coordinates lat=%v lon=%v for courier %s", lat, lon, courierID)
+	}
+
 	event := models.Event{
 		ID:        uuid.New(),
 		Type:      models.EventTypeLocationUpdated,
@@ -130,11 +235,76 @@ func (p *Producer) PublishLocationUpdated(courierID uuid.UUID, lat, lon float64)
 		},
 	}
 
-	return p.publishEvent(p.topics.Locations, event)
+	return p.publishEvent(ctx, p.topics.Locations, event)
+}
+
+// PublishDeadLetter пересылает непригодное к обработке сообщение в топик недоставленных сообщений,
+// сохраняя исходные ключ, данные и заголовки и добавляя сведения о причине
+func (p *Producer) PublishDeadLetter(ctx context.Context, topic string, original *sarama.ConsumerMessage, reason error) error {
+	headers := make([]sarama.RecordHeader, 0, len(original.Headers)+4)
+	for _, h := range original.Headers {
+		if h != nil {
+			headers = append(headers, *h)
+		}
+	}
+	headers = append(headers,
+		sarama.RecordHeader{Key: []byte("dlq_reason"), Value: []byte(reason.Error())},
+		sarama.RecordHeader{Key: []byte("original_topic"), Value: []byte(original.Topic)},
+		sarama.RecordHeader{Key: []byte("original_partition"), Value: []byte(strconv.FormatInt(int64(original.Partition), 10))},
+		sarama.RecordHeader{Key: []byte("original_offset"), Value: []byte(strconv.FormatInt(original.Offset, 10))},
+	)
+
+	message := &sarama.ProducerMessage{
+		Topic:   topic,
+		Key:     sarama.ByteEncoder(original.Key),
+		Value:   sarama.ByteEncoder(original.Value),
+		Headers: headers,
+	}
+
+	if _, _, err := p.send(ctx, message); err != nil {
+		return fmt.Errorf("failed to send message to dead letter topic %s: %w", topic, err)
+	}
+
+	p.log.WithField("topic", topic).
+		WithField("original_topic", original.Topic).
+		WithField("original_offset", original.Offset).
+		Warn("Message sent to dead letter topic")
+
+	return nil
+}
+
+// PublishWebhookDeadLetter отправляет событие, которое не удалось доставить подписчику webhook,
+// в топик недоставленных сообщений вместе с адресом подписки и причиной
+func (p *Producer) PublishWebhookDeadLetter(ctx context.Context, topic string, subscriptionID uuid.UUID, url string, payload []byte, eventType models.EventType, reason error) error {
+	message := &sarama.ProducerMessage{
+		Topic: topic,
+		Key:   sarama.StringEncoder(subscriptionID.String()),
+		Value: sarama.ByteEncoder(payload),
+		Headers: []sarama.RecordHeader{
+			{Key: []byte("dlq_reason"), Value: []byte(reason.Error())},
+			{Key: []byte("event_type"), Value: []byte(eventType)},
+			{Key: []byte("subscription_id"), Value: []byte(subscriptionID.String())},
+			{Key: []byte("webhook_url"), Value: []byte(url)},
+		},
+	}
+
+	if _, _, err := p.send(ctx, message); err != nil {
+		return fmt.Errorf("failed to send webhook to dead letter topic %s: %w", topic, err)
+	}
+
+	p.log.WithField("topic", topic).
+		WithField("subscription_id", subscriptionID).
+		WithField("event_type", eventType).
+		Warn("Webhook sent to dead letter topic")
+
+	return nil
 }
 
-// publishEvent публикует событие в указанный топик
-func (p *Producer) publishEvent(topic string, event models.Event) error {
+// publishEvent публикует событие в указанный топик, если для типа события
+// не настроен отдельный топик
+func (p *Producer) publishEvent(ctx context.Context, topic string, event models.Event) error {
+	topic = p.topics.Resolve(string(event.Type), topic)
+
 	data, err := json.Marshal(event)
 	if err != nil {
 		return fmt.Errorf("failed to marshal event: %w", err)
@@ -156,7 +326,7 @@ func (p *Producer) publishEvent(topic string, event models.Event) error {
 		},
 	}
 
-	partition, offset, err := p.producer.SendMessage(message)
+	partition, offset, err := p.send(ctx, message)
 	if err != nil {
 		return fmt.Errorf("failed to send message to topic %s: %w", topic, err)
 	}
@@ -170,3 +340,33 @@ func (p *Producer) publishEvent(topic string, event models.Event) error {
 
 	return nil
 }
+
+// send отправляет сообщение, ожидая подтверждения брокера не дольше publishTimeout и не дольше,
+// чем живет контекст. Синхронный producer нельзя прервать, поэтому по истечении ожидания отправка
+// продолжается в фоне, а вызывающий получает ErrPublishTimeout и может продолжить работу
+func (p *Producer) send(ctx context.Context, message *sarama.ProducerMessage) (int32, int64, error) {
+	if p.publishTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.publishTimeout)
+		defer cancel()
+	}
+
+	type sendResult struct {
+		partition int32
+		offset    int64
+		err       error
+	}
+	done := make(chan sendResult, 1)
+	go func() {
+		partition, offset, err := p.producer.SendMessage(message)
+		done <- sendResult{partition: partition, offset: offset, err: err}
+	}()
+
+	select {
+	case result := <-done:
+		return result.partition, result.offset, result.err
+	case <-ctx.Done():
+		p.log.WithField("topic", message.Topic).Warn("Kafka publish timed out, message is still being sent in background")
+		return 0, 0, fmt.Errorf("%w: %v", ErrPublishTimeout, ctx.Err())
+	}
+}
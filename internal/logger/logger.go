@@ -1,8 +1,10 @@
 package logger
 
 import (
+	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 
 	"delivery-system/internal/config"
 
@@ -12,6 +14,11 @@ import (
 // Logger представляет логгер приложения
 type Logger struct {
 	*logrus.Logger
+
+	// Файл логов, если он настроен; fileErr - ошибка его открытия при запуске
+	filePath string
+	file     *rotatingFile
+	fileErr  error
 }
 
 // New создает новый экземпляр логгера
@@ -37,17 +44,50 @@ func New(cfg *config.LoggerConfig) *Logger {
 		})
 	}
 
+	logger := &Logger{Logger: log, filePath: cfg.File}
+
+	// Маскирование персональных данных
+	if cfg.RedactPII {
+		log.AddHook(newRedactHook())
+	}
+
 	// Настройка вывода в файл
 	if cfg.File != "" {
-		file, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+		file, err := newRotatingFile(cfg.File, cfg.MaxSizeMB, cfg.MaxBackups, cfg.MaxAgeDays)
 		if err == nil {
+			logger.file = file
 			log.SetOutput(io.MultiWriter(os.Stdout, file))
 		} else {
+			logger.fileErr = err
 			log.WithError(err).Error("Failed to open log file, using stdout only")
 		}
 	}
 
-	return &Logger{Logger: log}
+	return logger
+}
+
+// CheckWritable проверяет, что логи пишутся в настроенный файл: файл был открыт,
+// последняя запись прошла успешно и в каталог логов можно писать (нужно для ротации).
+// Без файла логов всегда возвращает nil
+func (l *Logger) CheckWritable() error {
+	if l.filePath == "" {
+		return nil
+	}
+	if l.fileErr != nil {
+		return l.fileErr
+	}
+	if err := l.file.lastError(); err != nil {
+		return fmt.Errorf("failed to write log file: %w", err)
+	}
+
+	probe, err := os.CreateTemp(filepath.Dir(l.filePath), ".log-healthcheck-*")
+	if err != nil {
+		return fmt.Errorf("log directory is not writable: %w", err)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	return nil
 }
 
 // WithField добавляет поле к логгеру
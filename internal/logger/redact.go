@@ -0,0 +1,70 @@
+package logger
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// piiFields поля логов с персональными данными клиентов и курьеров
+var piiFields = []string{
+	"customer_name",
+	"customer_phone",
+	"delivery_address",
+	"courier_name",
+	"phone",
+	"client", // IP адрес или ID пользователя в логах лимитера
+}
+
+// redactHook заменяет значения полей с персональными данными их HMAC-хешем.
+// Ключ хеша генерируется при запуске, поэтому одинаковые значения совпадают в пределах
+// работы одного процесса, но не могут быть подобраны по хешу из архива логов
+type redactHook struct {
+	fields map[string]bool
+	key    []byte
+}
+
+// newRedactHook создает hook маскирования персональных данных со случайным ключом.
+// Если ключ получить не удалось, значения заменяются без хеша
+func newRedactHook() *redactHook {
+	fields := make(map[string]bool, len(piiFields))
+	for _, field := range piiFields {
+		fields[field] = true
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		key = nil
+	}
+
+	return &redactHook{fields: fields, key: key}
+}
+
+// Levels реализует logrus.Hook: маскирование применяется на всех уровнях
+func (h *redactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire реализует logrus.Hook. logrus передает hook копию записи, поэтому исходные поля не меняются
+func (h *redactHook) Fire(entry *logrus.Entry) error {
+	for key, value := range entry.Data {
+		if h.fields[key] && value != nil {
+			entry.Data[key] = h.redact(fmt.Sprint(value))
+		}
+	}
+	return nil
+}
+
+// redact возвращает сокращенный HMAC-хеш значения
+func (h *redactHook) redact(value string) string {
+	if h.key == nil {
+		return "redacted"
+	}
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte(value))
+	return "redacted:" + hex.EncodeToString(mac.Sum(nil))[:12]
+}
@@ -0,0 +1,147 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat формат метки времени в имени архивного файла лога
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// rotatingFile представляет файл лога, который архивируется при достижении максимального размера.
+// Архивы получают имя "<файл>.<время>" и удаляются сверх MaxBackups или старше MaxAgeDays
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+	maxAge     time.Duration
+
+	mu      sync.Mutex
+	file    *os.File
+	size    int64
+	lastErr error
+}
+
+// newRotatingFile открывает файл лога на дозапись. Нулевые ограничения отключают соответствующую очистку
+func newRotatingFile(path string, maxSizeMB, maxBackups, maxAgeDays int) (*rotatingFile, error) {
+	r := &rotatingFile{
+		path:       path,
+		maxSize:    int64(maxSizeMB) * 1024 * 1024,
+		maxBackups: maxBackups,
+		maxAge:     time.Duration(maxAgeDays) * 24 * time.Hour,
+	}
+
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Write записывает данные в файл, предварительно архивируя его, если запись превысит максимальный размер
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			r.lastErr = err
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	r.lastErr = err
+	return n, err
+}
+
+// lastError возвращает ошибку последней записи, nil если запись прошла успешно
+func (r *rotatingFile) lastError() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.lastErr
+}
+
+// open открывает файл лога и запоминает его текущий размер
+func (r *rotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+// rotate переименовывает текущий файл в архив, открывает новый и удаляет устаревшие архивы
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+
+	backup := r.path + "." + time.Now().Format(backupTimeFormat)
+	if err := os.Rename(r.path, backup); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+
+	if err := r.open(); err != nil {
+		return err
+	}
+
+	r.removeOldBackups()
+	return nil
+}
+
+// removeOldBackups удаляет архивы сверх maxBackups и старше maxAge
+func (r *rotatingFile) removeOldBackups() {
+	if r.maxBackups <= 0 && r.maxAge <= 0 {
+		return
+	}
+
+	matches, err := filepath.Glob(r.path + ".*")
+	if err != nil {
+		return
+	}
+
+	type backup struct {
+		path      string
+		createdAt time.Time
+	}
+
+	prefix := filepath.Base(r.path) + "."
+	var backups []backup
+	for _, match := range matches {
+		createdAt, err := time.ParseInLocation(backupTimeFormat, strings.TrimPrefix(filepath.Base(match), prefix), time.Local)
+		if err == nil {
+			backups = append(backups, backup{path: match, createdAt: createdAt})
+		}
+	}
+
+	// Новые архивы в конце
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].createdAt.Before(backups[j].createdAt)
+	})
+
+	cutoff := time.Now().Add(-r.maxAge)
+	for i, b := range backups {
+		expired := r.maxAge > 0 && b.createdAt.Before(cutoff)
+		excess := r.maxBackups > 0 && i < len(backups)-r.maxBackups
+		if expired || excess {
+			os.Remove(b.path)
+		}
+	}
+}
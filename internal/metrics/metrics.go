@@ -0,0 +1,275 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Типы метрик в формате Prometheus
+const (
+	typeGauge     = "gauge"
+	typeCounter   = "counter"
+	typeHistogram = "histogram"
+)
+
+// DefaultBuckets границы корзин гистограммы по умолчанию, в секундах
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// collector представляет метрику, которую реестр умеет вывести
+type collector interface {
+	write(sb *strings.Builder)
+}
+
+// Registry хранит зарегистрированные метрики и отдает их в текстовом формате Prometheus
+type Registry struct {
+	mu      sync.RWMutex
+	metrics []collector
+}
+
+// NewRegistry создает новый реестр метрик
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// NewGauge регистрирует метрику-gauge с указанными именами меток
+func (r *Registry) NewGauge(name, help string, labels ...string) *Vec {
+	return r.register(name, help, typeGauge, labels)
+}
+
+// NewCounter регистрирует метрику-счетчик с указанными именами меток
+func (r *Registry) NewCounter(name, help string, labels ...string) *Vec {
+	return r.register(name, help, typeCounter, labels)
+}
+
+// NewHistogram регистрирует гистограмму с указанными границами корзин и именами меток.
+// Если границы не переданы, используются DefaultBuckets
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	h := &HistogramVec{
+		name:    name,
+		help:    help,
+		buckets: buckets,
+		labels:  labels,
+		samples: make(map[string]*histogramSample),
+	}
+
+	r.add(h)
+	return h
+}
+
+func (r *Registry) register(name, help, metricType string, labels []string) *Vec {
+	v := &Vec{
+		name:    name,
+		help:    help,
+		typ:     metricType,
+		labels:  labels,
+		samples: make(map[string]*sample),
+	}
+
+	r.add(v)
+	return v
+}
+
+func (r *Registry) add(c collector) {
+	r.mu.Lock()
+	r.metrics = append(r.metrics, c)
+	r.mu.Unlock()
+}
+
+// WriteTo записывает все метрики в текстовом формате Prometheus
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var sb strings.Builder
+	for _, v := range r.metrics {
+		v.write(&sb)
+	}
+
+	n, err := io.WriteString(w, sb.String())
+	return int64(n), err
+}
+
+// Handler возвращает HTTP обработчик для эндпоинта /metrics
+func (r *Registry) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteTo(w)
+	}
+}
+
+// Vec представляет метрику с набором значений по меткам
+type Vec struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+
+	mu      sync.RWMutex
+	samples map[string]*sample
+}
+
+type sample struct {
+	labelValues []string
+	value       float64
+}
+
+// Set устанавливает значение метрики для указанных значений меток
+func (v *Vec) Set(value float64, labelValues ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.get(labelValues).value = value
+}
+
+// Add увеличивает значение метрики для указанных значений меток
+func (v *Vec) Add(delta float64, labelValues ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.get(labelValues).value += delta
+}
+
+// Inc увеличивает значение метрики на единицу
+func (v *Vec) Inc(labelValues ...string) {
+	v.Add(1, labelValues...)
+}
+
+// Reset удаляет все накопленные значения метрики
+func (v *Vec) Reset() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.samples = make(map[string]*sample)
+}
+
+// get возвращает значение для набора меток, создавая его при необходимости.
+// Вызывающий должен держать блокировку на запись.
+func (v *Vec) get(labelValues []string) *sample {
+	key := strings.Join(labelValues, "\xff")
+	s, ok := v.samples[key]
+	if !ok {
+		s = &sample{labelValues: append([]string(nil), labelValues...)}
+		v.samples[key] = s
+	}
+	return s
+}
+
+// write выводит метрику в текстовом формате Prometheus
+func (v *Vec) write(sb *strings.Builder) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	fmt.Fprintf(sb, "# HELP %s %s\n", v.name, v.help)
+	fmt.Fprintf(sb, "# TYPE %s %s\n", v.name, v.typ)
+
+	keys := make([]string, 0, len(v.samples))
+	for key := range v.samples {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := v.samples[key]
+		sb.WriteString(v.name)
+		sb.WriteString(formatLabels(v.labels, s.labelValues))
+		fmt.Fprintf(sb, " %g\n", s.value)
+	}
+}
+
+// HistogramVec представляет гистограмму с набором распределений по меткам
+type HistogramVec struct {
+	name    string
+	help    string
+	buckets []float64
+	labels  []string
+
+	mu      sync.RWMutex
+	samples map[string]*histogramSample
+}
+
+type histogramSample struct {
+	labelValues []string
+	counts      []uint64
+	count       uint64
+	sum         float64
+}
+
+// Observe добавляет наблюдение в гистограмму для указанных значений меток
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := strings.Join(labelValues, "\xff")
+	s, ok := h.samples[key]
+	if !ok {
+		s = &histogramSample{
+			labelValues: append([]string(nil), labelValues...),
+			counts:      make([]uint64, len(h.buckets)),
+		}
+		h.samples[key] = s
+	}
+
+	for i, bound := range h.buckets {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += value
+}
+
+// write выводит гистограмму в текстовом формате Prometheus
+func (h *HistogramVec) write(sb *strings.Builder) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	fmt.Fprintf(sb, "# HELP %s %s\n", h.name, h.help)
+	fmt.Fprintf(sb, "# TYPE %s %s\n", h.name, typeHistogram)
+
+	keys := make([]string, 0, len(h.samples))
+	for key := range h.samples {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	bucketLabels := append(append([]string(nil), h.labels...), "le")
+	for _, key := range keys {
+		s := h.samples[key]
+		for i, bound := range h.buckets {
+			values := append(append([]string(nil), s.labelValues...), strconv.FormatFloat(bound, 'g', -1, 64))
+			fmt.Fprintf(sb, "%s_bucket%s %d\n", h.name, formatLabels(bucketLabels, values), s.counts[i])
+		}
+		values := append(append([]string(nil), s.labelValues...), "+Inf")
+		fmt.Fprintf(sb, "%s_bucket%s %d\n", h.name, formatLabels(bucketLabels, values), s.count)
+		fmt.Fprintf(sb, "%s_sum%s %g\n", h.name, formatLabels(h.labels, s.labelValues), s.sum)
+		fmt.Fprintf(sb, "%s_count%s %d\n", h.name, formatLabels(h.labels, s.labelValues), s.count)
+	}
+}
+
+// formatLabels форматирует метки в виде {name="value",...}, пустая строка если меток нет
+func formatLabels(labels, labelValues []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(labels))
+	for i, label := range labels {
+		value := ""
+		if i < len(labelValues) {
+			value = labelValues[i]
+		}
+		pairs = append(pairs, fmt.Sprintf("%s=%q", label, value))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MinAddressLength минимальная длина адреса доставки в символах после нормализации
+const MinAddressLength = 5
+
+// NormalizeAddress приводит адрес доставки к каноническому виду: убирает пробелы
+// по краям, схлопывает повторяющиеся пробелы и пробелы перед запятыми.
+// Возвращает ошибку для пустых и заведомо некорректных адресов
+func NormalizeAddress(address string) (string, error) {
+	normalized := strings.Join(strings.Fields(address), " ")
+	normalized = strings.ReplaceAll(normalized, " ,", ",")
+	normalized = strings.Trim(normalized, " ,")
+
+	if normalized == "" {
+		return "", fmt.Errorf("delivery address is required")
+	}
+
+	if utf8.RuneCountInString(normalized) < MinAddressLength {
+		return "", fmt.Errorf("delivery address must be at least %d characters", MinAddressLength)
+	}
+
+	if !strings.ContainsFunc(normalized, unicode.IsLetter) {
+		return "", fmt.Errorf("delivery address must contain a street or place name")
+	}
+
+	return normalized, nil
+}
@@ -15,12 +15,19 @@ const (
 	CourierStatusBusy      CourierStatus = "busy"
 )
 
+// IsValid проверяет, что статус курьера входит в список известных
+func (s CourierStatus) IsValid() bool {
+	return s == CourierStatusOffline || s == CourierStatusAvailable || s == CourierStatusBusy
+}
+
 // Courier представляет курьера в системе
 type Courier struct {
 	ID         uuid.UUID     `json:"id" db:"id"`
 	Name       string        `json:"name" db:"name"`
 	Phone      string        `json:"phone" db:"phone"`
 	Status     CourierStatus `json:"status" db:"status"`
+	Active     bool          `json:"active" db:"active"`
+	Skills     []string      `json:"skills" db:"skills"`
 	CurrentLat *float64      `json:"current_lat,omitempty" db:"current_lat"`
 	CurrentLon *float64      `json:"current_lon,omitempty" db:"current_lon"`
 	CreatedAt  time.Time     `json:"created_at" db:"created_at"`
@@ -28,10 +35,107 @@ type Courier struct {
 	LastSeenAt *time.Time    `json:"last_seen_at,omitempty" db:"last_seen_at"`
 }
 
+// CourierStats представляет статистику работы курьера
+type CourierStats struct {
+	DeliveredOrders int   `json:"delivered_orders"`
+	TotalRevenue    Money `json:"total_revenue"`
+}
+
+// CourierDetails представляет курьера с дополнительной статистикой
+type CourierDetails struct {
+	Courier
+	Stats *CourierStats `json:"stats,omitempty"`
+}
+
+// CourierWithDistance представляет курьера с расстоянием до точки поиска.
+// Расстояние вычисляется при запросе и не хранится в базе данных.
+// Assignable и AssignableAt заполняются только поиском курьеров рядом с точкой:
+// AssignableAt - окончание периода ожидания курьера, недавно вернувшегося из busy в available
+type CourierWithDistance struct {
+	Courier
+	DistanceKm   float64    `json:"distance_km"`
+	Assignable   bool       `json:"assignable"`
+	AssignableAt *time.Time `json:"assignable_at,omitempty"`
+}
+
+// AutoAssignPreview представляет результат подбора курьера для заказа без назначения.
+// Candidate - курьер, которого выбрало бы автоматическое назначение, Alternatives - следующие по порядку
+type AutoAssignPreview struct {
+	OrderID      uuid.UUID              `json:"order_id"`
+	Candidate    *CourierWithDistance   `json:"candidate"`
+	Alternatives []*CourierWithDistance `json:"alternatives"`
+}
+
+// AutoAssignResult представляет результат автоматического назначения курьера.
+// Если свободного курьера нет, Courier пуст, а заказ поставлен в очередь (Queued)
+type AutoAssignResult struct {
+	OrderID uuid.UUID            `json:"order_id"`
+	Courier *CourierWithDistance `json:"courier,omitempty"`
+	Queued  bool                 `json:"queued"`
+}
+
+// OrderOffer представляет предложение нового заказа курьеру, находящемуся рядом с точкой забора
+type OrderOffer struct {
+	OrderID         uuid.UUID `json:"order_id"`
+	DeliveryAddress string    `json:"delivery_address"`
+	TotalAmount     Money     `json:"total_amount"`
+	PickupLat       float64   `json:"pickup_lat"`
+	PickupLon       float64   `json:"pickup_lon"`
+	DistanceKm      float64   `json:"distance_km"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// CourierDelivery представляет доставленный курьером заказ в истории доставок
+type CourierDelivery struct {
+	OrderID         uuid.UUID  `json:"order_id"`
+	DeliveryAddress string     `json:"delivery_address"`
+	TotalAmount     Money      `json:"total_amount"`
+	DeliveryCost    *Money     `json:"delivery_cost,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	PickedUpAt      *time.Time `json:"picked_up_at,omitempty"`
+	DeliveredAt     time.Time  `json:"delivered_at"`
+	// DeliveryDurationSeconds время от передачи заказа в доставку до вручения, nil если момент передачи неизвестен
+	DeliveryDurationSeconds *int64 `json:"delivery_duration_seconds,omitempty"`
+}
+
+// CourierDeliveryTotals представляет итоги по всем доставкам, подходящим под фильтр, а не только по странице
+type CourierDeliveryTotals struct {
+	Count                          int      `json:"count"`
+	TotalAmount                    Money    `json:"total_amount"`
+	AverageDeliveryDurationSeconds *float64 `json:"average_delivery_duration_seconds,omitempty"`
+}
+
+// CourierDeliveries представляет страницу истории доставок курьера с итогами
+type CourierDeliveries struct {
+	Deliveries []*CourierDelivery    `json:"deliveries"`
+	Totals     CourierDeliveryTotals `json:"totals"`
+}
+
+// CourierReportRow представляет показатели курьера за период отчета об эффективности
+type CourierReportRow struct {
+	CourierID       uuid.UUID `json:"courier_id"`
+	Name            string    `json:"name"`
+	DeliveredOrders int       `json:"delivered_orders"`
+	// AverageDeliveryDurationSeconds среднее время от передачи в доставку до вручения, нет - если доставок не было
+	AverageDeliveryDurationSeconds *float64 `json:"average_delivery_duration_seconds,omitempty"`
+	// TotalEarnings сумма стоимости доставки доставленных заказов
+	TotalEarnings   Money `json:"total_earnings"`
+	CancelledOrders int   `json:"cancelled_orders"`
+}
+
+// CourierShift представляет рабочую смену курьера
+type CourierShift struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	CourierID uuid.UUID  `json:"courier_id" db:"courier_id"`
+	StartedAt time.Time  `json:"started_at" db:"started_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty" db:"ended_at"`
+}
+
 // CreateCourierRequest представляет запрос на создание курьера
 type CreateCourierRequest struct {
-	Name  string `json:"name"`
-	Phone string `json:"phone"`
+	Name   string   `json:"name"`
+	Phone  string   `json:"phone"`
+	Skills []string `json:"skills,omitempty"`
 }
 
 // UpdateCourierStatusRequest представляет запрос на обновление статуса курьера
@@ -48,3 +152,24 @@ type CourierLocation struct {
 	Lon       float64   `json:"lon"`
 	Timestamp time.Time `json:"timestamp"`
 }
+
+// LocationPoint представляет точку маршрута курьера, записанную приложением
+type LocationPoint struct {
+	Lat       float64   `json:"lat"`
+	Lon       float64   `json:"lon"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// BatchLocationUpdateRequest представляет пакет точек, накопленных приложением курьера без связи.
+// Точки передаются в порядке возрастания времени
+type BatchLocationUpdateRequest struct {
+	Points []LocationPoint `json:"points"`
+}
+
+// BatchLocationUpdateResponse представляет результат сохранения пакета точек
+type BatchLocationUpdateResponse struct {
+	Accepted int `json:"accepted"`
+	// Latest самая новая точка пакета; LatestApplied - стала ли она текущим местоположением курьера
+	Latest        LocationPoint `json:"latest"`
+	LatestApplied bool          `json:"latest_applied"`
+}
@@ -0,0 +1,49 @@
+package models
+
+// ErrorCode представляет машиночитаемый код ошибки в ответах API
+type ErrorCode string
+
+// Общие коды ошибок, соответствующие HTTP статусу ответа
+const (
+	ErrorCodeBadRequest           ErrorCode = "BAD_REQUEST"
+	ErrorCodeValidationFailed     ErrorCode = "VALIDATION_FAILED"
+	ErrorCodeUnauthorized         ErrorCode = "UNAUTHORIZED"
+	ErrorCodeForbidden            ErrorCode = "FORBIDDEN"
+	ErrorCodeNotFound             ErrorCode = "NOT_FOUND"
+	ErrorCodeMethodNotAllowed     ErrorCode = "METHOD_NOT_ALLOWED"
+	ErrorCodeConflict             ErrorCode = "CONFLICT"
+	ErrorCodePayloadTooLarge      ErrorCode = "PAYLOAD_TOO_LARGE"
+	ErrorCodeUnprocessable        ErrorCode = "UNPROCESSABLE_ENTITY"
+	ErrorCodePreconditionRequired ErrorCode = "PRECONDITION_REQUIRED"
+	ErrorCodeRateLimited          ErrorCode = "RATE_LIMITED"
+	ErrorCodeInternal             ErrorCode = "INTERNAL_ERROR"
+	ErrorCodeServiceUnavailable   ErrorCode = "SERVICE_UNAVAILABLE"
+)
+
+// Коды ошибок бизнес-логики
+const (
+	ErrorCodeOrderNotFound               ErrorCode = "ORDER_NOT_FOUND"
+	ErrorCodeOrderItemNotFound           ErrorCode = "ORDER_ITEM_NOT_FOUND"
+	ErrorCodeOrderNotAssigned            ErrorCode = "ORDER_NOT_ASSIGNED"
+	ErrorCodeOrderAlreadyAssigned        ErrorCode = "ORDER_ALREADY_ASSIGNED"
+	ErrorCodeOrderVersionMismatch        ErrorCode = "ORDER_VERSION_MISMATCH"
+	ErrorCodeOrderLastItem               ErrorCode = "ORDER_LAST_ITEM"
+	ErrorCodeOrderItemPriceFixed         ErrorCode = "ORDER_ITEM_PRICE_FIXED"
+	ErrorCodeDuplicateOrderInProgress    ErrorCode = "DUPLICATE_ORDER_IN_PROGRESS"
+	ErrorCodeInvalidTransition           ErrorCode = "INVALID_TRANSITION"
+	ErrorCodeCourierNotFound             ErrorCode = "COURIER_NOT_FOUND"
+	ErrorCodeCourierNotAvailable         ErrorCode = "COURIER_NOT_AVAILABLE"
+	ErrorCodeCourierDeactivated          ErrorCode = "COURIER_DEACTIVATED"
+	ErrorCodeCourierAlreadyActive        ErrorCode = "COURIER_ALREADY_ACTIVE"
+	ErrorCodeCourierHasActiveOrders      ErrorCode = "COURIER_HAS_ACTIVE_ORDERS"
+	ErrorCodeCourierTooFar               ErrorCode = "COURIER_TOO_FAR"
+	ErrorCodeCourierMissingSkills        ErrorCode = "COURIER_MISSING_SKILLS"
+	ErrorCodeDeliveryOutOfRange          ErrorCode = "DELIVERY_OUT_OF_RANGE"
+	ErrorCodePickupLocationUnknown       ErrorCode = "PICKUP_LOCATION_UNKNOWN"
+	ErrorCodeDeliveryRouteUnknown        ErrorCode = "DELIVERY_ROUTE_UNKNOWN"
+	ErrorCodeDeliveryCostOverridden      ErrorCode = "DELIVERY_COST_OVERRIDDEN"
+	ErrorCodeProductNotFound             ErrorCode = "PRODUCT_NOT_FOUND"
+	ErrorCodeShiftAlreadyStarted         ErrorCode = "SHIFT_ALREADY_STARTED"
+	ErrorCodeShiftNotStarted             ErrorCode = "SHIFT_NOT_STARTED"
+	ErrorCodeWebhookSubscriptionNotFound ErrorCode = "WEBHOOK_SUBSCRIPTION_NOT_FOUND"
+)
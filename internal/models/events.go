@@ -1,6 +1,8 @@
 package models
 
 import (
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -10,13 +12,42 @@ import (
 type EventType string
 
 const (
-	EventTypeOrderCreated         EventType = "order.created"
-	EventTypeOrderStatusChanged   EventType = "order.status_changed"
-	EventTypeCourierAssigned      EventType = "courier.assigned"
-	EventTypeCourierStatusChanged EventType = "courier.status_changed"
-	EventTypeLocationUpdated      EventType = "location.updated"
+	EventTypeOrderCreated           EventType = "order.created"
+	EventTypeOrderStatusChanged     EventType = "order.status_changed"
+	EventTypeOrderCancelled         EventType = "order.cancelled"
+	EventTypeOrderUnassignedTimeout EventType = "order.unassigned_timeout"
+	EventTypeOrderAmountChanged     EventType = "order.amount_changed"
+	EventTypeOrderReadyForPickup    EventType = "order.ready_for_pickup"
+	EventTypeOrderSLABreached       EventType = "order.sla_breached"
+	EventTypeCourierAssigned        EventType = "courier.assigned"
+	EventTypeCourierStatusChanged   EventType = "courier.status_changed"
+	EventTypeLocationUpdated        EventType = "location.updated"
 )
 
+// EventTypes список всех типов событий системы
+var EventTypes = []EventType{
+	EventTypeOrderCreated,
+	EventTypeOrderStatusChanged,
+	EventTypeOrderCancelled,
+	EventTypeOrderUnassignedTimeout,
+	EventTypeOrderAmountChanged,
+	EventTypeOrderReadyForPickup,
+	EventTypeOrderSLABreached,
+	EventTypeCourierAssigned,
+	EventTypeCourierStatusChanged,
+	EventTypeLocationUpdated,
+}
+
+// IsValid проверяет, что тип события известен системе
+func (t EventType) IsValid() bool {
+	for _, known := range EventTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
 // Event представляет базовое событие
 type Event struct {
 	ID        uuid.UUID   `json:"id"`
@@ -25,13 +56,29 @@ type Event struct {
 	Data      interface{} `json:"data"`
 }
 
+// DecodeData декодирует данные события в структуру конкретного типа события.
+// После чтения из Kafka Data содержит map[string]interface{}, поэтому данные перекодируются через JSON
+func (e *Event) DecodeData(dest interface{}) error {
+	data, err := json.Marshal(e.Data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event data: %w", err)
+	}
+	if err := json.Unmarshal(data, dest); err != nil {
+		return fmt.Errorf("failed to decode %s event data: %w", e.Type, err)
+	}
+	return nil
+}
+
 // OrderCreatedEvent представляет событие создания заказа
 type OrderCreatedEvent struct {
 	OrderID         uuid.UUID `json:"order_id"`
 	CustomerName    string    `json:"customer_name"`
 	CustomerPhone   string    `json:"customer_phone"`
 	DeliveryAddress string    `json:"delivery_address"`
-	TotalAmount     float64   `json:"total_amount"`
+	TotalAmount     Money     `json:"total_amount"`
+	PickupLat       *float64  `json:"pickup_lat,omitempty"`
+	PickupLon       *float64  `json:"pickup_lon,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
 }
 
 // OrderStatusChangedEvent представляет событие изменения статуса заказа
@@ -43,6 +90,48 @@ type OrderStatusChangedEvent struct {
 	Timestamp time.Time   `json:"timestamp"`
 }
 
+// OrderCancelledEvent представляет событие отмены заказа
+type OrderCancelledEvent struct {
+	OrderID       uuid.UUID          `json:"order_id"`
+	Reason        CancellationReason `json:"reason,omitempty"`
+	ReasonComment string             `json:"reason_comment,omitempty"`
+	Timestamp     time.Time          `json:"timestamp"`
+}
+
+// OrderUnassignedTimeoutEvent представляет событие о заказе, слишком долго ожидающем назначения курьера
+type OrderUnassignedTimeoutEvent struct {
+	OrderID   uuid.UUID `json:"order_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Priority  int       `json:"priority"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// OrderReadyForPickupEvent представляет событие готовности заказа к выдаче курьеру
+type OrderReadyForPickupEvent struct {
+	OrderID         uuid.UUID  `json:"order_id"`
+	CourierID       *uuid.UUID `json:"courier_id,omitempty"`
+	DeliveryAddress string     `json:"delivery_address"`
+	Timestamp       time.Time  `json:"timestamp"`
+}
+
+// OrderSLABreachedEvent представляет событие о заказе, не доставленном в установленный срок
+type OrderSLABreachedEvent struct {
+	OrderID   uuid.UUID   `json:"order_id"`
+	Status    OrderStatus `json:"status"`
+	CourierID *uuid.UUID  `json:"courier_id,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+	DueAt     time.Time   `json:"due_at"`
+	Timestamp time.Time   `json:"timestamp"`
+}
+
+// OrderAmountChangedEvent представляет событие изменения суммы заказа
+type OrderAmountChangedEvent struct {
+	OrderID   uuid.UUID `json:"order_id"`
+	OldAmount Money     `json:"old_amount"`
+	NewAmount Money     `json:"new_amount"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
 // CourierAssignedEvent представляет событие назначения курьера
 type CourierAssignedEvent struct {
 	OrderID   uuid.UUID `json:"order_id"`
@@ -0,0 +1,188 @@
+package models
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money представляет денежную сумму в копейках. Целочисленное представление
+// исключает накопление ошибок округления при суммировании позиций.
+// В JSON сумма передается десятичной строкой ("123.45"), в базе данных хранится в NUMERIC
+type Money int64
+
+// NewMoneyFromFloat создает сумму из значения в рублях с округлением до копеек
+func NewMoneyFromFloat(value float64) Money {
+	return Money(math.Round(value * 100))
+}
+
+// ParseMoney разбирает десятичную строку вида "123", "123.4" или "-123.45"
+func ParseMoney(value string) (Money, error) {
+	s := strings.TrimSpace(value)
+	if s == "" {
+		return 0, fmt.Errorf("invalid money value %q", value)
+	}
+
+	negative := false
+	if s[0] == '-' || s[0] == '+' {
+		negative = s[0] == '-'
+		s = s[1:]
+	}
+
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	if intPart == "" && fracPart == "" {
+		return 0, fmt.Errorf("invalid money value %q", value)
+	}
+	// После знака допускаются только цифры: strconv.ParseInt принял бы второй знак ("--5", "+-5")
+	if !isDigits(intPart) || !isDigits(fracPart) {
+		return 0, fmt.Errorf("invalid money value %q", value)
+	}
+	if len(fracPart) > 2 {
+		// Допускаем незначащие нули (например, из NUMERIC с большей точностью)
+		if strings.Trim(fracPart[2:], "0") != "" {
+			return 0, fmt.Errorf("money value %q has more than 2 decimal places", value)
+		}
+		fracPart = fracPart[:2]
+	}
+	for len(fracPart) < 2 {
+		fracPart += "0"
+	}
+	if intPart == "" {
+		intPart = "0"
+	}
+
+	units, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid money value %q", value)
+	}
+	cents, err := strconv.ParseInt(fracPart, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid money value %q", value)
+	}
+
+	if units > (math.MaxInt64-cents)/100 {
+		return 0, fmt.Errorf("money value %q is out of range", value)
+	}
+
+	total := units*100 + cents
+	if negative {
+		total = -total
+	}
+	return Money(total), nil
+}
+
+// isDigits сообщает, что строка состоит только из цифр ASCII
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Cents возвращает сумму в копейках
+func (m Money) Cents() int64 {
+	return int64(m)
+}
+
+// Float64 возвращает сумму в рублях. Используется только для отображения и метрик
+func (m Money) Float64() float64 {
+	return float64(m) / 100
+}
+
+// Mul возвращает сумму, умноженную на количество
+func (m Money) Mul(quantity int) Money {
+	return m * Money(quantity)
+}
+
+// String возвращает сумму в виде десятичной строки с двумя знаками после точки
+func (m Money) String() string {
+	sign := ""
+	v := int64(m)
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, v/100, v%100)
+}
+
+// MarshalJSON сериализует сумму в десятичную строку
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+// UnmarshalJSON принимает сумму как в виде строки, так и в виде числа
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	var raw string
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return err
+		}
+	} else {
+		raw = string(data)
+	}
+
+	// Числа в экспоненциальной записи разбираем как float
+	if strings.ContainsAny(raw, "eE") {
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid money value %q", raw)
+		}
+		*m = NewMoneyFromFloat(f)
+		return nil
+	}
+
+	parsed, err := ParseMoney(raw)
+	if err != nil {
+		return err
+	}
+
+	*m = parsed
+	return nil
+}
+
+// Scan реализует sql.Scanner для чтения значений NUMERIC
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*m = 0
+		return nil
+	case []byte:
+		parsed, err := ParseMoney(string(v))
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	case string:
+		parsed, err := ParseMoney(v)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	case int64:
+		*m = Money(v * 100)
+		return nil
+	case float64:
+		*m = NewMoneyFromFloat(v)
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+}
+
+// Value реализует driver.Valuer, передавая сумму в базу данных точной десятичной строкой
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
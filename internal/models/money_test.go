@@ -0,0 +1,51 @@
+package models
+
+import "testing"
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Money
+		wantErr bool
+	}{
+		{value: "5", want: 500},
+		{value: "5.5", want: 550},
+		{value: "5.05", want: 505},
+		{value: "-5", want: -500},
+		{value: "+5", want: 500},
+		{value: ".5", want: 50},
+		{value: "5.", want: 500},
+		{value: " 12.30 ", want: 1230},
+		{value: "1.2300", want: 123},
+		{value: "", wantErr: true},
+		{value: "-", wantErr: true},
+		{value: ".", wantErr: true},
+		{value: "--5", wantErr: true},
+		{value: "+-5", wantErr: true},
+		{value: "-+5", wantErr: true},
+		{value: "5.-5", wantErr: true},
+		{value: "5.+5", wantErr: true},
+		{value: "5-", wantErr: true},
+		{value: "1.234", wantErr: true},
+		{value: "1e3", wantErr: true},
+		{value: "92233720368547758.08", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseMoney(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseMoney(%q) = %v, expected error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseMoney(%q) returned error: %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Fatalf("ParseMoney(%q) = %d, want %d", tt.value, got, tt.want)
+			}
+		})
+	}
+}
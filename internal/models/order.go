@@ -1,6 +1,7 @@
 package models
 
 import (
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -19,19 +20,147 @@ const (
 	OrderStatusCancelled  OrderStatus = "cancelled"
 )
 
+// IsValid проверяет, что статус заказа входит в список известных
+func (s OrderStatus) IsValid() bool {
+	switch s {
+	case OrderStatusCreated, OrderStatusAccepted, OrderStatusPreparing, OrderStatusReady,
+		OrderStatusInDelivery, OrderStatusDelivered, OrderStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// orderTransitions перечисляет статусы, в которые заказ может перейти из текущего.
+// Назначение и снятие курьера (created <-> accepted) выполняются отдельными операциями
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusCreated:    {OrderStatusCancelled},
+	OrderStatusAccepted:   {OrderStatusPreparing, OrderStatusReady, OrderStatusCancelled},
+	OrderStatusPreparing:  {OrderStatusReady, OrderStatusCancelled},
+	OrderStatusReady:      {OrderStatusInDelivery, OrderStatusCancelled},
+	OrderStatusInDelivery: {OrderStatusDelivered, OrderStatusCancelled},
+}
+
+// CanTransitionTo сообщает, может ли заказ перейти из статуса s в статус next
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	return slices.Contains(orderTransitions[s], next)
+}
+
+// CancellationReason представляет причину отмены заказа
+type CancellationReason string
+
+const (
+	CancellationReasonCustomerRequest  CancellationReason = "customer_request"
+	CancellationReasonNoCourier        CancellationReason = "no_courier"
+	CancellationReasonRestaurantClosed CancellationReason = "restaurant_closed"
+	CancellationReasonOther            CancellationReason = "other"
+)
+
+// IsValid проверяет, что причина отмены входит в список известных
+func (r CancellationReason) IsValid() bool {
+	switch r {
+	case CancellationReasonCustomerRequest, CancellationReasonNoCourier,
+		CancellationReasonRestaurantClosed, CancellationReasonOther:
+		return true
+	}
+	return false
+}
+
+// OrderSort представляет порядок сортировки списка заказов
+type OrderSort string
+
+const (
+	OrderSortCreatedDesc OrderSort = "created_desc"
+	OrderSortCreatedAsc  OrderSort = "created_asc"
+)
+
+// IsValid проверяет, что порядок сортировки известен
+func (s OrderSort) IsValid() bool {
+	return s == OrderSortCreatedDesc || s == OrderSortCreatedAsc
+}
+
+// OrderFilter представляет условия выборки списка заказов. Пустые поля не ограничивают выборку
+type OrderFilter struct {
+	Statuses  []OrderStatus
+	CourierID *uuid.UUID
+	// Unassigned оставляет только заказы без курьера
+	Unassigned bool
+	// CreatedFrom оставляет только заказы, созданные не раньше указанного времени
+	CreatedFrom *time.Time
+	// Search поиск по имени клиента, телефону и адресу; без явной сортировки результаты упорядочены по релевантности
+	Search      string
+	SLABreached bool
+	Sort        OrderSort
+}
+
 // Order представляет заказ в системе
 type Order struct {
-	ID              uuid.UUID   `json:"id" db:"id"`
-	CustomerName    string      `json:"customer_name" db:"customer_name"`
-	CustomerPhone   string      `json:"customer_phone" db:"customer_phone"`
-	DeliveryAddress string      `json:"delivery_address" db:"delivery_address"`
-	Items           []OrderItem `json:"items"`
-	TotalAmount     float64     `json:"total_amount" db:"total_amount"`
-	Status          OrderStatus `json:"status" db:"status"`
-	CourierID       *uuid.UUID  `json:"courier_id,omitempty" db:"courier_id"`
-	CreatedAt       time.Time   `json:"created_at" db:"created_at"`
-	UpdatedAt       time.Time   `json:"updated_at" db:"updated_at"`
-	DeliveredAt     *time.Time  `json:"delivered_at,omitempty" db:"delivered_at"`
+	ID                  uuid.UUID           `json:"id" db:"id"`
+	CustomerName        string              `json:"customer_name" db:"customer_name"`
+	CustomerPhone       string              `json:"customer_phone" db:"customer_phone"`
+	DeliveryAddress     string              `json:"delivery_address" db:"delivery_address"`
+	Items               []OrderItem         `json:"items"`
+	TotalAmount         Money               `json:"total_amount" db:"total_amount"`
+	Status              OrderStatus         `json:"status" db:"status"`
+	Priority            int                 `json:"priority" db:"priority"`
+	CourierID           *uuid.UUID          `json:"courier_id,omitempty" db:"courier_id"`
+	CreatedAt           time.Time           `json:"created_at" db:"created_at"`
+	UpdatedAt           time.Time           `json:"updated_at" db:"updated_at"`
+	DeliveredAt         *time.Time          `json:"delivered_at,omitempty" db:"delivered_at"`
+	CancellationReason  *CancellationReason `json:"cancellation_reason,omitempty" db:"cancellation_reason"`
+	CancellationComment *string             `json:"cancellation_comment,omitempty" db:"cancellation_comment"`
+	PickupLat           *float64            `json:"pickup_lat,omitempty" db:"pickup_lat"`
+	PickupLon           *float64            `json:"pickup_lon,omitempty" db:"pickup_lon"`
+	DeliveryLat         *float64            `json:"delivery_lat,omitempty" db:"delivery_lat"`
+	DeliveryLon         *float64            `json:"delivery_lon,omitempty" db:"delivery_lon"`
+	Version             int                 `json:"version" db:"version"`
+	SLABreachedAt       *time.Time          `json:"sla_breached_at,omitempty" db:"sla_breached_at"`
+	// Стоимость доставки, nil если координаты точек забора и доставки неизвестны
+	DeliveryCost           *Money `json:"delivery_cost,omitempty" db:"delivery_cost"`
+	DeliveryCostOverridden bool   `json:"delivery_cost_overridden" db:"delivery_cost_overridden"`
+	// Навыки, которые должны быть у курьера, чтобы доставить заказ
+	RequiredSkills []string `json:"required_skills" db:"required_skills"`
+	// Подтверждение доставки, заполняется только при получении одного заказа
+	Proof *DeliveryProof `json:"proof,omitempty"`
+}
+
+// OrderCostBreakdown представляет расшифровку стоимости заказа, вычисленную по сохраненным полям.
+// Надбавки за спрос и скидки сервис пока не применяет, поэтому SurgeMultiplier всегда 1, а Discount - 0
+type OrderCostBreakdown struct {
+	ItemsSubtotal Money `json:"items_subtotal"`
+	// Стоимость доставки, nil если она не рассчитана
+	DeliveryCost    *Money  `json:"delivery_cost"`
+	SurgeMultiplier float64 `json:"surge_multiplier"`
+	Discount        Money   `json:"discount"`
+	GrandTotal      Money   `json:"grand_total"`
+}
+
+// CostBreakdown вычисляет расшифровку стоимости заказа: сумма позиций (total_amount)
+// плюс стоимость доставки за вычетом скидки
+func (o *Order) CostBreakdown() *OrderCostBreakdown {
+	breakdown := &OrderCostBreakdown{
+		ItemsSubtotal:   o.TotalAmount,
+		DeliveryCost:    o.DeliveryCost,
+		SurgeMultiplier: 1,
+		GrandTotal:      o.TotalAmount,
+	}
+	if o.DeliveryCost != nil {
+		breakdown.GrandTotal += *o.DeliveryCost
+	}
+	breakdown.GrandTotal -= breakdown.Discount
+	return breakdown
+}
+
+// OrderDetails представляет заказ с расшифровкой стоимости (GET /api/orders/{id}?include=breakdown)
+type OrderDetails struct {
+	Order
+	Breakdown *OrderCostBreakdown `json:"breakdown"`
+}
+
+// UnassignedOrder представляет заказ в очереди на назначение курьера (GET /api/orders/unassigned)
+type UnassignedOrder struct {
+	Order
+	// WaitSeconds сколько секунд заказ ожидает курьера с момента создания
+	WaitSeconds int64 `json:"wait_seconds"`
 }
 
 // OrderItem представляет товар в заказе
@@ -40,7 +169,59 @@ type OrderItem struct {
 	OrderID  uuid.UUID `json:"order_id" db:"order_id"`
 	Name     string    `json:"name" db:"name"`
 	Quantity int       `json:"quantity" db:"quantity"`
-	Price    float64   `json:"price" db:"price"`
+	Price    Money     `json:"price" db:"price"`
+	// Вес одной единицы товара в граммах, 0 если не указан
+	WeightGrams int `json:"weight_grams" db:"weight_grams"`
+	// SKU товара из каталога, пусто для позиций в свободной форме
+	SKU string `json:"sku,omitempty" db:"sku"`
+}
+
+// TotalWeightGrams возвращает суммарный вес позиций заказа с учетом количества
+func TotalWeightGrams(items []OrderItem) int {
+	total := 0
+	for _, item := range items {
+		total += item.WeightGrams * item.Quantity
+	}
+	return total
+}
+
+// BatchGetOrdersRequest представляет запрос на получение нескольких заказов
+type BatchGetOrdersRequest struct {
+	IDs []uuid.UUID `json:"ids"`
+}
+
+// BatchGetOrdersResponse представляет ответ с несколькими заказами
+type BatchGetOrdersResponse struct {
+	Orders   []*Order    `json:"orders"`
+	NotFound []uuid.UUID `json:"not_found"`
+}
+
+// BulkUpdateOrderStatusRequest представляет запрос на перевод нескольких заказов в один статус.
+// Причина отмены допускается только при переходе в статус "cancelled"
+type BulkUpdateOrderStatusRequest struct {
+	OrderIDs      []uuid.UUID         `json:"order_ids"`
+	Status        OrderStatus         `json:"status"`
+	Reason        *CancellationReason `json:"reason,omitempty"`
+	ReasonComment string              `json:"reason_comment,omitempty"`
+}
+
+// BulkOrderStatusResult представляет результат проверки перехода одного заказа.
+// Code и Message заполнены, если заказ не может перейти в запрошенный статус
+type BulkOrderStatusResult struct {
+	OrderID   uuid.UUID   `json:"order_id"`
+	OldStatus OrderStatus `json:"old_status,omitempty"`
+	CourierID *uuid.UUID  `json:"courier_id,omitempty"`
+	Code      ErrorCode   `json:"code,omitempty"`
+	Message   string      `json:"message,omitempty"`
+}
+
+// BulkUpdateOrderStatusResponse представляет результат массового изменения статуса.
+// Переходы применяются все вместе: если хотя бы один заказ не прошел проверку, Applied равен false
+// и ни один заказ не изменен
+type BulkUpdateOrderStatusResponse struct {
+	Applied bool                    `json:"applied"`
+	Status  OrderStatus             `json:"status"`
+	Results []BulkOrderStatusResult `json:"results"`
 }
 
 // CreateOrderRequest представляет запрос на создание заказа
@@ -49,17 +230,98 @@ type CreateOrderRequest struct {
 	CustomerPhone   string                   `json:"customer_phone"`
 	DeliveryAddress string                   `json:"delivery_address"`
 	Items           []CreateOrderItemRequest `json:"items"`
+	PickupLat       *float64                 `json:"pickup_lat,omitempty"`
+	PickupLon       *float64                 `json:"pickup_lon,omitempty"`
+	DeliveryLat     *float64                 `json:"delivery_lat,omitempty"`
+	DeliveryLon     *float64                 `json:"delivery_lon,omitempty"`
+	RequiredSkills  []string                 `json:"required_skills,omitempty"`
 }
 
-// CreateOrderItemRequest представляет запрос на создание товара в заказе
+// CreateOrderItemRequest представляет запрос на создание товара в заказе.
+// Для позиции с SKU название, цена и вес берутся из каталога товаров, а переданные клиентом игнорируются
 type CreateOrderItemRequest struct {
-	Name     string  `json:"name"`
-	Quantity int     `json:"quantity"`
-	Price    float64 `json:"price"`
+	SKU         string `json:"sku,omitempty"`
+	Name        string `json:"name"`
+	Quantity    int    `json:"quantity"`
+	Price       Money  `json:"price"`
+	WeightGrams int    `json:"weight_grams,omitempty"`
+}
+
+// Product представляет товар каталога
+type Product struct {
+	SKU         string `json:"sku" db:"sku"`
+	Name        string `json:"name" db:"name"`
+	Price       Money  `json:"price" db:"price"`
+	WeightGrams int    `json:"weight_grams" db:"weight_grams"`
+}
+
+// UpdateOrderItemRequest представляет запрос на изменение позиции заказа. Пустые поля не меняются
+type UpdateOrderItemRequest struct {
+	Quantity *int   `json:"quantity,omitempty"`
+	Price    *Money `json:"price,omitempty"`
 }
 
 // UpdateOrderStatusRequest представляет запрос на обновление статуса заказа
 type UpdateOrderStatusRequest struct {
-	Status    OrderStatus `json:"status"`
-	CourierID *uuid.UUID  `json:"courier_id,omitempty"`
+	Status OrderStatus `json:"status"`
+	// Курьер заказа; если не передан, текущее назначение не меняется
+	CourierID *uuid.UUID `json:"courier_id,omitempty"`
+	// Ожидаемая версия заказа; при несовпадении обновление отклоняется
+	Version *int `json:"version,omitempty"`
+
+	// Причина отмены, допускается только при переходе в статус "cancelled".
+	// Для причины "other" обязателен текстовый комментарий.
+	Reason        *CancellationReason `json:"reason,omitempty"`
+	ReasonComment string              `json:"reason_comment,omitempty"`
+}
+
+// DeliveryProof представляет подтверждение доставки, приложенное курьером
+type DeliveryProof struct {
+	ID           uuid.UUID  `json:"id" db:"id"`
+	OrderID      uuid.UUID  `json:"order_id" db:"order_id"`
+	CourierID    *uuid.UUID `json:"courier_id,omitempty" db:"courier_id"`
+	PhotoRef     string     `json:"photo_ref" db:"photo_ref"`
+	SignatureRef *string    `json:"signature_ref,omitempty" db:"signature_ref"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+}
+
+// SubmitDeliveryProofRequest представляет запрос на завершение доставки с подтверждением.
+// Ссылки указывают на заранее загруженные изображения (URL или ключ в хранилище)
+type SubmitDeliveryProofRequest struct {
+	PhotoRef     string  `json:"photo_ref"`
+	SignatureRef *string `json:"signature_ref,omitempty"`
+}
+
+// TimelineEventType представляет тип события в хронологии заказа
+type TimelineEventType string
+
+const (
+	TimelineEventCreated           TimelineEventType = "created"
+	TimelineEventStatusChanged     TimelineEventType = "status_changed"
+	TimelineEventCourierAssigned   TimelineEventType = "courier_assigned"
+	TimelineEventCourierUnassigned TimelineEventType = "courier_unassigned"
+	TimelineEventCourierLocation   TimelineEventType = "courier_location"
+	TimelineEventSLABreached       TimelineEventType = "sla_breached"
+	TimelineEventDeliveryProof     TimelineEventType = "delivery_proof_submitted"
+)
+
+// OrderTimelineEvent представляет событие жизненного цикла заказа. Заполнены только поля,
+// относящиеся к типу события
+type OrderTimelineEvent struct {
+	Type      TimelineEventType `json:"type"`
+	At        time.Time         `json:"at"`
+	OldStatus *OrderStatus      `json:"old_status,omitempty"`
+	Status    *OrderStatus      `json:"status,omitempty"`
+	CourierID *uuid.UUID        `json:"courier_id,omitempty"`
+	Lat       *float64          `json:"lat,omitempty"`
+	Lon       *float64          `json:"lon,omitempty"`
+}
+
+// OrderTimeline представляет хронологию заказа: события из истории статусов, назначения курьеров,
+// местоположения курьера во время выполнения заказа и подтверждение доставки, упорядоченные по времени
+type OrderTimeline struct {
+	OrderID uuid.UUID             `json:"order_id"`
+	Events  []*OrderTimelineEvent `json:"events"`
+	// LocationsTruncated означает, что в хронологию попали не все местоположения курьера
+	LocationsTruncated bool `json:"locations_truncated"`
 }
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Ограничения навыков курьера и навыков, требуемых заказом
+const (
+	MaxSkills      = 20
+	MaxSkillLength = 32
+)
+
+// ValidateSkills проверяет список навыков: не больше MaxSkills неповторяющихся значений
+// длиной до MaxSkillLength из строчных латинских букв, цифр, "_" и "-"
+func ValidateSkills(skills []string) error {
+	if len(skills) > MaxSkills {
+		return fmt.Errorf("cannot contain more than %d skills", MaxSkills)
+	}
+
+	for i, skill := range skills {
+		if !validSkill(skill) {
+			return fmt.Errorf("skill %q must be 1 to %d characters of a-z, 0-9, _ or -", skill, MaxSkillLength)
+		}
+		if slices.Contains(skills[:i], skill) {
+			return fmt.Errorf("skill %q is listed more than once", skill)
+		}
+	}
+	return nil
+}
+
+// validSkill проверяет формат одного навыка
+func validSkill(skill string) bool {
+	if skill == "" || len(skill) > MaxSkillLength {
+		return false
+	}
+	for _, r := range skill {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '_' && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// HasSkills сообщает, что у курьера есть все требуемые навыки
+func (c *Courier) HasSkills(required []string) bool {
+	for _, skill := range required {
+		if !slices.Contains(c.Skills, skill) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebhookSubscription представляет подписку внешней системы на события
+type WebhookSubscription struct {
+	ID         uuid.UUID   `json:"id" db:"id"`
+	URL        string      `json:"url" db:"url"`
+	EventTypes []EventType `json:"event_types" db:"event_types"`
+	// Secret используется для подписи запросов и возвращается только при создании подписки
+	Secret    string    `json:"secret,omitempty" db:"secret"`
+	Active    bool      `json:"active" db:"active"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Matches проверяет, подписана ли подписка на указанный тип события
+func (s *WebhookSubscription) Matches(eventType EventType) bool {
+	for _, t := range s.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateWebhookSubscriptionRequest представляет запрос на создание подписки.
+// Если секрет не передан, он генерируется сервером
+type CreateWebhookSubscriptionRequest struct {
+	URL        string      `json:"url"`
+	EventTypes []EventType `json:"event_types"`
+	Secret     string      `json:"secret,omitempty"`
+}
+
+// UpdateWebhookSubscriptionRequest представляет запрос на изменение подписки.
+// Непереданные поля не меняются
+type UpdateWebhookSubscriptionRequest struct {
+	URL        *string     `json:"url,omitempty"`
+	EventTypes []EventType `json:"event_types,omitempty"`
+	Active     *bool       `json:"active,omitempty"`
+}
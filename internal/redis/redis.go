@@ -3,7 +3,9 @@ package redis
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"delivery-system/internal/config"
@@ -12,10 +14,14 @@ import (
 	"github.com/go-redis/redis/v8"
 )
 
+// ErrKeyNotFound возвращается, когда ключ отсутствует в Redis
+var ErrKeyNotFound = errors.New("key not found")
+
 // Client представляет клиент Redis
 type Client struct {
-	client *redis.Client
-	log    *logger.Logger
+	client    *redis.Client
+	keyPrefix string
+	log       *logger.Logger
 }
 
 // Connect создает подключение к Redis
@@ -36,11 +42,20 @@ func Connect(cfg *config.RedisConfig, log *logger.Logger) (*Client, error) {
 	log.Info("Successfully connected to Redis")
 
 	return &Client{
-		client: rdb,
-		log:    log,
+		client:    rdb,
+		keyPrefix: cfg.KeyPrefix,
+		log:       log,
 	}, nil
 }
 
+// key добавляет к ключу пространство имен окружения, если оно задано
+func (c *Client) key(key string) string {
+	if c.keyPrefix == "" {
+		return key
+	}
+	return c.keyPrefix + ":" + key
+}
+
 // Close закрывает подключение к Redis
 func (c *Client) Close() error {
 	return c.client.Close()
@@ -53,7 +68,7 @@ func (c *Client) Set(ctx context.Context, key string, value interface{}, ttl tim
 		return fmt.Errorf("failed to marshal value: %w", err)
 	}
 
-	err = c.client.Set(ctx, key, data, ttl).Err()
+	err = c.client.Set(ctx, c.key(key), data, ttl).Err()
 	if err != nil {
 		return fmt.Errorf("failed to set key %s: %w", key, err)
 	}
@@ -64,10 +79,10 @@ func (c *Client) Set(ctx context.Context, key string, value interface{}, ttl tim
 
 // Get получает значение по ключу
 func (c *Client) Get(ctx context.Context, key string, dest interface{}) error {
-	val, err := c.client.Get(ctx, key).Result()
+	val, err := c.client.Get(ctx, c.key(key)).Result()
 	if err != nil {
 		if err == redis.Nil {
-			return fmt.Errorf("key %s not found", key)
+			return fmt.Errorf("key %s: %w", key, ErrKeyNotFound)
 		}
 		return fmt.Errorf("failed to get key %s: %w", key, err)
 	}
@@ -81,9 +96,25 @@ func (c *Client) Get(ctx context.Context, key string, dest interface{}) error {
 	return nil
 }
 
+// SetNX устанавливает значение с TTL, только если ключ еще не существует.
+// Возвращает true, если значение было установлено
+func (c *Client) SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal value: %w", err)
+	}
+
+	ok, err := c.client.SetNX(ctx, c.key(key), data, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to set key %s: %w", key, err)
+	}
+
+	return ok, nil
+}
+
 // Delete удаляет значение по ключу
 func (c *Client) Delete(ctx context.Context, key string) error {
-	err := c.client.Del(ctx, key).Err()
+	err := c.client.Del(ctx, c.key(key)).Err()
 	if err != nil {
 		return fmt.Errorf("failed to delete key %s: %w", key, err)
 	}
@@ -94,7 +125,7 @@ func (c *Client) Delete(ctx context.Context, key string) error {
 
 // Exists проверяет существование ключа
 func (c *Client) Exists(ctx context.Context, key string) (bool, error) {
-	exists, err := c.client.Exists(ctx, key).Result()
+	exists, err := c.client.Exists(ctx, c.key(key)).Result()
 	if err != nil {
 		return false, fmt.Errorf("failed to check if key %s exists: %w", key, err)
 	}
@@ -102,6 +133,90 @@ func (c *Client) Exists(ctx context.Context, key string) (bool, error) {
 	return exists > 0, nil
 }
 
+// ZAddNX добавляет элемент в упорядоченное множество, если его там еще нет.
+// Возвращает true, если элемент был добавлен
+func (c *Client) ZAddNX(ctx context.Context, key, member string, score float64) (bool, error) {
+	added, err := c.client.ZAddNX(ctx, c.key(key), &redis.Z{Score: score, Member: member}).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to add member to %s: %w", key, err)
+	}
+
+	return added > 0, nil
+}
+
+// ZAddXX обновляет оценку элемента упорядоченного множества, только если элемент в нем уже есть
+func (c *Client) ZAddXX(ctx context.Context, key, member string, score float64) error {
+	if err := c.client.ZAddXX(ctx, c.key(key), &redis.Z{Score: score, Member: member}).Err(); err != nil {
+		return fmt.Errorf("failed to update member of %s: %w", key, err)
+	}
+
+	return nil
+}
+
+// ZRange возвращает элементы упорядоченного множества с позиции start по stop включительно
+// в порядке возрастания оценки
+func (c *Client) ZRange(ctx context.Context, key string, start, stop int64) ([]string, error) {
+	members, err := c.client.ZRange(ctx, c.key(key), start, stop).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read members of %s: %w", key, err)
+	}
+
+	return members, nil
+}
+
+// ZRem удаляет элемент из упорядоченного множества
+func (c *Client) ZRem(ctx context.Context, key, member string) error {
+	if err := c.client.ZRem(ctx, c.key(key), member).Err(); err != nil {
+		return fmt.Errorf("failed to remove member from %s: %w", key, err)
+	}
+
+	return nil
+}
+
+// ZCard возвращает количество элементов упорядоченного множества
+func (c *Client) ZCard(ctx context.Context, key string) (int64, error) {
+	count, err := c.client.ZCard(ctx, c.key(key)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count members of %s: %w", key, err)
+	}
+
+	return count, nil
+}
+
+// HSetNX устанавливает поле хеша, если его там еще нет
+func (c *Client) HSetNX(ctx context.Context, key, field string, value interface{}) error {
+	if err := c.client.HSetNX(ctx, c.key(key), field, value).Err(); err != nil {
+		return fmt.Errorf("failed to set field of %s: %w", key, err)
+	}
+
+	return nil
+}
+
+// HMGet возвращает значения полей хеша. Отсутствующие поля в результат не попадают
+func (c *Client) HMGet(ctx context.Context, key string, fields ...string) (map[string]string, error) {
+	values, err := c.client.HMGet(ctx, c.key(key), fields...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fields of %s: %w", key, err)
+	}
+
+	result := make(map[string]string, len(values))
+	for i, value := range values {
+		if str, ok := value.(string); ok {
+			result[fields[i]] = str
+		}
+	}
+	return result, nil
+}
+
+// HDel удаляет поля хеша
+func (c *Client) HDel(ctx context.Context, key string, fields ...string) error {
+	if err := c.client.HDel(ctx, c.key(key), fields...).Err(); err != nil {
+		return fmt.Errorf("failed to delete fields of %s: %w", key, err)
+	}
+
+	return nil
+}
+
 // SetMultiple устанавливает несколько значений за одну операцию
 func (c *Client) SetMultiple(ctx context.Context, values map[string]interface{}, ttl time.Duration) error {
 	pipe := c.client.Pipeline()
@@ -111,7 +226,7 @@ func (c *Client) SetMultiple(ctx context.Context, values map[string]interface{},
 		if err != nil {
 			return fmt.Errorf("failed to marshal value for key %s: %w", key, err)
 		}
-		pipe.Set(ctx, key, data, ttl)
+		pipe.Set(ctx, c.key(key), data, ttl)
 	}
 
 	_, err := pipe.Exec(ctx)
@@ -129,7 +244,12 @@ func (c *Client) GetMultiple(ctx context.Context, keys []string) (map[string]str
 		return make(map[string]string), nil
 	}
 
-	values, err := c.client.MGet(ctx, keys...).Result()
+	namespaced := make([]string, len(keys))
+	for i, key := range keys {
+		namespaced[i] = c.key(key)
+	}
+
+	values, err := c.client.MGet(ctx, namespaced...).Result()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get multiple keys: %w", err)
 	}
@@ -145,13 +265,96 @@ func (c *Client) GetMultiple(ctx context.Context, keys []string) (map[string]str
 	return result, nil
 }
 
+// Eval выполняет Lua-скрипт на стороне Redis. Ключи передаются скрипту с пространством имен
+func (c *Client) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	namespaced := make([]string, len(keys))
+	for i, key := range keys {
+		namespaced[i] = c.key(key)
+	}
+
+	result, err := c.client.Eval(ctx, script, namespaced, args...).Result()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to eval script: %w", err)
+	}
+
+	return result, nil
+}
+
+// ScanKeys возвращает ключи, подходящие под шаблон, без пространства имен окружения.
+// Использует SCAN, чтобы не блокировать Redis на больших базах
+func (c *Client) ScanKeys(ctx context.Context, pattern string) ([]string, error) {
+	keys, _, err := c.ScanKeysLimit(ctx, pattern, 0)
+	return keys, err
+}
+
+// ScanKeysLimit возвращает не больше limit ключей, подходящих под шаблон (0 - без ограничения).
+// Второе значение сообщает, что перебор остановлен на limit и ключей может быть больше
+func (c *Client) ScanKeysLimit(ctx context.Context, pattern string, limit int) ([]string, bool, error) {
+	var keys []string
+	var cursor uint64
+	for {
+		batch, next, err := c.client.Scan(ctx, cursor, c.key(pattern), 100).Result()
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to scan keys %s: %w", pattern, err)
+		}
+
+		for _, key := range batch {
+			keys = append(keys, c.unprefixed(key))
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return keys, false, nil
+		}
+		if limit > 0 && len(keys) >= limit {
+			return keys, true, nil
+		}
+	}
+}
+
+// TTLMultiple получает оставшееся время жизни нескольких ключей за одну операцию.
+// Ключи без TTL или уже удаленные в результат не попадают
+func (c *Client) TTLMultiple(ctx context.Context, keys []string) (map[string]time.Duration, error) {
+	result := make(map[string]time.Duration)
+	if len(keys) == 0 {
+		return result, nil
+	}
+
+	pipe := c.client.Pipeline()
+	cmds := make([]*redis.DurationCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.TTL(ctx, c.key(key))
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to get ttl of multiple keys: %w", err)
+	}
+
+	for i, key := range keys {
+		if ttl, err := cmds[i].Result(); err == nil && ttl > 0 {
+			result[key] = ttl
+		}
+	}
+
+	return result, nil
+}
+
+// unprefixed убирает пространство имен окружения из ключа, полученного от Redis
+func (c *Client) unprefixed(key string) string {
+	if c.keyPrefix == "" {
+		return key
+	}
+	return strings.TrimPrefix(key, c.keyPrefix+":")
+}
+
 // Health проверяет состояние Redis
 func (c *Client) Health(ctx context.Context) error {
 	_, err := c.client.Ping(ctx).Result()
 	return err
 }
 
-// GenerateKey генерирует ключ для кеша
+// GenerateKey генерирует ключ для кеша. Пространство имен окружения (REDIS_KEY_PREFIX)
+// добавляет Client при обращении к Redis
 func GenerateKey(prefix, id string) string {
 	return fmt.Sprintf("%s:%s", prefix, id)
 }
@@ -161,4 +364,35 @@ const (
 	KeyPrefixOrder   = "order"
 	KeyPrefixCourier = "courier"
 	KeyPrefixStats   = "stats"
+
+	KeyPrefixCourierStats    = "courier:stats"
+	KeyPrefixCourierLocation = "courier:location"
+	// KeyPrefixCourierGrace отмечает курьеров, недавно вернувшихся из busy в available.
+	// Пока ключ существует, курьер не назначается на заказы
+	KeyPrefixCourierGrace = "assignment:grace"
+
+	KeyPrefixOrderDedup = "order:dedup"
+
+	// KeyPrefixOrderStats счетчики заказов по статусам (stats:orders:{status}), обновляемые по событиям Kafka.
+	// KeyOrderStatsSeeded отмечает, что счетчики заполнены из базы данных, а по ключам
+	// KeyPrefixOrderStatsEvent запоминаются уже учтенные события. KeyOrderStatsReconcileLock не дает
+	// нескольким экземплярам сверять счетчики с базой данных в одном интервале
+	KeyPrefixOrderStats        = "stats:orders"
+	KeyOrderStatsSeeded        = "stats:orders:seeded"
+	KeyPrefixOrderStatsEvent   = "stats:orders:event"
+	KeyOrderStatsReconcileLock = "stats:orders:reconcile_lock"
+
+	KeyPrefixRateLimit         = "rate_limit"
+	KeyPrefixRateLimitBan      = "rate_limit:ban"
+	KeyPrefixRateLimitOverage  = "rate_limit:overage"
+	KeyPrefixRateLimitBanCount = "rate_limit:ban_count"
+	KeyRateLimitVIP            = "rate_limit:vip"
+	// KeyPrefixRateLimitConcurrency счетчик запросов клиента, выполняющихся в данный момент
+	KeyPrefixRateLimitConcurrency = "rate_limit:concurrency"
+
+	// KeyAssignmentQueue упорядоченное множество заказов, ожидающих свободного курьера
+	KeyAssignmentQueue = "assignment:queue"
+	// KeyAssignmentQueuedAt время постановки заказов в очередь ожидания курьера (Unix миллисекунды).
+	// Оценка в KeyAssignmentQueue меняется при переносе заказа в конец очереди, поэтому хранится отдельно
+	KeyAssignmentQueuedAt = "assignment:queued_at"
 )
@@ -0,0 +1,297 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"delivery-system/internal/config"
+	"delivery-system/internal/kafka"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/metrics"
+	"delivery-system/internal/models"
+	"delivery-system/internal/redis"
+
+	"github.com/google/uuid"
+)
+
+// autoAssignCandidates сколько ближайших курьеров пробуется при автоматическом назначении,
+// если предыдущих успели занять параллельные назначения
+const autoAssignCandidates = 5
+
+// AutoAssignService автоматически назначает заказу ближайшего свободного курьера.
+// Заказы, для которых курьера не нашлось, ставятся в очередь в Redis и назначаются повторно,
+// когда курьеры становятся доступными
+type AutoAssignService struct {
+	courierService *CourierService
+	redisClient    *redis.Client
+	producer       *kafka.Producer
+	cache          *CacheService
+	queueDepth     *metrics.Vec
+	cfg            *config.AssignmentConfig
+	log            *logger.Logger
+
+	// wakeup будит воркер очереди по событию освобождения курьера
+	wakeup chan struct{}
+	// mu не дает одновременно обрабатывать очередь событию и периодической проверке
+	mu sync.Mutex
+}
+
+// NewAutoAssignService создает новый сервис автоматического назначения
+func NewAutoAssignService(courierService *CourierService, redisClient *redis.Client, producer *kafka.Producer, cache *CacheService,
+	registry *metrics.Registry, cfg *config.AssignmentConfig, log *logger.Logger) *AutoAssignService {
+	return &AutoAssignService{
+		courierService: courierService,
+		redisClient:    redisClient,
+		producer:       producer,
+		cache:          cache,
+		queueDepth:     registry.NewGauge("delivery_assignment_queue_depth", "Orders waiting in the queue for an available courier"),
+		cfg:            cfg,
+		log:            log,
+		wakeup:         make(chan struct{}, 1),
+	}
+}
+
+// AutoAssign назначает заказу ближайшего подходящего курьера. Если свободных курьеров нет,
+// заказ ставится в очередь и результат содержит Queued = true
+func (s *AutoAssignService) AutoAssign(ctx context.Context, orderID uuid.UUID) (*models.AutoAssignResult, error) {
+	courier, err := s.tryAssign(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if courier != nil {
+		return &models.AutoAssignResult{OrderID: orderID, Courier: courier}, nil
+	}
+
+	if err := s.enqueue(ctx, orderID); err != nil {
+		return nil, err
+	}
+	return &models.AutoAssignResult{OrderID: orderID, Queued: true}, nil
+}
+
+// tryAssign пробует назначить заказ ближайшим курьерам по очереди. Возвращает nil без ошибки,
+// если ни одного курьера назначить не удалось
+func (s *AutoAssignService) tryAssign(ctx context.Context, orderID uuid.UUID) (*models.CourierWithDistance, error) {
+	candidates, err := s.courierService.RankCouriersForOrder(orderID, autoAssignCandidates)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, candidate := range candidates {
+		err := s.courierService.AssignOrderToCourier(orderID, candidate.ID, false)
+		if errors.Is(err, ErrCourierUnavailable) {
+			// Курьера успело занять другое назначение - пробуем следующего
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		s.afterAssign(ctx, orderID, candidate.ID)
+		return candidate, nil
+	}
+
+	return nil, nil
+}
+
+// afterAssign публикует событие назначения и сбрасывает кеш заказа и курьера
+func (s *AutoAssignService) afterAssign(ctx context.Context, orderID, courierID uuid.UUID) {
+	if err := s.producer.PublishCourierAssigned(ctx, orderID, courierID); err != nil {
+		s.log.WithError(err).Error("Failed to publish courier assigned event")
+	}
+
+	err := s.cache.Delete(ctx,
+		redis.GenerateKey(redis.KeyPrefixCourier, courierID.String()),
+		redis.GenerateKey(redis.KeyPrefixOrder, orderID.String()))
+	if err != nil {
+		s.log.WithError(err).Error("Failed to invalidate assignment cache")
+	}
+
+	s.log.WithField("order_id", orderID).WithField("courier_id", courierID).Info("Order auto-assigned to courier")
+}
+
+// enqueue ставит заказ в очередь ожидания курьера. Повторная постановка сохраняет исходную позицию
+func (s *AutoAssignService) enqueue(ctx context.Context, orderID uuid.UUID) error {
+	now := time.Now().UnixMilli()
+	added, err := s.redisClient.ZAddNX(ctx, redis.KeyAssignmentQueue, orderID.String(), float64(now))
+	if err != nil {
+		return fmt.Errorf("failed to enqueue order for assignment: %w", err)
+	}
+
+	if added {
+		if err := s.redisClient.HSetNX(ctx, redis.KeyAssignmentQueuedAt, orderID.String(), now); err != nil {
+			s.log.WithError(err).WithField("order_id", orderID).Warn("Failed to record assignment queue time")
+		}
+		s.log.WithField("order_id", orderID).Info("No courier available, order queued for assignment")
+	}
+	s.refreshQueueDepth(ctx)
+	return nil
+}
+
+// HandleCourierStatusChanged реализует kafka.EventHandler для события courier.status_changed:
+// освободившийся курьер запускает повторное назначение заказов из очереди
+func (s *AutoAssignService) HandleCourierStatusChanged(ctx context.Context, event *models.Event) error {
+	var data models.CourierStatusChangedEvent
+	if err := event.DecodeData(&data); err != nil {
+		return err
+	}
+
+	if data.NewStatus == models.CourierStatusAvailable {
+		// Курьер, вернувшийся из busy, назначается только после периода ожидания,
+		// поэтому воркер будится по его окончании (с запасом на истечение ключа в Redis)
+		if grace := s.courierService.availabilityGracePeriod(); data.OldStatus == models.CourierStatusBusy && grace > 0 {
+			time.AfterFunc(grace+time.Second, s.wake)
+			return nil
+		}
+		s.wake()
+	}
+	return nil
+}
+
+// wake будит воркер очереди. Обработка очереди выполняется воркером, чтобы не задерживать чтение событий
+func (s *AutoAssignService) wake() {
+	select {
+	case s.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// Run обрабатывает очередь по событиям освобождения курьеров и периодически до отмены контекста.
+// Периодическая проверка подхватывает курьеров, которые приблизились к точке забора, не меняя статус
+func (s *AutoAssignService) Run(ctx context.Context) {
+	var tick <-chan time.Time
+	if interval := time.Duration(s.cfg.QueueRetryInterval) * time.Second; interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	s.refreshQueueDepth(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wakeup:
+		case <-tick:
+		}
+
+		if _, err := s.ProcessQueue(ctx); err != nil {
+			s.log.WithError(err).Error("Failed to process assignment queue")
+		}
+	}
+}
+
+// ProcessQueue пробует назначить курьеров заказам из очереди, начиная с самых старых.
+// Заказы, которые назначены или больше не могут быть назначены автоматически, удаляются из очереди,
+// а остальные переносятся в ее конец, чтобы заказы без подходящего курьера не занимали весь проход.
+// Заказы, ожидающие дольше MaxQueueAge, удаляются из очереди. Возвращает количество назначенных заказов
+func (s *AutoAssignService) ProcessQueue(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.refreshQueueDepth(ctx)
+
+	members, err := s.redisClient.ZRange(ctx, redis.KeyAssignmentQueue, 0, int64(s.cfg.QueueBatchSize)-1)
+	if err != nil {
+		return 0, err
+	}
+	if len(members) == 0 {
+		return 0, nil
+	}
+
+	queuedAt, err := s.redisClient.HMGet(ctx, redis.KeyAssignmentQueuedAt, members...)
+	if err != nil {
+		return 0, err
+	}
+
+	assigned := 0
+	for _, member := range members {
+		orderID, err := uuid.Parse(member)
+		if err != nil {
+			s.dequeue(ctx, member)
+			continue
+		}
+
+		if age, expired := s.queueAgeExceeded(ctx, member, queuedAt[member]); expired {
+			s.log.WithField("order_id", orderID).
+				WithField("queue_age", age.Round(time.Second).String()).
+				Warn("Order waited too long for a courier, removing from assignment queue")
+			s.dequeue(ctx, member)
+			continue
+		}
+
+		courier, err := s.tryAssign(ctx, orderID)
+		switch {
+		case err != nil && isFinalAssignmentError(err):
+			s.log.WithError(err).WithField("order_id", orderID).Info("Removing order from assignment queue")
+			s.dequeue(ctx, member)
+		case err != nil:
+			s.log.WithError(err).WithField("order_id", orderID).Error("Failed to auto-assign queued order")
+			s.requeue(ctx, member)
+		case courier != nil:
+			assigned++
+			s.dequeue(ctx, member)
+		default:
+			s.requeue(ctx, member)
+		}
+	}
+
+	return assigned, nil
+}
+
+// queueAgeExceeded возвращает время ожидания заказа в очереди и признак превышения MaxQueueAge.
+// Заказу без записанного времени постановки (поставлен до появления ограничения) оно записывается сейчас
+func (s *AutoAssignService) queueAgeExceeded(ctx context.Context, member, queuedAt string) (time.Duration, bool) {
+	if s.cfg.MaxQueueAge <= 0 {
+		return 0, false
+	}
+
+	millis, err := strconv.ParseInt(queuedAt, 10, 64)
+	if err != nil {
+		if err := s.redisClient.HSetNX(ctx, redis.KeyAssignmentQueuedAt, member, time.Now().UnixMilli()); err != nil {
+			s.log.WithError(err).WithField("order_id", member).Warn("Failed to record assignment queue time")
+		}
+		return 0, false
+	}
+
+	age := time.Since(time.UnixMilli(millis))
+	return age, age > time.Duration(s.cfg.MaxQueueAge)*time.Second
+}
+
+// requeue переносит заказ в конец очереди. Заказ, который успели удалить из очереди, не добавляется снова
+func (s *AutoAssignService) requeue(ctx context.Context, member string) {
+	if err := s.redisClient.ZAddXX(ctx, redis.KeyAssignmentQueue, member, float64(time.Now().UnixMilli())); err != nil {
+		s.log.WithError(err).WithField("order_id", member).Error("Failed to move order to the end of assignment queue")
+	}
+}
+
+// isFinalAssignmentError сообщает, что заказ не будет назначен автоматически при повторных попытках:
+// он удален, уже назначен, перешел в другой статус или не имеет точки забора
+func isFinalAssignmentError(err error) bool {
+	return errors.Is(err, ErrNotFound) || errors.Is(err, ErrConflict) ||
+		errors.Is(err, ErrInvalidTransition) || errors.Is(err, ErrMissingLocation)
+}
+
+// dequeue удаляет заказ из очереди ожидания курьера
+func (s *AutoAssignService) dequeue(ctx context.Context, member string) {
+	if err := s.redisClient.ZRem(ctx, redis.KeyAssignmentQueue, member); err != nil {
+		s.log.WithError(err).WithField("order_id", member).Error("Failed to remove order from assignment queue")
+		return
+	}
+	if err := s.redisClient.HDel(ctx, redis.KeyAssignmentQueuedAt, member); err != nil {
+		s.log.WithError(err).WithField("order_id", member).Warn("Failed to remove assignment queue time")
+	}
+}
+
+// refreshQueueDepth обновляет метрику длины очереди
+func (s *AutoAssignService) refreshQueueDepth(ctx context.Context) {
+	depth, err := s.redisClient.ZCard(ctx, redis.KeyAssignmentQueue)
+	if err != nil {
+		s.log.WithError(err).Warn("Failed to get assignment queue depth")
+		return
+	}
+	s.queueDepth.Set(float64(depth))
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+	"delivery-system/internal/redis"
+
+	"github.com/google/uuid"
+)
+
+// CacheInvalidator удаляет из кеша заказы и курьеров, измененные по событиям Kafka.
+// Обработчик запроса сбрасывает кеш только на своем экземпляре сервиса, а события получают
+// все экземпляры, поэтому кеш остается согласованным, даже если изменение выполнил другой экземпляр
+type CacheInvalidator struct {
+	cache *CacheService
+	log   *logger.Logger
+}
+
+// NewCacheInvalidator создает обработчик инвалидации кеша по событиям
+func NewCacheInvalidator(cache *CacheService, log *logger.Logger) *CacheInvalidator {
+	return &CacheInvalidator{
+		cache: cache,
+		log:   log,
+	}
+}
+
+// HandleOrderStatusChanged реализует kafka.EventHandler для события order.status_changed.
+// Вместе с заказом сбрасывается кеш его курьера, статус которого меняется вместе с заказом,
+// а после доставки - и статистика курьера
+func (i *CacheInvalidator) HandleOrderStatusChanged(ctx context.Context, event *models.Event) error {
+	var data models.OrderStatusChangedEvent
+	if err := event.DecodeData(&data); err != nil {
+		return err
+	}
+
+	keys := []string{orderCacheKey(data.OrderID)}
+	if data.CourierID != nil {
+		keys = append(keys, courierCacheKey(*data.CourierID))
+		if data.NewStatus == models.OrderStatusDelivered {
+			keys = append(keys, redis.GenerateKey(redis.KeyPrefixCourierStats, data.CourierID.String()))
+		}
+	}
+
+	return i.invalidate(ctx, event, keys)
+}
+
+// HandleOrderAmountChanged реализует kafka.EventHandler для события order.amount_changed
+func (i *CacheInvalidator) HandleOrderAmountChanged(ctx context.Context, event *models.Event) error {
+	var data models.OrderAmountChangedEvent
+	if err := event.DecodeData(&data); err != nil {
+		return err
+	}
+
+	return i.invalidate(ctx, event, []string{orderCacheKey(data.OrderID)})
+}
+
+// HandleCourierAssigned реализует kafka.EventHandler для события courier.assigned
+func (i *CacheInvalidator) HandleCourierAssigned(ctx context.Context, event *models.Event) error {
+	var data models.CourierAssignedEvent
+	if err := event.DecodeData(&data); err != nil {
+		return err
+	}
+
+	return i.invalidate(ctx, event, []string{orderCacheKey(data.OrderID), courierCacheKey(data.CourierID)})
+}
+
+// HandleCourierStatusChanged реализует kafka.EventHandler для события courier.status_changed
+func (i *CacheInvalidator) HandleCourierStatusChanged(ctx context.Context, event *models.Event) error {
+	var data models.CourierStatusChangedEvent
+	if err := event.DecodeData(&data); err != nil {
+		return err
+	}
+
+	return i.invalidate(ctx, event, []string{courierCacheKey(data.CourierID)})
+}
+
+// invalidate удаляет ключи из кеша. Ошибка возвращается, чтобы потребитель повторил обработку события
+func (i *CacheInvalidator) invalidate(ctx context.Context, event *models.Event, keys []string) error {
+	if err := i.cache.Delete(ctx, keys...); err != nil {
+		return fmt.Errorf("failed to invalidate cache for event %s: %w", event.ID, err)
+	}
+
+	i.log.WithField("event_id", event.ID).
+		WithField("event_type", event.Type).
+		WithField("keys", keys).
+		Debug("Cache invalidated by event")
+	return nil
+}
+
+// orderCacheKey возвращает ключ кеша заказа
+func orderCacheKey(orderID uuid.UUID) string {
+	return redis.GenerateKey(redis.KeyPrefixOrder, orderID.String())
+}
+
+// courierCacheKey возвращает ключ кеша курьера
+func courierCacheKey(courierID uuid.UUID) string {
+	return redis.GenerateKey(redis.KeyPrefixCourier, courierID.String())
+}
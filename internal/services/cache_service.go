@@ -0,0 +1,215 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"delivery-system/internal/config"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/redis"
+)
+
+// CacheMetrics представляет статистику использования кеша
+type CacheMetrics struct {
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	Errors  int64   `json:"errors"`
+	Sets    int64   `json:"sets"`
+	Deletes int64   `json:"deletes"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+// CacheService представляет сервис кеширования поверх Redis с учетом статистики
+type CacheService struct {
+	redisClient *redis.Client
+	cfg         *config.CacheConfig
+	log         *logger.Logger
+
+	hits    atomic.Int64
+	misses  atomic.Int64
+	errors  atomic.Int64
+	sets    atomic.Int64
+	deletes atomic.Int64
+}
+
+// NewCacheService создает новый экземпляр сервиса кеширования
+func NewCacheService(redisClient *redis.Client, cfg *config.CacheConfig, log *logger.Logger) *CacheService {
+	return &CacheService{
+		redisClient: redisClient,
+		cfg:         cfg,
+		log:         log,
+	}
+}
+
+// Get получает значение из кеша. Возвращает ошибку при промахе или сбое Redis.
+func (s *CacheService) Get(ctx context.Context, key string, dest interface{}) error {
+	ctx, cancel, err := s.withTimeout(ctx)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	err = s.redisClient.Get(ctx, key, dest)
+	switch {
+	case err == nil:
+		s.hits.Add(1)
+	case errors.Is(err, redis.ErrKeyNotFound):
+		s.misses.Add(1)
+	default:
+		s.errors.Add(1)
+	}
+	return err
+}
+
+// Set сохраняет значение в кеш со временем жизни по умолчанию
+func (s *CacheService) Set(ctx context.Context, key string, value interface{}) error {
+	return s.SetWithTTL(ctx, key, value, s.ttl())
+}
+
+// GetMultiple получает несколько значений из кеша за один запрос.
+// Возвращает сырые JSON значения только для найденных ключей
+func (s *CacheService) GetMultiple(ctx context.Context, keys []string) (map[string]string, error) {
+	ctx, cancel, err := s.withTimeout(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	values, err := s.redisClient.GetMultiple(ctx, keys)
+	if err != nil {
+		s.errors.Add(1)
+		return nil, err
+	}
+
+	s.hits.Add(int64(len(values)))
+	s.misses.Add(int64(len(keys) - len(values)))
+	return values, nil
+}
+
+// SetWithTTL сохраняет значение в кеш с заданным временем жизни
+func (s *CacheService) SetWithTTL(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	ctx, cancel, err := s.withTimeout(ctx)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	if err := s.redisClient.Set(ctx, key, value, ttl); err != nil {
+		s.errors.Add(1)
+		return err
+	}
+	s.sets.Add(1)
+	return nil
+}
+
+// Delete удаляет значения из кеша. Инвалидация выполняется и для отмененного запроса,
+// иначе после уже сохраненного изменения в кеше осталась бы устаревшая запись
+func (s *CacheService) Delete(ctx context.Context, keys ...string) error {
+	ctx, cancel, err := s.withTimeout(context.WithoutCancel(ctx))
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	for _, key := range keys {
+		if err := s.redisClient.Delete(ctx, key); err != nil {
+			s.errors.Add(1)
+			return err
+		}
+		s.deletes.Add(1)
+	}
+	return nil
+}
+
+// Metrics возвращает накопленную статистику использования кеша
+func (s *CacheService) Metrics() CacheMetrics {
+	metrics := CacheMetrics{
+		Hits:    s.hits.Load(),
+		Misses:  s.misses.Load(),
+		Errors:  s.errors.Load(),
+		Sets:    s.sets.Load(),
+		Deletes: s.deletes.Load(),
+	}
+
+	if lookups := metrics.Hits + metrics.Misses; lookups > 0 {
+		metrics.HitRate = float64(metrics.Hits) / float64(lookups)
+	}
+
+	return metrics
+}
+
+// CacheSize представляет количество ключей кеша по префиксам
+type CacheSize struct {
+	KeysByPrefix map[string]int64 `json:"keys_by_prefix"`
+	// Approximate означает, что просмотрено только CACHE_SIZE_SCAN_LIMIT ключей и значения занижены
+	Approximate bool `json:"approximate"`
+}
+
+// cachePrefixes префиксы ключей, относящихся к кешу. Ключи ограничения частоты запросов
+// и другие служебные ключи в размер кеша не входят
+var cachePrefixes = []string{
+	redis.KeyPrefixOrder,
+	redis.KeyPrefixOrderDedup,
+	redis.KeyPrefixCourier,
+	redis.KeyPrefixCourierStats,
+	redis.KeyPrefixCourierLocation,
+	redis.KeyPrefixStats,
+}
+
+// Size подсчитывает ключи кеша по префиксам с помощью SCAN. Ключ относится к самому длинному
+// подходящему префиксу, например courier:stats:{id} не учитывается в courier
+func (s *CacheService) Size(ctx context.Context) (*CacheSize, error) {
+	keys, truncated, err := s.redisClient.ScanKeysLimit(ctx, "*", s.cfg.SizeScanLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	size := &CacheSize{
+		KeysByPrefix: make(map[string]int64, len(cachePrefixes)),
+		Approximate:  truncated,
+	}
+	for _, prefix := range cachePrefixes {
+		size.KeysByPrefix[prefix] = 0
+	}
+
+	for _, key := range keys {
+		if prefix := matchCachePrefix(key); prefix != "" {
+			size.KeysByPrefix[prefix]++
+		}
+	}
+
+	return size, nil
+}
+
+// matchCachePrefix возвращает самый длинный префикс кеша, которому соответствует ключ
+func matchCachePrefix(key string) string {
+	match := ""
+	for _, prefix := range cachePrefixes {
+		if strings.HasPrefix(key, prefix+":") && len(prefix) > len(match) {
+			match = prefix
+		}
+	}
+	return match
+}
+
+// withTimeout ограничивает операцию с кешем таймаутом из конфигурации.
+// Если контекст запроса уже отменен, операция не выполняется
+func (s *CacheService) withTimeout(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	if s.cfg.OperationTimeoutMs <= 0 {
+		return ctx, func() {}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(s.cfg.OperationTimeoutMs)*time.Millisecond)
+	return ctx, cancel, nil
+}
+
+// ttl возвращает время жизни записей кеша
+func (s *CacheService) ttl() time.Duration {
+	return time.Duration(s.cfg.DefaultTTL) * time.Second
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"delivery-system/internal/config"
+)
+
+func TestCacheServiceCancelledContext(t *testing.T) {
+	// Клиент Redis не задан: отмененный запрос не должен до него дойти
+	s := NewCacheService(nil, &config.CacheConfig{DefaultTTL: 60, OperationTimeoutMs: 1000}, nil)
+
+	tests := []struct {
+		name string
+		op   func(ctx context.Context) error
+	}{
+		{
+			name: "get",
+			op: func(ctx context.Context) error {
+				var value string
+				return s.Get(ctx, "order:1", &value)
+			},
+		},
+		{
+			name: "get multiple",
+			op: func(ctx context.Context) error {
+				_, err := s.GetMultiple(ctx, []string{"order:1", "order:2"})
+				return err
+			},
+		},
+		{
+			name: "set",
+			op: func(ctx context.Context) error {
+				return s.Set(ctx, "order:1", "value")
+			},
+		},
+		{
+			name: "set with ttl",
+			op: func(ctx context.Context) error {
+				return s.SetWithTTL(ctx, "order:1", "value", time.Minute)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			start := time.Now()
+			err := tt.op(ctx)
+			if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+				t.Fatalf("cancelled operation took %s", elapsed)
+			}
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("expected context.Canceled, got %v", err)
+			}
+		})
+	}
+
+	if metrics := s.Metrics(); metrics != (CacheMetrics{}) {
+		t.Fatalf("cancelled operations must not be counted, got %+v", metrics)
+	}
+}
+
+func TestCacheServiceExpiredContext(t *testing.T) {
+	s := NewCacheService(nil, &config.CacheConfig{OperationTimeoutMs: 1000}, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+
+	var value string
+	if err := s.Get(ctx, "order:1", &value); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"delivery-system/internal/config"
+	"delivery-system/internal/geo"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+	"delivery-system/internal/redis"
+
+	"github.com/google/uuid"
+)
+
+// CourierLocationCache хранит в Redis последнее местоположение курьеров из событий location.updated,
+// чтобы чтение свежей позиции не требовало обращения к PostgreSQL
+type CourierLocationCache struct {
+	cache *CacheService
+	cfg   *config.CacheConfig
+	log   *logger.Logger
+}
+
+// NewCourierLocationCache создает новый кеш местоположений курьеров
+func NewCourierLocationCache(cache *CacheService, cfg *config.CacheConfig, log *logger.Logger) *CourierLocationCache {
+	return &CourierLocationCache{
+		cache: cache,
+		cfg:   cfg,
+		log:   log,
+	}
+}
+
+// HandleLocationUpdated реализует kafka.EventHandler для события location.updated.
+// Событие, пришедшее позже более нового, не перезаписывает кешированное местоположение
+func (c *CourierLocationCache) HandleLocationUpdated(ctx context.Context, event *models.Event) error {
+	var data models.LocationUpdatedEvent
+	if err := event.DecodeData(&data); err != nil {
+		return err
+	}
+
+	if !geo.ValidLat(data.Lat) || !geo.ValidLon(data.Lon) {
+		c.log.WithField("event_id", event.ID).
+			WithField("courier_id", data.CourierID).
+			Warn("Skipping location update with invalid coordinates")
+		return nil
+	}
+
+	if current, ok := c.Get(ctx, data.CourierID); ok && current.Timestamp.After(data.Timestamp) {
+		return nil
+	}
+
+	location := models.CourierLocation{
+		CourierID: data.CourierID,
+		Lat:       data.Lat,
+		Lon:       data.Lon,
+		Timestamp: data.Timestamp,
+	}
+	if err := c.cache.SetWithTTL(ctx, courierLocationKey(data.CourierID), location, c.ttl()); err != nil {
+		return fmt.Errorf("failed to cache courier location: %w", err)
+	}
+
+	return nil
+}
+
+// Get возвращает последнее известное местоположение курьера из кеша
+func (c *CourierLocationCache) Get(ctx context.Context, courierID uuid.UUID) (*models.CourierLocation, bool) {
+	location := &models.CourierLocation{}
+	if err := c.cache.Get(ctx, courierLocationKey(courierID), location); err != nil {
+		return nil, false
+	}
+	return location, true
+}
+
+// Apply подставляет в курьера местоположение из кеша, если оно новее сохраненного в базе данных
+func (c *CourierLocationCache) Apply(ctx context.Context, courier *models.Courier) bool {
+	location, ok := c.Get(ctx, courier.ID)
+	if !ok || (courier.LastSeenAt != nil && !location.Timestamp.After(*courier.LastSeenAt)) {
+		return false
+	}
+
+	lat, lon, seenAt := location.Lat, location.Lon, location.Timestamp
+	courier.CurrentLat = &lat
+	courier.CurrentLon = &lon
+	courier.LastSeenAt = &seenAt
+	return true
+}
+
+// ttl возвращает время жизни кешированного местоположения
+func (c *CourierLocationCache) ttl() time.Duration {
+	return time.Duration(c.cfg.LocationTTL) * time.Second
+}
+
+// courierLocationKey возвращает ключ кеша местоположения курьера
+func courierLocationKey(courierID uuid.UUID) string {
+	return redis.GenerateKey(redis.KeyPrefixCourierLocation, courierID.String())
+}
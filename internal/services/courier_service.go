@@ -1,28 +1,39 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
+	"delivery-system/internal/config"
 	"delivery-system/internal/database"
+	"delivery-system/internal/geo"
 	"delivery-system/internal/logger"
 	"delivery-system/internal/models"
+	"delivery-system/internal/redis"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 // CourierService представляет сервис для работы с курьерами
 type CourierService struct {
-	db  *database.DB
-	log *logger.Logger
+	db          *database.DB
+	redisClient *redis.Client
+	cfg         *config.AssignmentConfig
+	log         *logger.Logger
 }
 
 // NewCourierService создает новый экземпляр сервиса курьеров
-func NewCourierService(db *database.DB, log *logger.Logger) *CourierService {
+func NewCourierService(db *database.DB, redisClient *redis.Client, cfg *config.AssignmentConfig, log *logger.Logger) *CourierService {
 	return &CourierService{
-		db:  db,
-		log: log,
+		db:          db,
+		redisClient: redisClient,
+		cfg:         cfg,
+		log:         log,
 	}
 }
 
@@ -33,17 +44,23 @@ func (s *CourierService) CreateCourier(req *models.CreateCourierRequest) (*model
 		Name:      req.Name,
 		Phone:     req.Phone,
 		Status:    models.CourierStatusOffline,
+		Active:    true,
+		Skills:    req.Skills,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
 
+	if courier.Skills == nil {
+		courier.Skills = []string{}
+	}
+
 	query := `
-		INSERT INTO couriers (id, name, phone, status, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO couriers (id, name, phone, status, skills, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 	`
 
 	_, err := s.db.Exec(query, courier.ID, courier.Name, courier.Phone,
-		courier.Status, courier.CreatedAt, courier.UpdatedAt)
+		courier.Status, pq.Array(courier.Skills), courier.CreatedAt, courier.UpdatedAt)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create courier: %w", err)
 	}
@@ -62,20 +79,20 @@ func (s *CourierService) GetCourier(courierID uuid.UUID) (*models.Courier, error
 	courier := &models.Courier{}
 
 	query := `
-		SELECT id, name, phone, status, current_lat, current_lon, 
+		SELECT id, name, phone, status, active, skills, current_lat, current_lon, 
 		       created_at, updated_at, last_seen_at
 		FROM couriers 
 		WHERE id = $1
 	`
 
-	err := s.db.QueryRow(query, courierID).Scan(
-		&courier.ID, &courier.Name, &courier.Phone, &courier.Status,
+	err := s.db.QueryRowWithRetry(query, courierID).Scan(
+		&courier.ID, &courier.Name, &courier.Phone, &courier.Status, &courier.Active, pq.Array(&courier.Skills),
 		&courier.CurrentLat, &courier.CurrentLon, &courier.CreatedAt,
 		&courier.UpdatedAt, &courier.LastSeenAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("courier not found")
+			return nil, newError(models.ErrorCodeCourierNotFound, "courier not found")
 		}
 		return nil, fmt.Errorf("failed to get courier: %w", err)
 	}
@@ -83,31 +100,55 @@ func (s *CourierService) GetCourier(courierID uuid.UUID) (*models.Courier, error
 	return courier, nil
 }
 
-// UpdateCourierStatus обновляет статус курьера
-func (s *CourierService) UpdateCourierStatus(courierID uuid.UUID, req *models.UpdateCourierStatusRequest) error {
+// GetCourierStats возвращает количество доставленных курьером заказов и их общую сумму
+func (s *CourierService) GetCourierStats(courierID uuid.UUID) (*models.CourierStats, error) {
+	stats := &models.CourierStats{}
+
 	query := `
-		UPDATE couriers 
-		SET status = $1, current_lat = $2, current_lon = $3, updated_at = $4, last_seen_at = $5
-		WHERE id = $6
+		SELECT COUNT(*), COALESCE(SUM(total_amount), 0)
+		FROM orders
+		WHERE courier_id = $1 AND status = $2
 	`
 
-	now := time.Now()
-	result, err := s.db.Exec(query, req.Status, req.CurrentLat, req.CurrentLon, now, now, courierID)
+	err := s.db.QueryRowWithRetry(query, courierID, models.OrderStatusDelivered).Scan(&stats.DeliveredOrders, &stats.TotalRevenue)
 	if err != nil {
-		return fmt.Errorf("failed to update courier status: %w", err)
+		return nil, fmt.Errorf("failed to get courier stats: %w", err)
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	return stats, nil
+}
+
+// UpdateCourierStatus обновляет статус курьера. Курьер, вернувшийся из busy в available,
+// становится доступен для назначения только после AvailabilityGracePeriod
+func (s *CourierService) UpdateCourierStatus(courierID uuid.UUID, req *models.UpdateCourierStatusRequest) error {
+	// Самосоединение возвращает статус курьера до обновления. Без координат в запросе
+	// сохраняется последнее известное местоположение
+	query := `
+		UPDATE couriers c
+		SET status = $1, current_lat = COALESCE($2, c.current_lat), current_lon = COALESCE($3, c.current_lon),
+		    updated_at = $4, last_seen_at = $5
+		FROM couriers old
+		WHERE c.id = $6 AND old.id = c.id
+		RETURNING old.status
+	`
+
+	now := time.Now()
+	var oldStatus models.CourierStatus
+	err := s.db.QueryRow(query, req.Status, req.CurrentLat, req.CurrentLon, now, now, courierID).Scan(&oldStatus)
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		if err == sql.ErrNoRows {
+			return newError(models.ErrorCodeCourierNotFound, "courier not found")
+		}
+		return fmt.Errorf("failed to update courier status: %w", err)
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("courier not found")
+	if oldStatus == models.CourierStatusBusy && req.Status == models.CourierStatusAvailable {
+		s.startAvailabilityGrace(courierID)
 	}
 
 	s.log.WithFields(map[string]interface{}{
 		"courier_id": courierID,
+		"old_status": oldStatus,
 		"new_status": req.Status,
 		"lat":        req.CurrentLat,
 		"lon":        req.CurrentLon,
@@ -116,10 +157,126 @@ func (s *CourierService) UpdateCourierStatus(courierID uuid.UUID, req *models.Up
 	return nil
 }
 
-// GetCouriers получает список курьеров с фильтрацией
-func (s *CourierService) GetCouriers(status *models.CourierStatus, limit, offset int) ([]*models.Courier, error) {
+// startAvailabilityGrace откладывает назначение заказов курьеру на AvailabilityGracePeriod.
+// Ошибка Redis только логируется: курьер в этом случае доступен сразу
+func (s *CourierService) startAvailabilityGrace(courierID uuid.UUID) {
+	grace := s.availabilityGracePeriod()
+	if grace <= 0 {
+		return
+	}
+
+	key := redis.GenerateKey(redis.KeyPrefixCourierGrace, courierID.String())
+	if err := s.redisClient.Set(context.Background(), key, time.Now().Add(grace), grace); err != nil {
+		s.log.WithError(err).WithField("courier_id", courierID).Warn("Failed to start courier availability grace period")
+	}
+}
+
+// availabilityGraceUntil возвращает для курьеров, у которых еще идет период ожидания после
+// возвращения в available, время его окончания. При ошибке Redis период ожидания не учитывается
+func (s *CourierService) availabilityGraceUntil(courierIDs []uuid.UUID) map[uuid.UUID]time.Time {
+	result := make(map[uuid.UUID]time.Time)
+	if s.availabilityGracePeriod() <= 0 || len(courierIDs) == 0 {
+		return result
+	}
+
+	keys := make([]string, len(courierIDs))
+	for i, id := range courierIDs {
+		keys[i] = redis.GenerateKey(redis.KeyPrefixCourierGrace, id.String())
+	}
+
+	ttls, err := s.redisClient.TTLMultiple(context.Background(), keys)
+	if err != nil {
+		s.log.WithError(err).Warn("Failed to get courier availability grace periods")
+		return result
+	}
+
+	now := time.Now()
+	for i, id := range courierIDs {
+		if ttl, ok := ttls[keys[i]]; ok {
+			result[id] = now.Add(ttl)
+		}
+	}
+	return result
+}
+
+// availabilityGracePeriod возвращает период ожидания после возвращения курьера в available
+func (s *CourierService) availabilityGracePeriod() time.Duration {
+	return time.Duration(s.cfg.AvailabilityGracePeriod) * time.Second
+}
+
+// RecordLocations сохраняет пакет точек маршрута курьера в историю местоположений.
+// Точки должны быть упорядочены по времени. Текущее местоположение курьера обновляется
+// по последней точке, только если она новее уже известного. Возвращает, было ли оно обновлено
+func (s *CourierService) RecordLocations(courierID uuid.UUID, points []models.LocationPoint) (updated bool, err error) {
+	err = s.db.Retry(func() error {
+		updated, err = s.recordLocations(courierID, points)
+		return err
+	})
+	return updated, err
+}
+
+// recordLocations выполняет RecordLocations в одной транзакции
+func (s *CourierService) recordLocations(courierID uuid.UUID, points []models.LocationPoint) (bool, error) {
+	if len(points) == 0 {
+		return false, nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var lastSeenAt sql.NullTime
+	err = tx.QueryRow("SELECT last_seen_at FROM couriers WHERE id = $1 FOR UPDATE", courierID).Scan(&lastSeenAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, newError(models.ErrorCodeCourierNotFound, "courier not found")
+		}
+		return false, fmt.Errorf("failed to get courier: %w", err)
+	}
+
+	values := make([]string, 0, len(points))
+	args := make([]interface{}, 0, len(points)*3+1)
+	args = append(args, courierID)
+	for _, point := range points {
+		n := len(args)
+		values = append(values, fmt.Sprintf("($1, $%d, $%d, $%d)", n+1, n+2, n+3))
+		args = append(args, point.Lat, point.Lon, point.Timestamp)
+	}
+
+	query := "INSERT INTO courier_locations (courier_id, lat, lon, recorded_at) VALUES " + strings.Join(values, ", ")
+	if _, err = tx.Exec(query, args...); err != nil {
+		return false, fmt.Errorf("failed to insert courier locations: %w", err)
+	}
+
+	latest := points[len(points)-1]
+	updated := !lastSeenAt.Valid || latest.Timestamp.After(lastSeenAt.Time)
+	if updated {
+		_, err = tx.Exec("UPDATE couriers SET current_lat = $1, current_lon = $2, last_seen_at = $3, updated_at = $4 WHERE id = $5",
+			latest.Lat, latest.Lon, latest.Timestamp, time.Now(), courierID)
+		if err != nil {
+			return false, fmt.Errorf("failed to update courier location: %w", err)
+		}
+	}
+
+	if err = database.Commit(tx); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.log.WithFields(map[string]interface{}{
+		"courier_id":       courierID,
+		"points":           len(points),
+		"location_updated": updated,
+	}).Info("Courier locations recorded")
+
+	return updated, nil
+}
+
+// GetCouriers получает список курьеров с фильтрацией. Непустой skill оставляет только курьеров с этим навыком
+func (s *CourierService) GetCouriers(status *models.CourierStatus, skill string, limit, offset int) ([]*models.Courier, error) {
 	query := `
-		SELECT id, name, phone, status, current_lat, current_lon, 
+		SELECT id, name, phone, status, active, skills, current_lat, current_lon, 
 		       created_at, updated_at, last_seen_at
 		FROM couriers 
 		WHERE 1=1
@@ -133,6 +290,12 @@ func (s *CourierService) GetCouriers(status *models.CourierStatus, limit, offset
 		argIndex++
 	}
 
+	if skill != "" {
+		query += fmt.Sprintf(" AND skills @> ARRAY[$%d]::text[]", argIndex)
+		args = append(args, skill)
+		argIndex++
+	}
+
 	query += " ORDER BY created_at DESC"
 
 	if limit > 0 {
@@ -146,16 +309,146 @@ func (s *CourierService) GetCouriers(status *models.CourierStatus, limit, offset
 		args = append(args, offset)
 	}
 
-	rows, err := s.db.Query(query, args...)
+	rows, err := s.db.QueryWithRetry(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get couriers: %w", err)
 	}
 	defer rows.Close()
 
+	return scanCouriers(rows)
+}
+
+// GetCouriersNearby получает курьеров с известным местоположением в радиусе от точки,
+// отсортированных по расстоянию. Непустой skill оставляет только курьеров с этим навыком
+func (s *CourierService) GetCouriersNearby(status *models.CourierStatus, skill string, lat, lon, radiusKm float64, limit, offset int) ([]*models.CourierWithDistance, error) {
+	minLat, maxLat, minLon, maxLon := geo.BoundingBox(lat, lon, radiusKm)
+
+	query := `
+		SELECT id, name, phone, status, active, skills, current_lat, current_lon,
+		       created_at, updated_at, last_seen_at
+		FROM couriers
+		WHERE current_lat BETWEEN $1 AND $2
+		  AND current_lon BETWEEN $3 AND $4
+	`
+	args := []interface{}{minLat, maxLat, minLon, maxLon}
+
+	if status != nil {
+		query += fmt.Sprintf(" AND status = $%d", len(args)+1)
+		args = append(args, *status)
+	}
+
+	if skill != "" {
+		query += fmt.Sprintf(" AND skills @> ARRAY[$%d]::text[]", len(args)+1)
+		args = append(args, skill)
+	}
+
+	rows, err := s.db.QueryWithRetry(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get couriers nearby: %w", err)
+	}
+	defer rows.Close()
+
+	couriers, err := scanCouriers(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	// Точная фильтрация по радиусу и сортировка по расстоянию
+	nearby := make([]*models.CourierWithDistance, 0, len(couriers))
+	for _, courier := range couriers {
+		distance := geo.DistanceKm(lat, lon, *courier.CurrentLat, *courier.CurrentLon)
+		if distance <= radiusKm {
+			nearby = append(nearby, &models.CourierWithDistance{Courier: *courier, DistanceKm: distance})
+		}
+	}
+
+	sort.Slice(nearby, func(i, j int) bool {
+		return nearby[i].DistanceKm < nearby[j].DistanceKm
+	})
+
+	if offset >= len(nearby) {
+		return []*models.CourierWithDistance{}, nil
+	}
+	nearby = nearby[offset:]
+	if limit > 0 && limit < len(nearby) {
+		nearby = nearby[:limit]
+	}
+
+	s.setEffectiveAvailability(nearby)
+
+	return nearby, nil
+}
+
+// setEffectiveAvailability отмечает, каких курьеров можно назначить на заказ прямо сейчас:
+// доступных и активных, у которых закончился период ожидания после возвращения в available
+func (s *CourierService) setEffectiveAvailability(couriers []*models.CourierWithDistance) {
+	ids := make([]uuid.UUID, len(couriers))
+	for i, courier := range couriers {
+		ids[i] = courier.ID
+	}
+	graceUntil := s.availabilityGraceUntil(ids)
+
+	for _, courier := range couriers {
+		if until, ok := graceUntil[courier.ID]; ok {
+			courier.AssignableAt = &until
+		}
+		courier.Assignable = courier.Status == models.CourierStatusAvailable && courier.Active && courier.AssignableAt == nil
+	}
+}
+
+// GetAvailableCouriers получает список доступных активных курьеров, находящихся на смене,
+// у которых закончился период ожидания после возвращения в available.
+// Только эти курьеры рассматриваются при назначении заказов
+func (s *CourierService) GetAvailableCouriers() ([]*models.Courier, error) {
+	query := `
+		SELECT c.id, c.name, c.phone, c.status, c.active, c.skills, c.current_lat, c.current_lon,
+		       c.created_at, c.updated_at, c.last_seen_at
+		FROM couriers c
+		WHERE c.status = $1
+		  AND c.active
+		  AND EXISTS (
+		      SELECT 1 FROM courier_shifts cs
+		      WHERE cs.courier_id = c.id AND cs.ended_at IS NULL
+		  )
+		ORDER BY c.created_at DESC
+	`
+
+	rows, err := s.db.QueryWithRetry(query, models.CourierStatusAvailable)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get available couriers: %w", err)
+	}
+	defer rows.Close()
+
+	couriers, err := scanCouriers(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	// Курьеры, недавно вернувшиеся из busy, не назначаются до окончания периода ожидания
+	ids := make([]uuid.UUID, len(couriers))
+	for i, courier := range couriers {
+		ids[i] = courier.ID
+	}
+	graceUntil := s.availabilityGraceUntil(ids)
+	if len(graceUntil) == 0 {
+		return couriers, nil
+	}
+
+	assignable := couriers[:0]
+	for _, courier := range couriers {
+		if _, ok := graceUntil[courier.ID]; !ok {
+			assignable = append(assignable, courier)
+		}
+	}
+	return assignable, nil
+}
+
+// scanCouriers считывает курьеров из результата запроса
+func scanCouriers(rows *sql.Rows) ([]*models.Courier, error) {
 	var couriers []*models.Courier
 	for rows.Next() {
 		courier := &models.Courier{}
-		if err := rows.Scan(&courier.ID, &courier.Name, &courier.Phone, &courier.Status,
+		if err := rows.Scan(&courier.ID, &courier.Name, &courier.Phone, &courier.Status, &courier.Active, pq.Array(&courier.Skills),
 			&courier.CurrentLat, &courier.CurrentLon, &courier.CreatedAt,
 			&courier.UpdatedAt, &courier.LastSeenAt); err != nil {
 			return nil, fmt.Errorf("failed to scan courier: %w", err)
@@ -166,14 +459,247 @@ func (s *CourierService) GetCouriers(status *models.CourierStatus, limit, offset
 	return couriers, nil
 }
 
-// GetAvailableCouriers получает список доступных курьеров
-func (s *CourierService) GetAvailableCouriers() ([]*models.Courier, error) {
-	status := models.CourierStatusAvailable
-	return s.GetCouriers(&status, 0, 0)
+// StartShift открывает смену курьера и переводит его из offline в available.
+// Возвращает открытую смену и статус курьера до начала смены
+func (s *CourierService) StartShift(courierID uuid.UUID) (shift *models.CourierShift, oldStatus models.CourierStatus, err error) {
+	err = s.db.Retry(func() error {
+		shift, oldStatus, err = s.startShift(courierID)
+		return err
+	})
+	return shift, oldStatus, err
 }
 
-// AssignOrderToCourier назначает заказ курьеру
-func (s *CourierService) AssignOrderToCourier(orderID, courierID uuid.UUID) error {
+// startShift выполняет StartShift в одной транзакции
+func (s *CourierService) startShift(courierID uuid.UUID) (*models.CourierShift, models.CourierStatus, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var status models.CourierStatus
+	var active bool
+	err = tx.QueryRow("SELECT status, active FROM couriers WHERE id = $1 FOR UPDATE", courierID).Scan(&status, &active)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, "", newError(models.ErrorCodeCourierNotFound, "courier not found")
+		}
+		return nil, "", fmt.Errorf("failed to get courier: %w", err)
+	}
+
+	if !active {
+		return nil, "", newError(models.ErrorCodeCourierDeactivated, "courier is deactivated")
+	}
+
+	var openShifts int
+	err = tx.QueryRow("SELECT COUNT(*) FROM courier_shifts WHERE courier_id = $1 AND ended_at IS NULL", courierID).Scan(&openShifts)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to check courier shifts: %w", err)
+	}
+	if openShifts > 0 {
+		return nil, "", newError(models.ErrorCodeShiftAlreadyStarted, "courier shift already started")
+	}
+
+	shift := &models.CourierShift{
+		ID:        uuid.New(),
+		CourierID: courierID,
+		StartedAt: time.Now(),
+	}
+
+	_, err = tx.Exec("INSERT INTO courier_shifts (id, courier_id, started_at) VALUES ($1, $2, $3)",
+		shift.ID, shift.CourierID, shift.StartedAt)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create courier shift: %w", err)
+	}
+
+	if status == models.CourierStatusOffline {
+		_, err = tx.Exec("UPDATE couriers SET status = $1, updated_at = $2, last_seen_at = $2 WHERE id = $3",
+			models.CourierStatusAvailable, shift.StartedAt, courierID)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to update courier status: %w", err)
+		}
+	}
+
+	if err = database.Commit(tx); err != nil {
+		return nil, "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.log.WithFields(map[string]interface{}{
+		"courier_id": courierID,
+		"shift_id":   shift.ID,
+	}).Info("Courier shift started")
+
+	return shift, status, nil
+}
+
+// EndShift закрывает открытую смену курьера и переводит его в offline.
+// Возвращает закрытую смену и статус курьера до окончания смены
+func (s *CourierService) EndShift(courierID uuid.UUID) (shift *models.CourierShift, oldStatus models.CourierStatus, err error) {
+	err = s.db.Retry(func() error {
+		shift, oldStatus, err = s.endShift(courierID)
+		return err
+	})
+	return shift, oldStatus, err
+}
+
+// endShift выполняет EndShift в одной транзакции
+func (s *CourierService) endShift(courierID uuid.UUID) (*models.CourierShift, models.CourierStatus, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var status models.CourierStatus
+	err = tx.QueryRow("SELECT status FROM couriers WHERE id = $1 FOR UPDATE", courierID).Scan(&status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, "", newError(models.ErrorCodeCourierNotFound, "courier not found")
+		}
+		return nil, "", fmt.Errorf("failed to get courier: %w", err)
+	}
+
+	if status == models.CourierStatusBusy {
+		return nil, "", newError(models.ErrorCodeCourierHasActiveOrders, "cannot end shift while courier has active orders")
+	}
+
+	now := time.Now()
+	shift := &models.CourierShift{CourierID: courierID, EndedAt: &now}
+
+	query := `
+		UPDATE courier_shifts
+		SET ended_at = $1
+		WHERE courier_id = $2 AND ended_at IS NULL
+		RETURNING id, started_at
+	`
+	err = tx.QueryRow(query, now, courierID).Scan(&shift.ID, &shift.StartedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, "", newError(models.ErrorCodeShiftNotStarted, "courier shift not started")
+		}
+		return nil, "", fmt.Errorf("failed to end courier shift: %w", err)
+	}
+
+	_, err = tx.Exec("UPDATE couriers SET status = $1, updated_at = $2 WHERE id = $3",
+		models.CourierStatusOffline, now, courierID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to update courier status: %w", err)
+	}
+
+	if err = database.Commit(tx); err != nil {
+		return nil, "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.log.WithFields(map[string]interface{}{
+		"courier_id": courierID,
+		"shift_id":   shift.ID,
+		"duration":   now.Sub(shift.StartedAt).String(),
+	}).Info("Courier shift ended")
+
+	return shift, status, nil
+}
+
+// DeactivateCourier отключает курьера, который больше не работает в компании: закрывает
+// открытую смену и переводит его в offline. Курьер с активными заказами не может быть отключен.
+// Возвращает статус курьера до отключения
+func (s *CourierService) DeactivateCourier(courierID uuid.UUID) (oldStatus models.CourierStatus, err error) {
+	err = s.db.Retry(func() error {
+		oldStatus, err = s.deactivateCourier(courierID)
+		return err
+	})
+	return oldStatus, err
+}
+
+// deactivateCourier выполняет DeactivateCourier в одной транзакции
+func (s *CourierService) deactivateCourier(courierID uuid.UUID) (models.CourierStatus, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var status models.CourierStatus
+	var active bool
+	err = tx.QueryRow("SELECT status, active FROM couriers WHERE id = $1 FOR UPDATE", courierID).Scan(&status, &active)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", newError(models.ErrorCodeCourierNotFound, "courier not found")
+		}
+		return "", fmt.Errorf("failed to get courier: %w", err)
+	}
+
+	if !active {
+		return "", newError(models.ErrorCodeCourierDeactivated, "courier is already deactivated")
+	}
+
+	var activeOrders int
+	activeQuery := `
+		SELECT COUNT(*) FROM orders
+		WHERE courier_id = $1 AND status NOT IN ($2, $3)
+	`
+	err = tx.QueryRow(activeQuery, courierID, models.OrderStatusDelivered, models.OrderStatusCancelled).Scan(&activeOrders)
+	if err != nil {
+		return "", fmt.Errorf("failed to check courier orders: %w", err)
+	}
+	if activeOrders > 0 {
+		return "", newError(models.ErrorCodeCourierHasActiveOrders, "cannot deactivate courier with active orders")
+	}
+
+	now := time.Now()
+	if _, err = tx.Exec("UPDATE courier_shifts SET ended_at = $1 WHERE courier_id = $2 AND ended_at IS NULL", now, courierID); err != nil {
+		return "", fmt.Errorf("failed to end courier shift: %w", err)
+	}
+
+	_, err = tx.Exec("UPDATE couriers SET active = FALSE, status = $1, updated_at = $2 WHERE id = $3",
+		models.CourierStatusOffline, now, courierID)
+	if err != nil {
+		return "", fmt.Errorf("failed to deactivate courier: %w", err)
+	}
+
+	if err = database.Commit(tx); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.log.WithField("courier_id", courierID).Info("Courier deactivated")
+
+	return status, nil
+}
+
+// ReactivateCourier возвращает отключенного курьера в работу. Курьер остается offline
+// до начала следующей смены
+func (s *CourierService) ReactivateCourier(courierID uuid.UUID) error {
+	var active bool
+	err := s.db.QueryRowWithRetry("SELECT active FROM couriers WHERE id = $1", courierID).Scan(&active)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return newError(models.ErrorCodeCourierNotFound, "courier not found")
+		}
+		return fmt.Errorf("failed to get courier: %w", err)
+	}
+
+	if active {
+		return newError(models.ErrorCodeCourierAlreadyActive, "courier is already active")
+	}
+
+	if _, err = s.db.Exec("UPDATE couriers SET active = TRUE, updated_at = $1 WHERE id = $2", time.Now(), courierID); err != nil {
+		return fmt.Errorf("failed to reactivate courier: %w", err)
+	}
+
+	s.log.WithField("courier_id", courierID).Info("Courier reactivated")
+
+	return nil
+}
+
+// AssignOrderToCourier назначает заказ курьеру. Назначение курьера, находящегося дальше
+// MaxAssignmentDistanceKm от точки забора или без требуемых заказом навыков, отклоняется, если не передан force
+func (s *CourierService) AssignOrderToCourier(orderID, courierID uuid.UUID, force bool) error {
+	return s.db.Retry(func() error {
+		return s.assignOrderToCourier(orderID, courierID, force)
+	})
+}
+
+// assignOrderToCourier выполняет AssignOrderToCourier в одной транзакции
+func (s *CourierService) assignOrderToCourier(orderID, courierID uuid.UUID, force bool) error {
 	tx, err := s.db.Begin()
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
@@ -182,28 +708,78 @@ func (s *CourierService) AssignOrderToCourier(orderID, courierID uuid.UUID) erro
 
 	// Проверяем, что курьер доступен
 	var courierStatus string
-	courierQuery := "SELECT status FROM couriers WHERE id = $1"
-	err = tx.QueryRow(courierQuery, courierID).Scan(&courierStatus)
+	var courierActive bool
+	var courierLat, courierLon *float64
+	courier := &models.Courier{ID: courierID}
+	courierQuery := "SELECT status, active, current_lat, current_lon, skills FROM couriers WHERE id = $1"
+	err = tx.QueryRow(courierQuery, courierID).Scan(&courierStatus, &courierActive, &courierLat, &courierLon, pq.Array(&courier.Skills))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return fmt.Errorf("courier not found")
+			return newError(models.ErrorCodeCourierNotFound, "courier not found")
 		}
 		return fmt.Errorf("failed to check courier status: %w", err)
 	}
 
+	if !courierActive {
+		return newError(models.ErrorCodeCourierDeactivated, "courier is deactivated")
+	}
+
 	if courierStatus != string(models.CourierStatusAvailable) {
-		return fmt.Errorf("courier is not available")
+		return newError(models.ErrorCodeCourierNotAvailable, "courier is not available")
 	}
 
-	// Назначаем заказ курьеру и меняем статус заказа
-	orderQuery := `
-		UPDATE orders 
-		SET courier_id = $1, status = $2, updated_at = $3
-		WHERE id = $4 AND status = $5
+	if until, ok := s.availabilityGraceUntil([]uuid.UUID{courierID})[courierID]; ok && !force {
+		return newError(models.ErrorCodeCourierNotAvailable, "courier has just become available and can be assigned after %s",
+			until.UTC().Format(time.RFC3339))
+	}
+
+	// Проверяем расстояние от курьера до точки забора заказа и навыки курьера
+	var pickupLat, pickupLon *float64
+	var requiredSkills []string
+	err = tx.QueryRow("SELECT pickup_lat, pickup_lon, required_skills FROM orders WHERE id = $1", orderID).
+		Scan(&pickupLat, &pickupLon, pq.Array(&requiredSkills))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return newError(models.ErrorCodeOrderNotFound, "order not found")
+		}
+		return fmt.Errorf("failed to get order pickup location: %w", err)
+	}
+
+	if !courier.HasSkills(requiredSkills) {
+		if !force {
+			return newError(models.ErrorCodeCourierMissingSkills, "courier does not have all required skills: %s",
+				strings.Join(requiredSkills, ", "))
+		}
+		s.log.WithFields(map[string]interface{}{
+			"order_id":        orderID,
+			"courier_id":      courierID,
+			"required_skills": requiredSkills,
+		}).Warn("Assigning courier without required skills")
+	}
+
+	if distance, ok := s.exceedsAssignmentDistance(courierLat, courierLon, pickupLat, pickupLon); ok {
+		if !force {
+			return newError(models.ErrorCodeCourierTooFar, "courier is too far from pickup: %.1f km (max %.1f km)", distance, s.cfg.MaxAssignmentDistanceKm)
+		}
+		s.log.WithFields(map[string]interface{}{
+			"order_id":    orderID,
+			"courier_id":  courierID,
+			"distance_km": distance,
+		}).Warn("Assigning courier beyond maximum assignment distance")
+	}
+
+	now := time.Now()
+
+	// Занимаем курьера одним условным UPDATE: при одновременных назначениях строка курьера
+	// блокируется, и конкурирующие транзакции после ее фиксации не проходят условие по статусу
+	courierUpdateQuery := `
+		UPDATE couriers 
+		SET status = $1, updated_at = $2
+		WHERE id = $3 AND status = $4 AND active = TRUE
 	`
-	result, err := tx.Exec(orderQuery, courierID, models.OrderStatusAccepted, time.Now(), orderID, models.OrderStatusCreated)
+	result, err := tx.Exec(courierUpdateQuery, models.CourierStatusBusy, now, courierID, models.CourierStatusAvailable)
 	if err != nil {
-		return fmt.Errorf("failed to assign order to courier: %w", err)
+		return fmt.Errorf("failed to update courier status: %w", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
@@ -212,21 +788,30 @@ func (s *CourierService) AssignOrderToCourier(orderID, courierID uuid.UUID) erro
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("order not found or already assigned")
+		return newError(models.ErrorCodeCourierNotAvailable, "courier is not available")
 	}
 
-	// Меняем статус курьера на "занят"
-	courierUpdateQuery := `
-		UPDATE couriers 
-		SET status = $1, updated_at = $2
-		WHERE id = $3
+	// Назначаем заказ курьеру и меняем статус заказа
+	orderQuery := `
+		UPDATE orders 
+		SET courier_id = $1, status = $2, updated_at = $3, version = version + 1
+		WHERE id = $4 AND status = $5 AND courier_id IS NULL
 	`
-	_, err = tx.Exec(courierUpdateQuery, models.CourierStatusBusy, time.Now(), courierID)
+	result, err = tx.Exec(orderQuery, courierID, models.OrderStatusAccepted, now, orderID, models.OrderStatusCreated)
 	if err != nil {
-		return fmt.Errorf("failed to update courier status: %w", err)
+		return fmt.Errorf("failed to assign order to courier: %w", err)
+	}
+
+	rowsAffected, err = result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return newError(models.ErrorCodeOrderAlreadyAssigned, "order is already assigned")
 	}
 
-	if err = tx.Commit(); err != nil {
+	if err = database.Commit(tx); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
@@ -237,3 +822,217 @@ func (s *CourierService) AssignOrderToCourier(orderID, courierID uuid.UUID) erro
 
 	return nil
 }
+
+// GetCourierDeliveries возвращает доставленные курьером заказы, новые первыми, с итогами по всему периоду.
+// from и to ограничивают время доставки (to не включается), nil не ограничивает.
+// Момент передачи в доставку берется из истории статусов заказа
+func (s *CourierService) GetCourierDeliveries(courierID uuid.UUID, from, to *time.Time, limit, offset int) (*models.CourierDeliveries, error) {
+	var exists bool
+	if err := s.db.QueryRowWithRetry("SELECT EXISTS(SELECT 1 FROM couriers WHERE id = $1)", courierID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to check courier: %w", err)
+	}
+	if !exists {
+		return nil, newError(models.ErrorCodeCourierNotFound, "courier not found")
+	}
+
+	deliveries := `
+		WITH deliveries AS (
+			SELECT o.id, o.delivery_address, o.total_amount, o.delivery_cost, o.created_at, o.delivered_at,
+			       (SELECT MIN(h.changed_at) FROM order_status_history h
+			        WHERE h.order_id = o.id AND h.new_status = $2) AS picked_up_at
+			FROM orders o
+			WHERE o.courier_id = $1 AND o.status = $3 AND o.delivered_at IS NOT NULL
+			  AND ($4::timestamptz IS NULL OR o.delivered_at >= $4)
+			  AND ($5::timestamptz IS NULL OR o.delivered_at < $5)
+		)
+	`
+	args := []interface{}{courierID, models.OrderStatusInDelivery, models.OrderStatusDelivered, from, to}
+
+	result := &models.CourierDeliveries{Deliveries: []*models.CourierDelivery{}}
+
+	var avgDuration sql.NullFloat64
+	totalsQuery := deliveries + `
+		SELECT COUNT(*), COALESCE(SUM(total_amount), 0),
+		       AVG(EXTRACT(EPOCH FROM delivered_at - picked_up_at))
+		FROM deliveries
+	`
+	err := s.db.QueryRowWithRetry(totalsQuery, args...).Scan(&result.Totals.Count, &result.Totals.TotalAmount, &avgDuration)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get courier delivery totals: %w", err)
+	}
+	if avgDuration.Valid {
+		result.Totals.AverageDeliveryDurationSeconds = &avgDuration.Float64
+	}
+
+	pageQuery := deliveries + `
+		SELECT id, delivery_address, total_amount, delivery_cost, created_at, picked_up_at, delivered_at
+		FROM deliveries
+		ORDER BY delivered_at DESC
+		LIMIT $6 OFFSET $7
+	`
+	rows, err := s.db.QueryWithRetry(pageQuery, append(args, limit, offset)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get courier deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		d := &models.CourierDelivery{}
+		err := rows.Scan(&d.OrderID, &d.DeliveryAddress, &d.TotalAmount, &d.DeliveryCost, &d.CreatedAt, &d.PickedUpAt, &d.DeliveredAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan courier delivery: %w", err)
+		}
+
+		if d.PickedUpAt != nil && !d.DeliveredAt.Before(*d.PickedUpAt) {
+			duration := int64(d.DeliveredAt.Sub(*d.PickedUpAt) / time.Second)
+			d.DeliveryDurationSeconds = &duration
+		}
+		result.Deliveries = append(result.Deliveries, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate courier deliveries: %w", err)
+	}
+
+	return result, nil
+}
+
+// StreamCourierReport вычисляет показатели каждого курьера за период [from, to) и передает их fn
+// по мере чтения из базы данных, в порядке имени курьера. Доставки учитываются по времени вручения,
+// отмены - по времени перевода назначенного курьеру заказа в cancelled. Ошибка fn прерывает отчет
+func (s *CourierService) StreamCourierReport(from, to *time.Time, fn func(*models.CourierReportRow) error) error {
+	query := `
+		WITH deliveries AS (
+			SELECT o.courier_id, o.delivery_cost, o.delivered_at,
+			       (SELECT MIN(h.changed_at) FROM order_status_history h
+			        WHERE h.order_id = o.id AND h.new_status = $1) AS picked_up_at
+			FROM orders o
+			WHERE o.courier_id IS NOT NULL AND o.status = $2 AND o.delivered_at IS NOT NULL
+			  AND ($3::timestamptz IS NULL OR o.delivered_at >= $3)
+			  AND ($4::timestamptz IS NULL OR o.delivered_at < $4)
+		), delivered AS (
+			SELECT courier_id, COUNT(*) AS count,
+			       AVG(EXTRACT(EPOCH FROM delivered_at - picked_up_at)) AS avg_duration,
+			       COALESCE(SUM(delivery_cost), 0) AS earnings
+			FROM deliveries
+			GROUP BY courier_id
+		), cancelled AS (
+			SELECT h.courier_id, COUNT(DISTINCT h.order_id) AS count
+			FROM order_status_history h
+			WHERE h.new_status = $5 AND h.courier_id IS NOT NULL
+			  AND ($3::timestamptz IS NULL OR h.changed_at >= $3)
+			  AND ($4::timestamptz IS NULL OR h.changed_at < $4)
+			GROUP BY h.courier_id
+		)
+		SELECT c.id, c.name, COALESCE(d.count, 0), d.avg_duration, COALESCE(d.earnings, 0), COALESCE(x.count, 0)
+		FROM couriers c
+		LEFT JOIN delivered d ON d.courier_id = c.id
+		LEFT JOIN cancelled x ON x.courier_id = c.id
+		ORDER BY c.name, c.id
+	`
+
+	rows, err := s.db.QueryWithRetry(query, models.OrderStatusInDelivery, models.OrderStatusDelivered, from, to, models.OrderStatusCancelled)
+	if err != nil {
+		return fmt.Errorf("failed to get courier report: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		row := &models.CourierReportRow{}
+		var avgDuration sql.NullFloat64
+		err := rows.Scan(&row.CourierID, &row.Name, &row.DeliveredOrders, &avgDuration, &row.TotalEarnings, &row.CancelledOrders)
+		if err != nil {
+			return fmt.Errorf("failed to scan courier report row: %w", err)
+		}
+		if avgDuration.Valid {
+			row.AverageDeliveryDurationSeconds = &avgDuration.Float64
+		}
+
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate courier report: %w", err)
+	}
+
+	return nil
+}
+
+// RankCouriersForOrder подбирает курьеров для автоматического назначения заказа: доступных курьеров
+// на смене со всеми требуемыми заказом навыками и известным местоположением в пределах MaxAssignmentDistanceKm,
+// ближайшие к точке забора первыми.
+// Ничего не изменяет; limit ограничивает число курьеров в результате (0 - без ограничения)
+func (s *CourierService) RankCouriersForOrder(orderID uuid.UUID, limit int) ([]*models.CourierWithDistance, error) {
+	var status models.OrderStatus
+	var courierID *uuid.UUID
+	var pickupLat, pickupLon *float64
+	var requiredSkills []string
+	err := s.db.QueryRowWithRetry("SELECT status, courier_id, pickup_lat, pickup_lon, required_skills FROM orders WHERE id = $1", orderID).
+		Scan(&status, &courierID, &pickupLat, &pickupLon, pq.Array(&requiredSkills))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, newError(models.ErrorCodeOrderNotFound, "order not found")
+		}
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	if courierID != nil {
+		return nil, newError(models.ErrorCodeOrderAlreadyAssigned, "order is already assigned")
+	}
+	if status != models.OrderStatusCreated {
+		return nil, newError(models.ErrorCodeInvalidTransition, "order cannot be assigned in status %s", status)
+	}
+	if pickupLat == nil || pickupLon == nil {
+		return nil, newError(models.ErrorCodePickupLocationUnknown, "order pickup location is unknown")
+	}
+
+	couriers, err := s.GetAvailableCouriers()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.rankCouriers(couriers, requiredSkills, *pickupLat, *pickupLon, limit), nil
+}
+
+// rankCouriers упорядочивает курьеров по расстоянию до точки забора, отбрасывая курьеров
+// без требуемых навыков, без местоположения и дальше MaxAssignmentDistanceKm
+func (s *CourierService) rankCouriers(couriers []*models.Courier, requiredSkills []string, pickupLat, pickupLon float64, limit int) []*models.CourierWithDistance {
+	ranked := make([]*models.CourierWithDistance, 0, len(couriers))
+	for _, courier := range couriers {
+		if !courier.HasSkills(requiredSkills) {
+			continue
+		}
+		if courier.CurrentLat == nil || courier.CurrentLon == nil {
+			continue
+		}
+
+		distance := geo.DistanceKm(*courier.CurrentLat, *courier.CurrentLon, pickupLat, pickupLon)
+		if s.cfg.MaxAssignmentDistanceKm > 0 && distance > s.cfg.MaxAssignmentDistanceKm {
+			continue
+		}
+		ranked = append(ranked, &models.CourierWithDistance{Courier: *courier, DistanceKm: distance})
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].DistanceKm < ranked[j].DistanceKm
+	})
+
+	if limit > 0 && limit < len(ranked) {
+		ranked = ranked[:limit]
+	}
+	return ranked
+}
+
+// exceedsAssignmentDistance возвращает расстояние от курьера до точки забора и true,
+// если оно превышает MaxAssignmentDistanceKm. При неизвестных координатах проверка не выполняется
+func (s *CourierService) exceedsAssignmentDistance(courierLat, courierLon, pickupLat, pickupLon *float64) (float64, bool) {
+	if s.cfg.MaxAssignmentDistanceKm <= 0 ||
+		courierLat == nil || courierLon == nil || pickupLat == nil || pickupLon == nil {
+		return 0, false
+	}
+
+	distance := geo.DistanceKm(*courierLat, *courierLon, *pickupLat, *pickupLon)
+	return distance, distance > s.cfg.MaxAssignmentDistanceKm
+}
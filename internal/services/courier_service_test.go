@@ -0,0 +1,111 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"delivery-system/internal/config"
+	"delivery-system/internal/database"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// connectTestDB подключается к PostgreSQL с примененными миграциями по переменным DB_*.
+// Без DB_HOST тест пропускается
+func connectTestDB(t *testing.T) (*database.DB, *logger.Logger) {
+	t.Helper()
+
+	if os.Getenv("DB_HOST") == "" {
+		t.Skip("DB_HOST is not set, skipping PostgreSQL integration test")
+	}
+
+	cfg := config.Load()
+	log := logger.New(&cfg.Logger)
+	db, err := database.Connect(&cfg.Database, log)
+	if err != nil {
+		t.Fatalf("failed to connect to database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, log
+}
+
+// createTestCourier создает свободного курьера без координат и навыков
+func createTestCourier(t *testing.T, db *database.DB) uuid.UUID {
+	t.Helper()
+
+	id := uuid.New()
+	_, err := db.Exec("INSERT INTO couriers (id, name, phone, status) VALUES ($1, $2, $3, $4)",
+		id, "Тестовый курьер", fmt.Sprintf("+7%010d", id.ID()), models.CourierStatusAvailable)
+	if err != nil {
+		t.Fatalf("failed to create courier: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM orders WHERE courier_id = $1", id)
+		db.Exec("DELETE FROM couriers WHERE id = $1", id)
+	})
+	return id
+}
+
+// createTestOrder создает новый заказ без точки забора
+func createTestOrder(t *testing.T, db *database.DB) uuid.UUID {
+	t.Helper()
+
+	id := uuid.New()
+	_, err := db.Exec("INSERT INTO orders (id, customer_name, customer_phone, delivery_address, total_amount) VALUES ($1, $2, $3, $4, $5)",
+		id, "Тестовый клиент", "+79990000000", "ул. Тестовая, д. 1", 100)
+	if err != nil {
+		t.Fatalf("failed to create order: %v", err)
+	}
+	t.Cleanup(func() { db.Exec("DELETE FROM orders WHERE id = $1", id) })
+	return id
+}
+
+func TestAssignOrderToCourierConcurrent(t *testing.T) {
+	db, log := connectTestDB(t)
+	s := NewCourierService(db, nil, &config.AssignmentConfig{}, log)
+
+	courierID := createTestCourier(t, db)
+	orderIDs := []uuid.UUID{createTestOrder(t, db), createTestOrder(t, db)}
+
+	errs := make([]error, len(orderIDs))
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i, orderID := range orderIDs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			errs[i] = s.AssignOrderToCourier(orderID, courierID, false)
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	succeeded, unavailable := 0, 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case errors.Is(err, ErrCourierUnavailable):
+			unavailable++
+		default:
+			t.Fatalf("unexpected assignment error: %v", err)
+		}
+	}
+	if succeeded != 1 || unavailable != 1 {
+		t.Fatalf("expected one success and one ErrCourierUnavailable, got %d and %d (%v)", succeeded, unavailable, errs)
+	}
+
+	var assigned int
+	if err := db.QueryRow("SELECT COUNT(*) FROM orders WHERE courier_id = $1", courierID).Scan(&assigned); err != nil {
+		t.Fatalf("failed to count assigned orders: %v", err)
+	}
+	if assigned != 1 {
+		t.Fatalf("expected courier to have 1 assigned order, got %d", assigned)
+	}
+}
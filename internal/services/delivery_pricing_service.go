@@ -0,0 +1,53 @@
+package services
+
+import (
+	"math"
+
+	"delivery-system/internal/config"
+	"delivery-system/internal/models"
+)
+
+// DeliveryCostInput представляет параметры расчета стоимости доставки
+type DeliveryCostInput struct {
+	DistanceKm  float64
+	WeightGrams int
+}
+
+// DeliveryPricingService представляет сервис расчета стоимости доставки по тарифам
+type DeliveryPricingService struct {
+	cfg *config.DeliveryPricingConfig
+}
+
+// NewDeliveryPricingService создает новый экземпляр сервиса расчета стоимости доставки
+func NewDeliveryPricingService(cfg *config.DeliveryPricingConfig) *DeliveryPricingService {
+	return &DeliveryPricingService{
+		cfg: cfg,
+	}
+}
+
+// CalculateDeliveryCost рассчитывает стоимость доставки: базовая цена и цена за километр
+// ограничиваются минимальной и максимальной стоимостью, затем добавляется надбавка за вес,
+// чтобы тяжелые заказы не упирались в максимальную стоимость
+func (s *DeliveryPricingService) CalculateDeliveryCost(input DeliveryCostInput) models.Money {
+	cost := s.cfg.BasePrice + s.cfg.PricePerKm*math.Max(input.DistanceKm, 0)
+
+	if s.cfg.MinPrice > 0 && cost < s.cfg.MinPrice {
+		cost = s.cfg.MinPrice
+	}
+	if s.cfg.MaxPrice > 0 && cost > s.cfg.MaxPrice {
+		cost = s.cfg.MaxPrice
+	}
+
+	return models.NewMoneyFromFloat(cost) + s.weightSurcharge(input.WeightGrams)
+}
+
+// weightSurcharge рассчитывает надбавку за каждый начатый килограмм сверх бесплатного веса
+func (s *DeliveryPricingService) weightSurcharge(weightGrams int) models.Money {
+	excess := weightGrams - s.cfg.FreeWeightGrams
+	if excess <= 0 || s.cfg.PricePerKg <= 0 {
+		return 0
+	}
+
+	kilograms := (excess + 999) / 1000
+	return models.NewMoneyFromFloat(s.cfg.PricePerKg).Mul(kilograms)
+}
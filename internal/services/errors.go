@@ -0,0 +1,71 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"delivery-system/internal/models"
+)
+
+// Категории ошибок бизнес-логики для проверки через errors.Is
+var (
+	ErrNotFound           = errors.New("not found")
+	ErrCourierUnavailable = errors.New("courier unavailable")
+	ErrCourierTooFar      = errors.New("courier too far")
+	ErrOutOfRange         = errors.New("out of range")
+	ErrMissingLocation    = errors.New("missing location")
+	ErrInvalidTransition  = errors.New("invalid transition")
+	ErrConflict           = errors.New("conflict")
+	ErrUnknownReference   = errors.New("unknown reference")
+)
+
+// errorKinds сопоставляет коды ошибок с их категориями
+var errorKinds = map[models.ErrorCode]error{
+	models.ErrorCodeOrderNotFound:               ErrNotFound,
+	models.ErrorCodeOrderItemNotFound:           ErrNotFound,
+	models.ErrorCodeCourierNotFound:             ErrNotFound,
+	models.ErrorCodeWebhookSubscriptionNotFound: ErrNotFound,
+	models.ErrorCodeCourierNotAvailable:         ErrCourierUnavailable,
+	models.ErrorCodeCourierDeactivated:          ErrCourierUnavailable,
+	models.ErrorCodeCourierMissingSkills:        ErrCourierUnavailable,
+	models.ErrorCodeCourierTooFar:               ErrCourierTooFar,
+	models.ErrorCodeDeliveryOutOfRange:          ErrOutOfRange,
+	models.ErrorCodePickupLocationUnknown:       ErrMissingLocation,
+	models.ErrorCodeDeliveryRouteUnknown:        ErrMissingLocation,
+	models.ErrorCodeDeliveryCostOverridden:      ErrConflict,
+	models.ErrorCodeInvalidTransition:           ErrInvalidTransition,
+	models.ErrorCodeOrderNotAssigned:            ErrInvalidTransition,
+	models.ErrorCodeOrderAlreadyAssigned:        ErrConflict,
+	models.ErrorCodeOrderVersionMismatch:        ErrConflict,
+	models.ErrorCodeOrderLastItem:               ErrConflict,
+	models.ErrorCodeOrderItemPriceFixed:         ErrConflict,
+	models.ErrorCodeDuplicateOrderInProgress:    ErrConflict,
+	models.ErrorCodeCourierAlreadyActive:        ErrConflict,
+	models.ErrorCodeCourierHasActiveOrders:      ErrConflict,
+	models.ErrorCodeProductNotFound:             ErrUnknownReference,
+	models.ErrorCodeShiftAlreadyStarted:         ErrConflict,
+	models.ErrorCodeShiftNotStarted:             ErrConflict,
+}
+
+// Error представляет ожидаемую ошибку бизнес-логики с машиночитаемым кодом.
+// Категорию ошибки можно проверить через errors.Is(err, ErrNotFound) и т.д.
+type Error struct {
+	Code    models.ErrorCode
+	Message string
+}
+
+// Error реализует интерфейс error
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is сравнивает ошибку с категорией, соответствующей ее коду
+func (e *Error) Is(target error) bool {
+	kind, ok := errorKinds[e.Code]
+	return ok && kind == target
+}
+
+// newError создает ошибку бизнес-логики с указанным кодом
+func newError(code models.ErrorCode, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"delivery-system/internal/clock"
+	"delivery-system/internal/config"
+	"delivery-system/internal/database"
+	"delivery-system/internal/kafka"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// EscalationService отслеживает заказы, слишком долго ожидающие назначения курьера
+type EscalationService struct {
+	db       *database.DB
+	producer *kafka.Producer
+	cfg      *config.EscalationConfig
+	clock    clock.Clock
+	log      *logger.Logger
+}
+
+// NewEscalationService создает новый экземпляр сервиса эскалации
+func NewEscalationService(db *database.DB, producer *kafka.Producer, cfg *config.EscalationConfig, clk clock.Clock, log *logger.Logger) *EscalationService {
+	return &EscalationService{
+		db:       db,
+		producer: producer,
+		cfg:      cfg,
+		clock:    clk,
+		log:      log,
+	}
+}
+
+// Run периодически проверяет неназначенные заказы до отмены контекста.
+// Неположительный интервал проверки отключает мониторинг.
+func (s *EscalationService) Run(ctx context.Context) {
+	interval := time.Duration(s.cfg.CheckInterval) * time.Second
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.EscalateUnassignedOrders(ctx); err != nil {
+				s.log.WithError(err).Error("Failed to escalate unassigned orders")
+			}
+		}
+	}
+}
+
+// EscalateUnassignedOrders находит заказы в статусе "создан" старше порога,
+// помечает их как эскалированные (при необходимости повышая приоритет)
+// и публикует событие order.unassigned_timeout. Каждый заказ эскалируется один раз.
+func (s *EscalationService) EscalateUnassignedOrders(ctx context.Context) (int, error) {
+	now := s.clock.Now()
+	cutoff := now.Add(-time.Duration(s.cfg.UnassignedTimeout) * time.Second)
+
+	bump := 0
+	if s.cfg.BumpPriority {
+		bump = 1
+	}
+
+	query := `
+		UPDATE orders
+		SET escalated_at = $1, priority = priority + $2, version = version + 1
+		WHERE status = $3 AND escalated_at IS NULL AND created_at < $4
+		RETURNING id, created_at, priority
+	`
+
+	rows, err := s.db.Query(query, now, bump, models.OrderStatusCreated, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to escalate unassigned orders: %w", err)
+	}
+	defer rows.Close()
+
+	type escalatedOrder struct {
+		id        uuid.UUID
+		createdAt time.Time
+		priority  int
+	}
+
+	var escalated []escalatedOrder
+	for rows.Next() {
+		var o escalatedOrder
+		if err := rows.Scan(&o.id, &o.createdAt, &o.priority); err != nil {
+			return 0, fmt.Errorf("failed to scan escalated order: %w", err)
+		}
+		escalated = append(escalated, o)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read escalated orders: %w", err)
+	}
+
+	for _, o := range escalated {
+		if err := s.producer.PublishOrderUnassignedTimeout(ctx, o.id, o.createdAt, o.priority); err != nil {
+			s.log.WithError(err).WithField("order_id", o.id).Error("Failed to publish order unassigned timeout event")
+		}
+
+		s.log.WithFields(map[string]interface{}{
+			"order_id":   o.id,
+			"created_at": o.createdAt,
+			"priority":   o.priority,
+		}).Warn("Order has been waiting for a courier too long")
+	}
+
+	return len(escalated), nil
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"sync"
+
+	"delivery-system/internal/config"
+	"delivery-system/internal/geo"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// offerBufferSize сколько предложений может ждать отправки подписчику. Если клиент не успевает
+// их читать, новые предложения для него отбрасываются, чтобы не задерживать обработку событий
+const offerBufferSize = 16
+
+// offerSubscription представляет подписку курьера на предложения заказов
+type offerSubscription struct {
+	courierID uuid.UUID
+	// fallbackLat и fallbackLon местоположение курьера из базы данных на момент подписки,
+	// используется, пока в кеше нет более свежего
+	fallbackLat, fallbackLon *float64
+	offers                   chan *models.OrderOffer
+}
+
+// OrderOfferHub рассылает подписанным курьерам новые заказы из событий order.created,
+// точка забора которых находится в пределах MaxAssignmentDistanceKm от курьера
+type OrderOfferHub struct {
+	courierService *CourierService
+	locationCache  *CourierLocationCache
+	cfg            *config.AssignmentConfig
+	log            *logger.Logger
+
+	mu            sync.Mutex
+	subscriptions map[*offerSubscription]struct{}
+	closed        bool
+}
+
+// NewOrderOfferHub создает новый hub предложений заказов
+func NewOrderOfferHub(courierService *CourierService, locationCache *CourierLocationCache, cfg *config.AssignmentConfig, log *logger.Logger) *OrderOfferHub {
+	return &OrderOfferHub{
+		courierService: courierService,
+		locationCache:  locationCache,
+		cfg:            cfg,
+		log:            log,
+		subscriptions:  make(map[*offerSubscription]struct{}),
+	}
+}
+
+// Subscribe подписывает курьера на предложения заказов. Канал закрывается при отмене ctx
+// или остановке hub; отключенные курьеры подписаться не могут
+func (h *OrderOfferHub) Subscribe(ctx context.Context, courierID uuid.UUID) (<-chan *models.OrderOffer, error) {
+	courier, err := h.courierService.GetCourier(courierID)
+	if err != nil {
+		return nil, err
+	}
+	if !courier.Active {
+		return nil, newError(models.ErrorCodeCourierDeactivated, "courier is deactivated")
+	}
+
+	sub := &offerSubscription{
+		courierID:   courierID,
+		fallbackLat: courier.CurrentLat,
+		fallbackLon: courier.CurrentLon,
+		offers:      make(chan *models.OrderOffer, offerBufferSize),
+	}
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		close(sub.offers)
+		return sub.offers, nil
+	}
+	h.subscriptions[sub] = struct{}{}
+	h.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		h.unsubscribe(sub)
+	}()
+
+	return sub.offers, nil
+}
+
+// unsubscribe удаляет подписку и закрывает ее канал
+func (h *OrderOfferHub) unsubscribe(sub *offerSubscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscriptions[sub]; ok {
+		delete(h.subscriptions, sub)
+		close(sub.offers)
+	}
+}
+
+// Close закрывает все подписки, чтобы потоки предложений не задерживали остановку сервера
+func (h *OrderOfferHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for sub := range h.subscriptions {
+		delete(h.subscriptions, sub)
+		close(sub.offers)
+	}
+}
+
+// HandleOrderCreated реализует kafka.EventHandler для события order.created.
+// Заказы без координат точки забора не предлагаются
+func (h *OrderOfferHub) HandleOrderCreated(ctx context.Context, event *models.Event) error {
+	var data models.OrderCreatedEvent
+	if err := event.DecodeData(&data); err != nil {
+		return err
+	}
+	if data.PickupLat == nil || data.PickupLon == nil {
+		return nil
+	}
+
+	// Расстояния считаются без блокировки: местоположение курьера читается из Redis
+	h.mu.Lock()
+	subs := make([]*offerSubscription, 0, len(h.subscriptions))
+	for sub := range h.subscriptions {
+		subs = append(subs, sub)
+	}
+	h.mu.Unlock()
+
+	offers := make(map[*offerSubscription]*models.OrderOffer)
+	for _, sub := range subs {
+		if offer, ok := h.offerFor(ctx, sub, &data); ok {
+			offers[sub] = offer
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub, offer := range offers {
+		// Подписка могла завершиться, пока считались расстояния, и ее канал уже закрыт
+		if _, ok := h.subscriptions[sub]; !ok {
+			continue
+		}
+
+		select {
+		case sub.offers <- offer:
+		default:
+			h.log.WithField("courier_id", sub.courierID).
+				WithField("order_id", data.OrderID).
+				Warn("Order offer dropped, subscriber is not reading")
+		}
+	}
+	return nil
+}
+
+// offerFor возвращает предложение заказа для подписчика, если курьер находится в пределах радиуса
+func (h *OrderOfferHub) offerFor(ctx context.Context, sub *offerSubscription, data *models.OrderCreatedEvent) (*models.OrderOffer, bool) {
+	lat, lon := sub.fallbackLat, sub.fallbackLon
+	if location, ok := h.locationCache.Get(ctx, sub.courierID); ok {
+		lat, lon = &location.Lat, &location.Lon
+	}
+	if lat == nil || lon == nil {
+		return nil, false
+	}
+
+	distance := geo.DistanceKm(*lat, *lon, *data.PickupLat, *data.PickupLon)
+	if h.cfg.MaxAssignmentDistanceKm > 0 && distance > h.cfg.MaxAssignmentDistanceKm {
+		return nil, false
+	}
+
+	return &models.OrderOffer{
+		OrderID:         data.OrderID,
+		DeliveryAddress: data.DeliveryAddress,
+		TotalAmount:     data.TotalAmount,
+		PickupLat:       *data.PickupLat,
+		PickupLon:       *data.PickupLon,
+		DistanceKm:      distance,
+		CreatedAt:       data.CreatedAt,
+	}, true
+}
@@ -1,33 +1,221 @@
 package services
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
+	"delivery-system/internal/config"
 	"delivery-system/internal/database"
+	"delivery-system/internal/geo"
 	"delivery-system/internal/logger"
 	"delivery-system/internal/models"
+	"delivery-system/internal/redis"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 // OrderService представляет сервис для работы с заказами
 type OrderService struct {
-	db  *database.DB
-	log *logger.Logger
+	db          *database.DB
+	redisClient *redis.Client
+	pricing     *DeliveryPricingService
+	cfg         *config.OrderConfig
+	log         *logger.Logger
 }
 
 // NewOrderService создает новый экземпляр сервиса заказов
-func NewOrderService(db *database.DB, log *logger.Logger) *OrderService {
+func NewOrderService(db *database.DB, redisClient *redis.Client, pricing *DeliveryPricingService, cfg *config.OrderConfig, log *logger.Logger) *OrderService {
 	return &OrderService{
-		db:  db,
-		log: log,
+		db:          db,
+		redisClient: redisClient,
+		pricing:     pricing,
+		cfg:         cfg,
+		log:         log,
 	}
 }
 
-// CreateOrder создает новый заказ
-func (s *OrderService) CreateOrder(req *models.CreateOrderRequest) (*models.Order, error) {
+// CreateOrder создает новый заказ. Если включен поиск дубликатов и такой же заказ
+// уже был создан в пределах окна, возвращается существующий заказ и duplicate = true
+func (s *OrderService) CreateOrder(ctx context.Context, req *models.CreateOrderRequest) (order *models.Order, duplicate bool, err error) {
+	if err := s.checkDeliveryRadius(req); err != nil {
+		return nil, false, err
+	}
+
+	// Цены из каталога подставляются до поиска дубликатов, чтобы отпечаток заказа
+	// не зависел от цен, переданных клиентом
+	if err := s.applyCatalogPrices(req.Items); err != nil {
+		return nil, false, err
+	}
+
+	orderID := uuid.New()
+
+	if s.cfg.DedupEnabled {
+		dedupKey := redis.GenerateKey(redis.KeyPrefixOrderDedup, orderFingerprint(req))
+		existingID, reserved, reserveErr := s.reserveDedupKey(ctx, dedupKey, orderID)
+		if reserveErr != nil {
+			// При недоступности Redis создаем заказ без проверки дубликатов
+			s.log.WithError(reserveErr).Warn("Failed to check order duplicates")
+		} else if !reserved {
+			existing, getErr := s.GetOrder(existingID)
+			if getErr != nil {
+				if errors.Is(getErr, ErrNotFound) {
+					return nil, false, newError(models.ErrorCodeDuplicateOrderInProgress, "duplicate order is still being created")
+				}
+				return nil, false, getErr
+			}
+
+			s.log.WithFields(map[string]interface{}{
+				"order_id":       existing.ID,
+				"customer_phone": req.CustomerPhone,
+			}).Info("Duplicate order detected, returning existing order")
+			return existing, true, nil
+		} else {
+			defer func() {
+				// Освобождаем ключ, если заказ так и не был создан
+				if err != nil {
+					if delErr := s.redisClient.Delete(ctx, dedupKey); delErr != nil {
+						s.log.WithError(delErr).Warn("Failed to release order dedup key")
+					}
+				}
+			}()
+		}
+	}
+
+	err = s.db.Retry(func() error {
+		order, err = s.createOrder(orderID, req)
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return order, false, nil
+}
+
+// checkDeliveryRadius отклоняет заказ, точка доставки которого дальше MaxDeliveryRadiusKm
+// от точки забора. Заказы без координат обеих точек не проверяются
+func (s *OrderService) checkDeliveryRadius(req *models.CreateOrderRequest) error {
+	if s.cfg.MaxDeliveryRadiusKm <= 0 || req.PickupLat == nil || req.PickupLon == nil ||
+		req.DeliveryLat == nil || req.DeliveryLon == nil {
+		return nil
+	}
+
+	distance := geo.DistanceKm(*req.PickupLat, *req.PickupLon, *req.DeliveryLat, *req.DeliveryLon)
+	if distance > s.cfg.MaxDeliveryRadiusKm {
+		return newError(models.ErrorCodeDeliveryOutOfRange,
+			"delivery distance %.1f km exceeds maximum delivery radius %.1f km", distance, s.cfg.MaxDeliveryRadiusKm)
+	}
+	return nil
+}
+
+// applyCatalogPrices заменяет название, цену и вес позиций со ссылкой на SKU данными из каталога товаров.
+// Позиции без SKU сохраняются с ценой клиента. Неизвестный или снятый с продажи SKU отклоняет заказ
+func (s *OrderService) applyCatalogPrices(items []models.CreateOrderItemRequest) error {
+	skus := make([]string, 0, len(items))
+	for _, item := range items {
+		if item.SKU != "" {
+			skus = append(skus, item.SKU)
+		}
+	}
+	if len(skus) == 0 {
+		return nil
+	}
+
+	rows, err := s.db.QueryWithRetry("SELECT sku, name, price, weight_grams FROM products WHERE sku = ANY($1) AND active", pq.Array(skus))
+	if err != nil {
+		return fmt.Errorf("failed to get products: %w", err)
+	}
+	defer rows.Close()
+
+	products := make(map[string]models.Product, len(skus))
+	for rows.Next() {
+		var product models.Product
+		if err := rows.Scan(&product.SKU, &product.Name, &product.Price, &product.WeightGrams); err != nil {
+			return fmt.Errorf("failed to scan product: %w", err)
+		}
+		products[product.SKU] = product
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to get products: %w", err)
+	}
+
+	for i := range items {
+		if items[i].SKU == "" {
+			continue
+		}
+		product, ok := products[items[i].SKU]
+		if !ok {
+			return newError(models.ErrorCodeProductNotFound, "product %s not found in catalog", items[i].SKU)
+		}
+		if items[i].Price != product.Price {
+			s.log.WithFields(map[string]interface{}{
+				"sku":          product.SKU,
+				"client_price": items[i].Price,
+				"price":        product.Price,
+			}).Debug("Ignoring client item price in favor of catalog price")
+		}
+		items[i].Name = product.Name
+		items[i].Price = product.Price
+		items[i].WeightGrams = product.WeightGrams
+	}
+
+	return nil
+}
+
+// reserveDedupKey атомарно закрепляет ключ дубликата за новым заказом.
+// Если ключ уже занят, возвращает ID ранее созданного заказа
+func (s *OrderService) reserveDedupKey(ctx context.Context, key string, orderID uuid.UUID) (uuid.UUID, bool, error) {
+	window := time.Duration(s.cfg.DedupWindow) * time.Second
+	reserved, err := s.redisClient.SetNX(ctx, key, orderID, window)
+	if err != nil || reserved {
+		return uuid.Nil, reserved, err
+	}
+
+	var existingID uuid.UUID
+	if err := s.redisClient.Get(ctx, key, &existingID); err != nil {
+		if errors.Is(err, redis.ErrKeyNotFound) {
+			// Ключ истек между проверками - повторяем резервирование
+			return s.reserveDedupKey(ctx, key, orderID)
+		}
+		return uuid.Nil, false, err
+	}
+
+	return existingID, false, nil
+}
+
+// orderFingerprint вычисляет хеш заказа по телефону клиента, адресу и составу.
+// Порядок позиций не учитывается
+func orderFingerprint(req *models.CreateOrderRequest) string {
+	items := make([]string, 0, len(req.Items))
+	for _, item := range req.Items {
+		items = append(items, fmt.Sprintf("%s:%s:%d:%s", item.SKU, strings.ToLower(strings.TrimSpace(item.Name)), item.Quantity, item.Price))
+	}
+	sort.Strings(items)
+
+	skills := slices.Clone(req.RequiredSkills)
+	sort.Strings(skills)
+
+	data := strings.Join([]string{
+		strings.TrimSpace(req.CustomerPhone),
+		strings.ToLower(strings.Join(strings.Fields(req.DeliveryAddress), " ")),
+		strings.Join(items, ";"),
+		strings.Join(skills, ","),
+	}, "|")
+
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// createOrder сохраняет новый заказ с позициями в базе данных
+func (s *OrderService) createOrder(orderID uuid.UUID, req *models.CreateOrderRequest) (*models.Order, error) {
 	tx, err := s.db.Begin()
 	if err != nil {
 		return nil, fmt.Errorf("failed to begin transaction: %w", err)
@@ -35,13 +223,19 @@ func (s *OrderService) CreateOrder(req *models.CreateOrderRequest) (*models.Orde
 	defer tx.Rollback()
 
 	// Расчет общей суммы заказа
-	var totalAmount float64
+	var totalAmount models.Money
+	for _, item := range req.Items {
+		totalAmount += item.Price.Mul(item.Quantity)
+	}
+
+	// Стоимость доставки рассчитывается, только если известны обе точки маршрута
+	var totalWeight int
 	for _, item := range req.Items {
-		totalAmount += item.Price * float64(item.Quantity)
+		totalWeight += item.WeightGrams * item.Quantity
 	}
+	deliveryCost, _ := s.deliveryCost(req.PickupLat, req.PickupLon, req.DeliveryLat, req.DeliveryLon, totalWeight)
 
 	// Создание заказа
-	orderID := uuid.New()
 	order := &models.Order{
 		ID:              orderID,
 		CustomerName:    req.CustomerName,
@@ -49,16 +243,29 @@ func (s *OrderService) CreateOrder(req *models.CreateOrderRequest) (*models.Orde
 		DeliveryAddress: req.DeliveryAddress,
 		TotalAmount:     totalAmount,
 		Status:          models.OrderStatusCreated,
+		PickupLat:       req.PickupLat,
+		PickupLon:       req.PickupLon,
+		DeliveryLat:     req.DeliveryLat,
+		DeliveryLon:     req.DeliveryLon,
+		DeliveryCost:    deliveryCost,
+		RequiredSkills:  req.RequiredSkills,
+		Version:         1,
 		CreatedAt:       time.Now(),
 		UpdatedAt:       time.Now(),
 	}
 
+	if order.RequiredSkills == nil {
+		order.RequiredSkills = []string{}
+	}
+
 	query := `
-		INSERT INTO orders (id, customer_name, customer_phone, delivery_address, total_amount, status, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO orders (id, customer_name, customer_phone, delivery_address, total_amount, status, created_at, updated_at,
+		                    pickup_lat, pickup_lon, delivery_lat, delivery_lon, delivery_cost, required_skills)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 	`
 	_, err = tx.Exec(query, order.ID, order.CustomerName, order.CustomerPhone,
-		order.DeliveryAddress, order.TotalAmount, order.Status, order.CreatedAt, order.UpdatedAt)
+		order.DeliveryAddress, order.TotalAmount, order.Status, order.CreatedAt, order.UpdatedAt,
+		order.PickupLat, order.PickupLon, order.DeliveryLat, order.DeliveryLon, order.DeliveryCost, pq.Array(order.RequiredSkills))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create order: %w", err)
 	}
@@ -67,24 +274,26 @@ func (s *OrderService) CreateOrder(req *models.CreateOrderRequest) (*models.Orde
 	for _, item := range req.Items {
 		itemID := uuid.New()
 		itemQuery := `
-			INSERT INTO order_items (id, order_id, name, quantity, price)
-			VALUES ($1, $2, $3, $4, $5)
+			INSERT INTO order_items (id, order_id, name, quantity, price, weight_grams, sku)
+			VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''))
 		`
-		_, err = tx.Exec(itemQuery, itemID, orderID, item.Name, item.Quantity, item.Price)
+		_, err = tx.Exec(itemQuery, itemID, orderID, item.Name, item.Quantity, item.Price, item.WeightGrams, item.SKU)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create order item: %w", err)
 		}
 
 		order.Items = append(order.Items, models.OrderItem{
-			ID:       itemID,
-			OrderID:  orderID,
-			Name:     item.Name,
-			Quantity: item.Quantity,
-			Price:    item.Price,
+			ID:          itemID,
+			OrderID:     orderID,
+			Name:        item.Name,
+			Quantity:    item.Quantity,
+			Price:       item.Price,
+			WeightGrams: item.WeightGrams,
+			SKU:         item.SKU,
 		})
 	}
 
-	if err = tx.Commit(); err != nil {
+	if err = database.Commit(tx); err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
@@ -97,37 +306,172 @@ func (s *OrderService) CreateOrder(req *models.CreateOrderRequest) (*models.Orde
 	return order, nil
 }
 
-// GetOrder получает заказ по ID
-func (s *OrderService) GetOrder(orderID uuid.UUID) (*models.Order, error) {
-	order := &models.Order{}
+// MarkOrderReady переводит заказ из accepted или preparing в ready, сохраняя назначенного курьера.
+// Возвращает заказ до изменения статуса
+func (s *OrderService) MarkOrderReady(orderID uuid.UUID) (*models.Order, error) {
+	order, err := s.GetOrder(orderID)
+	if err != nil {
+		return nil, err
+	}
 
+	if order.Status != models.OrderStatusAccepted && order.Status != models.OrderStatusPreparing {
+		return nil, newError(models.ErrorCodeInvalidTransition, "order cannot be marked ready in status %s", order.Status)
+	}
+
+	req := &models.UpdateOrderStatusRequest{
+		Status:  models.OrderStatusReady,
+		Version: &order.Version,
+	}
+	if err := s.UpdateOrderStatus(orderID, req); err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// deliveryCost рассчитывает стоимость доставки по расстоянию между точками забора и доставки.
+// Возвращает false, если координаты одной из точек неизвестны
+func (s *OrderService) deliveryCost(pickupLat, pickupLon, deliveryLat, deliveryLon *float64, weightGrams int) (*models.Money, bool) {
+	if pickupLat == nil || pickupLon == nil || deliveryLat == nil || deliveryLon == nil {
+		return nil, false
+	}
+
+	cost := s.pricing.CalculateDeliveryCost(DeliveryCostInput{
+		DistanceKm:  geo.DistanceKm(*pickupLat, *pickupLon, *deliveryLat, *deliveryLon),
+		WeightGrams: weightGrams,
+	})
+	return &cost, true
+}
+
+// RecalculateDeliveryCost пересчитывает стоимость доставки заказа по текущим тарифам.
+// Доставленные и отмененные заказы, а также заказы со стоимостью, установленной вручную, не пересчитываются.
+// Возвращает стоимость до и после пересчета
+func (s *OrderService) RecalculateDeliveryCost(orderID uuid.UUID) (oldCost *models.Money, newCost models.Money, err error) {
+	err = s.db.Retry(func() error {
+		oldCost, newCost, err = s.recalculateDeliveryCost(orderID)
+		return err
+	})
+	return oldCost, newCost, err
+}
+
+// recalculateDeliveryCost выполняет RecalculateDeliveryCost в одной транзакции
+func (s *OrderService) recalculateDeliveryCost(orderID uuid.UUID) (*models.Money, models.Money, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Блокируем заказ до конца транзакции
+	var status models.OrderStatus
+	var pickupLat, pickupLon, deliveryLat, deliveryLon *float64
+	var oldCost *models.Money
+	var overridden bool
 	query := `
-		SELECT id, customer_name, customer_phone, delivery_address, total_amount, 
-		       status, courier_id, created_at, updated_at, delivered_at
-		FROM orders 
-		WHERE id = $1
+		SELECT status, pickup_lat, pickup_lon, delivery_lat, delivery_lon, delivery_cost, delivery_cost_overridden
+		FROM orders WHERE id = $1 FOR UPDATE
 	`
+	err = tx.QueryRow(query, orderID).Scan(&status, &pickupLat, &pickupLon, &deliveryLat, &deliveryLon, &oldCost, &overridden)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, 0, newError(models.ErrorCodeOrderNotFound, "order not found")
+		}
+		return nil, 0, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	if status == models.OrderStatusDelivered || status == models.OrderStatusCancelled {
+		return nil, 0, newError(models.ErrorCodeInvalidTransition, "delivery cost cannot be recalculated in status %s", status)
+	}
+	if overridden {
+		return nil, 0, newError(models.ErrorCodeDeliveryCostOverridden, "delivery cost was set manually")
+	}
 
-	err := s.db.QueryRow(query, orderID).Scan(
+	newCost, ok, err := s.orderDeliveryCost(tx, orderID, pickupLat, pickupLon, deliveryLat, deliveryLon)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !ok {
+		return nil, 0, newError(models.ErrorCodeDeliveryRouteUnknown, "order pickup or delivery location is unknown")
+	}
+
+	updateQuery := "UPDATE orders SET delivery_cost = $1, updated_at = $2, version = version + 1 WHERE id = $3"
+	if _, err = tx.Exec(updateQuery, *newCost, time.Now(), orderID); err != nil {
+		return nil, 0, fmt.Errorf("failed to update delivery cost: %w", err)
+	}
+
+	if err = database.Commit(tx); err != nil {
+		return nil, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.log.WithFields(map[string]interface{}{
+		"order_id":      orderID,
+		"old_cost":      oldCost,
+		"delivery_cost": *newCost,
+	}).Info("Order delivery cost recalculated")
+
+	return oldCost, *newCost, nil
+}
+
+// orderDeliveryCost рассчитывает стоимость доставки заказа по текущему весу его позиций в рамках транзакции.
+// Возвращает false, если координаты одной из точек маршрута неизвестны
+func (s *OrderService) orderDeliveryCost(tx *sql.Tx, orderID uuid.UUID, pickupLat, pickupLon, deliveryLat, deliveryLon *float64) (*models.Money, bool, error) {
+	var weightGrams int
+	err := tx.QueryRow("SELECT COALESCE(SUM(weight_grams * quantity), 0) FROM order_items WHERE order_id = $1", orderID).Scan(&weightGrams)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get order weight: %w", err)
+	}
+
+	cost, ok := s.deliveryCost(pickupLat, pickupLon, deliveryLat, deliveryLon, weightGrams)
+	return cost, ok, nil
+}
+
+// orderColumns список колонок заказа в порядке, ожидаемом scanOrder
+const orderColumns = `id, customer_name, customer_phone, delivery_address, total_amount,
+	status, priority, courier_id, created_at, updated_at, delivered_at,
+	cancellation_reason, cancellation_comment, pickup_lat, pickup_lon, delivery_lat, delivery_lon, version, sla_breached_at,
+	delivery_cost, delivery_cost_overridden, required_skills`
+
+// rowScanner представляет *sql.Row или *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanOrder считывает заказ из строки, выбранной с колонками orderColumns
+func scanOrder(row rowScanner) (*models.Order, error) {
+	order := &models.Order{}
+	err := row.Scan(
 		&order.ID, &order.CustomerName, &order.CustomerPhone, &order.DeliveryAddress,
-		&order.TotalAmount, &order.Status, &order.CourierID, &order.CreatedAt,
-		&order.UpdatedAt, &order.DeliveredAt,
+		&order.TotalAmount, &order.Status, &order.Priority, &order.CourierID, &order.CreatedAt,
+		&order.UpdatedAt, &order.DeliveredAt, &order.CancellationReason, &order.CancellationComment,
+		&order.PickupLat, &order.PickupLon, &order.DeliveryLat, &order.DeliveryLon, &order.Version, &order.SLABreachedAt,
+		&order.DeliveryCost, &order.DeliveryCostOverridden, pq.Array(&order.RequiredSkills),
 	)
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// GetOrder получает заказ по ID
+func (s *OrderService) GetOrder(orderID uuid.UUID) (*models.Order, error) {
+	query := "SELECT " + orderColumns + " FROM orders WHERE id = $1"
+
+	order, err := scanOrder(s.db.QueryRowWithRetry(query, orderID))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("order not found")
+			return nil, newError(models.ErrorCodeOrderNotFound, "order not found")
 		}
 		return nil, fmt.Errorf("failed to get order: %w", err)
 	}
 
 	// Получение товаров заказа
 	itemsQuery := `
-		SELECT id, order_id, name, quantity, price
+		SELECT id, order_id, name, quantity, price, weight_grams, COALESCE(sku, '')
 		FROM order_items
 		WHERE order_id = $1
 	`
 
-	rows, err := s.db.Query(itemsQuery, orderID)
+	rows, err := s.db.QueryWithRetry(itemsQuery, orderID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get order items: %w", err)
 	}
@@ -135,32 +479,407 @@ func (s *OrderService) GetOrder(orderID uuid.UUID) (*models.Order, error) {
 
 	for rows.Next() {
 		var item models.OrderItem
-		if err := rows.Scan(&item.ID, &item.OrderID, &item.Name, &item.Quantity, &item.Price); err != nil {
+		if err := rows.Scan(&item.ID, &item.OrderID, &item.Name, &item.Quantity, &item.Price, &item.WeightGrams, &item.SKU); err != nil {
 			return nil, fmt.Errorf("failed to scan order item: %w", err)
 		}
 		order.Items = append(order.Items, item)
 	}
 
+	proof, err := s.getDeliveryProof(orderID)
+	if err != nil {
+		return nil, err
+	}
+	order.Proof = proof
+
 	return order, nil
 }
 
+// maxTimelineLocations ограничивает количество местоположений курьера в хронологии заказа
+const maxTimelineLocations = 500
+
+// courierAssignment представляет период, в течение которого заказ был назначен курьеру.
+// until равен nil, пока период не закончился
+type courierAssignment struct {
+	courierID uuid.UUID
+	since     time.Time
+	until     *time.Time
+}
+
+// GetOrderTimeline собирает хронологию заказа из истории статусов, подтверждения доставки и истории
+// местоположений курьеров. Назначение и снятие курьера определяются по изменению courier_id в истории статусов,
+// местоположения берутся только за периоды, когда заказ был назначен курьеру
+func (s *OrderService) GetOrderTimeline(orderID uuid.UUID) (*models.OrderTimeline, error) {
+	var createdAt time.Time
+	var slaBreachedAt *time.Time
+	err := s.db.QueryRowWithRetry("SELECT created_at, sla_breached_at FROM orders WHERE id = $1", orderID).
+		Scan(&createdAt, &slaBreachedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, newError(models.ErrorCodeOrderNotFound, "order not found")
+		}
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	created := models.OrderStatusCreated
+	timeline := &models.OrderTimeline{
+		OrderID: orderID,
+		Events:  []*models.OrderTimelineEvent{{Type: models.TimelineEventCreated, At: createdAt, Status: &created}},
+	}
+	if slaBreachedAt != nil {
+		timeline.Events = append(timeline.Events, &models.OrderTimelineEvent{Type: models.TimelineEventSLABreached, At: *slaBreachedAt})
+	}
+
+	assignments, err := s.appendStatusHistory(timeline, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, assignment := range assignments {
+		if err := s.appendCourierLocations(timeline, assignment); err != nil {
+			return nil, err
+		}
+	}
+
+	proof, err := s.getDeliveryProof(orderID)
+	if err != nil {
+		return nil, err
+	}
+	if proof != nil {
+		timeline.Events = append(timeline.Events, &models.OrderTimelineEvent{
+			Type:      models.TimelineEventDeliveryProof,
+			At:        proof.CreatedAt,
+			CourierID: proof.CourierID,
+		})
+	}
+
+	// Устойчивая сортировка сохраняет порядок событий с одинаковым временем, например смены статуса и назначения курьера
+	sort.SliceStable(timeline.Events, func(i, j int) bool {
+		return timeline.Events[i].At.Before(timeline.Events[j].At)
+	})
+
+	return timeline, nil
+}
+
+// appendStatusHistory добавляет в хронологию смены статусов заказа и изменения назначенного курьера.
+// Возвращает периоды назначения заказа курьерам
+func (s *OrderService) appendStatusHistory(timeline *models.OrderTimeline, orderID uuid.UUID) ([]*courierAssignment, error) {
+	query := `
+		SELECT old_status, new_status, courier_id, changed_at
+		FROM order_status_history
+		WHERE order_id = $1
+		ORDER BY changed_at
+	`
+
+	rows, err := s.db.QueryWithRetry(query, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order status history: %w", err)
+	}
+	defer rows.Close()
+
+	var assignments []*courierAssignment
+	var current *courierAssignment
+	for rows.Next() {
+		var oldStatus *models.OrderStatus
+		var newStatus models.OrderStatus
+		var courierID *uuid.UUID
+		var changedAt time.Time
+		if err := rows.Scan(&oldStatus, &newStatus, &courierID, &changedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan order status history: %w", err)
+		}
+
+		timeline.Events = append(timeline.Events, &models.OrderTimelineEvent{
+			Type:      models.TimelineEventStatusChanged,
+			At:        changedAt,
+			OldStatus: oldStatus,
+			Status:    &newStatus,
+			CourierID: courierID,
+		})
+
+		// Курьер сменился: закрываем текущий период назначения и, если назначен новый курьер, открываем следующий
+		if current != nil && (courierID == nil || *courierID != current.courierID) {
+			current.until = &changedAt
+			timeline.Events = append(timeline.Events, &models.OrderTimelineEvent{
+				Type:      models.TimelineEventCourierUnassigned,
+				At:        changedAt,
+				CourierID: &current.courierID,
+			})
+			current = nil
+		}
+		if current == nil && courierID != nil {
+			current = &courierAssignment{courierID: *courierID, since: changedAt}
+			assignments = append(assignments, current)
+			timeline.Events = append(timeline.Events, &models.OrderTimelineEvent{
+				Type:      models.TimelineEventCourierAssigned,
+				At:        changedAt,
+				CourierID: courierID,
+			})
+		}
+
+		// После доставки или отмены местоположение курьера к заказу уже не относится
+		if current != nil && (newStatus == models.OrderStatusDelivered || newStatus == models.OrderStatusCancelled) {
+			current.until = &changedAt
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate order status history: %w", err)
+	}
+
+	return assignments, nil
+}
+
+// appendCourierLocations добавляет в хронологию местоположения курьера за период назначения,
+// пока их общее количество не достигнет maxTimelineLocations
+func (s *OrderService) appendCourierLocations(timeline *models.OrderTimeline, assignment *courierAssignment) error {
+	remaining := maxTimelineLocations
+	for _, event := range timeline.Events {
+		if event.Type == models.TimelineEventCourierLocation {
+			remaining--
+		}
+	}
+	if remaining <= 0 {
+		timeline.LocationsTruncated = true
+		return nil
+	}
+
+	query := `
+		SELECT lat, lon, recorded_at
+		FROM courier_locations
+		WHERE courier_id = $1 AND recorded_at >= $2 AND ($3::timestamptz IS NULL OR recorded_at <= $3)
+		ORDER BY recorded_at
+		LIMIT $4
+	`
+
+	// Запрашиваем на одну точку больше, чтобы узнать, что часть местоположений не поместилась
+	rows, err := s.db.QueryWithRetry(query, assignment.courierID, assignment.since, assignment.until, remaining+1)
+	if err != nil {
+		return fmt.Errorf("failed to get courier locations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if remaining == 0 {
+			timeline.LocationsTruncated = true
+			break
+		}
+
+		var lat, lon float64
+		var recordedAt time.Time
+		if err := rows.Scan(&lat, &lon, &recordedAt); err != nil {
+			return fmt.Errorf("failed to scan courier location: %w", err)
+		}
+		timeline.Events = append(timeline.Events, &models.OrderTimelineEvent{
+			Type:      models.TimelineEventCourierLocation,
+			At:        recordedAt,
+			CourierID: &assignment.courierID,
+			Lat:       &lat,
+			Lon:       &lon,
+		})
+		remaining--
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate courier locations: %w", err)
+	}
+
+	return nil
+}
+
+// getDeliveryProof получает подтверждение доставки заказа, nil если его нет
+func (s *OrderService) getDeliveryProof(orderID uuid.UUID) (*models.DeliveryProof, error) {
+	query := `
+		SELECT id, order_id, courier_id, photo_ref, signature_ref, created_at
+		FROM delivery_proofs
+		WHERE order_id = $1
+	`
+
+	proof := &models.DeliveryProof{}
+	err := s.db.QueryRowWithRetry(query, orderID).Scan(&proof.ID, &proof.OrderID, &proof.CourierID,
+		&proof.PhotoRef, &proof.SignatureRef, &proof.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get delivery proof: %w", err)
+	}
+
+	return proof, nil
+}
+
+// SubmitDeliveryProof сохраняет подтверждение доставки и переводит заказ из in_delivery в delivered.
+// Возвращает заказ до изменения статуса и сохраненное подтверждение
+func (s *OrderService) SubmitDeliveryProof(orderID uuid.UUID, req *models.SubmitDeliveryProofRequest) (order *models.Order, proof *models.DeliveryProof, err error) {
+	err = s.db.Retry(func() error {
+		order, proof, err = s.submitDeliveryProof(orderID, req)
+		return err
+	})
+	return order, proof, err
+}
+
+// submitDeliveryProof выполняет SubmitDeliveryProof в одной транзакции
+func (s *OrderService) submitDeliveryProof(orderID uuid.UUID, req *models.SubmitDeliveryProofRequest) (*models.Order, *models.DeliveryProof, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Блокируем заказ до конца транзакции
+	order, err := scanOrder(tx.QueryRow("SELECT "+orderColumns+" FROM orders WHERE id = $1 FOR UPDATE", orderID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil, newError(models.ErrorCodeOrderNotFound, "order not found")
+		}
+		return nil, nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	if order.Status != models.OrderStatusInDelivery {
+		return nil, nil, newError(models.ErrorCodeInvalidTransition, "delivery proof cannot be submitted in status %s", order.Status)
+	}
+
+	now := time.Now()
+	proof := &models.DeliveryProof{
+		ID:           uuid.New(),
+		OrderID:      orderID,
+		CourierID:    order.CourierID,
+		PhotoRef:     req.PhotoRef,
+		SignatureRef: req.SignatureRef,
+		CreatedAt:    now,
+	}
+
+	proofQuery := `
+		INSERT INTO delivery_proofs (id, order_id, courier_id, photo_ref, signature_ref, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	_, err = tx.Exec(proofQuery, proof.ID, proof.OrderID, proof.CourierID, proof.PhotoRef, proof.SignatureRef, proof.CreatedAt)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to save delivery proof: %w", err)
+	}
+
+	orderQuery := `
+		UPDATE orders
+		SET status = $1, delivered_at = $2, updated_at = $2, version = version + 1
+		WHERE id = $3
+	`
+	if _, err = tx.Exec(orderQuery, models.OrderStatusDelivered, now, orderID); err != nil {
+		return nil, nil, fmt.Errorf("failed to update order status: %w", err)
+	}
+
+	if err = database.Commit(tx); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.log.WithFields(map[string]interface{}{
+		"order_id":   orderID,
+		"courier_id": order.CourierID,
+		"proof_id":   proof.ID,
+	}).Info("Delivery proof submitted")
+
+	return order, proof, nil
+}
+
+// GetOrdersByIDs получает заказы вместе с позициями по списку ID двумя запросами.
+// Отсутствующие заказы пропускаются, порядок результата не гарантируется
+func (s *OrderService) GetOrdersByIDs(orderIDs []uuid.UUID) ([]*models.Order, error) {
+	if len(orderIDs) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]string, len(orderIDs))
+	for i, id := range orderIDs {
+		ids[i] = id.String()
+	}
+
+	query := "SELECT " + orderColumns + " FROM orders WHERE id = ANY($1::uuid[])"
+
+	rows, err := s.db.QueryWithRetry(query, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get orders: %w", err)
+	}
+	defer rows.Close()
+
+	var orders []*models.Order
+	byID := make(map[uuid.UUID]*models.Order, len(orderIDs))
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order: %w", err)
+		}
+		orders = append(orders, order)
+		byID[order.ID] = order
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get orders: %w", err)
+	}
+
+	// Получение товаров всех найденных заказов одним запросом
+	itemsQuery := `
+		SELECT id, order_id, name, quantity, price, weight_grams, COALESCE(sku, '')
+		FROM order_items
+		WHERE order_id = ANY($1::uuid[])
+	`
+
+	itemRows, err := s.db.QueryWithRetry(itemsQuery, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order items: %w", err)
+	}
+	defer itemRows.Close()
+
+	for itemRows.Next() {
+		var item models.OrderItem
+		if err := itemRows.Scan(&item.ID, &item.OrderID, &item.Name, &item.Quantity, &item.Price, &item.WeightGrams, &item.SKU); err != nil {
+			return nil, fmt.Errorf("failed to scan order item: %w", err)
+		}
+		if order, ok := byID[item.OrderID]; ok {
+			order.Items = append(order.Items, item)
+		}
+	}
+
+	return orders, nil
+}
+
 // UpdateOrderStatus обновляет статус заказа
 func (s *OrderService) UpdateOrderStatus(orderID uuid.UUID, req *models.UpdateOrderStatusRequest) error {
 	query := `
 		UPDATE orders 
-		SET status = $1, courier_id = $2, updated_at = $3
+		SET status = $1, updated_at = $2, version = version + 1
 	`
-	args := []interface{}{req.Status, req.CourierID, time.Now()}
+	args := []interface{}{req.Status, time.Now()}
+	argIndex := 3
+
+	// Курьер меняется только если передан явно, иначе текущее назначение сохраняется
+	if req.CourierID != nil {
+		query += fmt.Sprintf(", courier_id = $%d", argIndex)
+		args = append(args, req.CourierID)
+		argIndex++
+	}
 
 	// Если статус "доставлен", устанавливаем время доставки
 	if req.Status == models.OrderStatusDelivered {
-		query += ", delivered_at = $4"
+		query += fmt.Sprintf(", delivered_at = $%d", argIndex)
 		args = append(args, time.Now())
-		query += " WHERE id = $5"
-		args = append(args, orderID)
-	} else {
-		query += " WHERE id = $4"
-		args = append(args, orderID)
+		argIndex++
+	}
+
+	// Если заказ отменяется, сохраняем причину отмены
+	if req.Status == models.OrderStatusCancelled {
+		var comment *string
+		if req.ReasonComment != "" {
+			comment = &req.ReasonComment
+		}
+		query += fmt.Sprintf(", cancellation_reason = $%d, cancellation_comment = $%d", argIndex, argIndex+1)
+		args = append(args, req.Reason, comment)
+		argIndex += 2
+	}
+
+	query += fmt.Sprintf(" WHERE id = $%d", argIndex)
+	args = append(args, orderID)
+	argIndex++
+
+	// Оптимистичная блокировка: обновляем только ожидаемую клиентом версию
+	if req.Version != nil {
+		query += fmt.Sprintf(" AND version = $%d", argIndex)
+		args = append(args, *req.Version)
 	}
 
 	result, err := s.db.Exec(query, args...)
@@ -174,7 +893,16 @@ func (s *OrderService) UpdateOrderStatus(orderID uuid.UUID, req *models.UpdateOr
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("order not found")
+		if req.Version != nil {
+			var exists bool
+			if err := s.db.QueryRowWithRetry("SELECT EXISTS(SELECT 1 FROM orders WHERE id = $1)", orderID).Scan(&exists); err != nil {
+				return fmt.Errorf("failed to check order: %w", err)
+			}
+			if exists {
+				return newError(models.ErrorCodeOrderVersionMismatch, "order version mismatch: order was modified concurrently")
+			}
+		}
+		return newError(models.ErrorCodeOrderNotFound, "order not found")
 	}
 
 	s.log.WithFields(map[string]interface{}{
@@ -186,30 +914,471 @@ func (s *OrderService) UpdateOrderStatus(orderID uuid.UUID, req *models.UpdateOr
 	return nil
 }
 
-// GetOrders получает список заказов с фильтрацией
-func (s *OrderService) GetOrders(status *models.OrderStatus, courierID *uuid.UUID, limit, offset int) ([]*models.Order, error) {
+// BulkUpdateOrderStatus переводит заказы в статус req.Status в одной транзакции. Заказы блокируются
+// на время проверки; если хотя бы один заказ не найден или не может перейти в статус, ни один заказ
+// не изменяется. Возвращает результат по каждому заказу в порядке orderIDs и признак применения переходов
+func (s *OrderService) BulkUpdateOrderStatus(orderIDs []uuid.UUID, req *models.UpdateOrderStatusRequest) (results []models.BulkOrderStatusResult, applied bool, err error) {
+	err = s.db.Retry(func() error {
+		results, applied, err = s.bulkUpdateOrderStatus(orderIDs, req)
+		return err
+	})
+	return results, applied, err
+}
+
+// bulkUpdateOrderStatus выполняет BulkUpdateOrderStatus в одной транзакции
+func (s *OrderService) bulkUpdateOrderStatus(orderIDs []uuid.UUID, req *models.UpdateOrderStatusRequest) ([]models.BulkOrderStatusResult, bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	ids := make([]string, len(orderIDs))
+	for i, id := range orderIDs {
+		ids[i] = id.String()
+	}
+
+	// Строки блокируются в порядке id, чтобы параллельные массовые изменения не взаимоблокировались
+	rows, err := tx.Query("SELECT id, status, courier_id FROM orders WHERE id = ANY($1::uuid[]) ORDER BY id FOR UPDATE", pq.Array(ids))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to lock orders: %w", err)
+	}
+
+	type lockedOrder struct {
+		status    models.OrderStatus
+		courierID *uuid.UUID
+	}
+	locked := make(map[uuid.UUID]lockedOrder, len(orderIDs))
+	for rows.Next() {
+		var id uuid.UUID
+		var order lockedOrder
+		if err := rows.Scan(&id, &order.status, &order.courierID); err != nil {
+			rows.Close()
+			return nil, false, fmt.Errorf("failed to scan order: %w", err)
+		}
+		locked[id] = order
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, false, fmt.Errorf("failed to read orders: %w", err)
+	}
+
+	results := make([]models.BulkOrderStatusResult, len(orderIDs))
+	applicable := true
+	for i, id := range orderIDs {
+		result := models.BulkOrderStatusResult{OrderID: id}
+		order, ok := locked[id]
+		switch {
+		case !ok:
+			result.Code = models.ErrorCodeOrderNotFound
+			result.Message = "order not found"
+		case !order.status.CanTransitionTo(req.Status):
+			result.OldStatus = order.status
+			result.CourierID = order.courierID
+			result.Code = models.ErrorCodeInvalidTransition
+			result.Message = fmt.Sprintf("order cannot change status from %s to %s", order.status, req.Status)
+		default:
+			result.OldStatus = order.status
+			result.CourierID = order.courierID
+		}
+		if result.Code != "" {
+			applicable = false
+		}
+		results[i] = result
+	}
+	if !applicable {
+		return results, false, nil
+	}
+
+	now := time.Now()
+	query := "UPDATE orders SET status = $1, updated_at = $2, version = version + 1"
+	args := []interface{}{req.Status, now}
+
+	if req.Status == models.OrderStatusDelivered {
+		query += ", delivered_at = $3"
+		args = append(args, now)
+	}
+	if req.Status == models.OrderStatusCancelled {
+		var comment *string
+		if req.ReasonComment != "" {
+			comment = &req.ReasonComment
+		}
+		query += fmt.Sprintf(", cancellation_reason = $%d, cancellation_comment = $%d", len(args)+1, len(args)+2)
+		args = append(args, req.Reason, comment)
+	}
+
+	query += fmt.Sprintf(" WHERE id = ANY($%d::uuid[])", len(args)+1)
+	args = append(args, pq.Array(ids))
+
+	if _, err := tx.Exec(query, args...); err != nil {
+		return nil, false, fmt.Errorf("failed to update order statuses: %w", err)
+	}
+
+	if err := database.Commit(tx); err != nil {
+		return nil, false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.log.WithFields(map[string]interface{}{
+		"orders":     len(orderIDs),
+		"new_status": req.Status,
+	}).Info("Order statuses updated in bulk")
+
+	return results, true, nil
+}
+
+// UnassignOrder снимает курьера с заказа и возвращает заказ в статус "создан".
+// Курьер освобождается, если у него не осталось других активных заказов.
+// Возвращает предыдущий статус заказа и ID снятого курьера.
+func (s *OrderService) UnassignOrder(orderID uuid.UUID) (oldStatus models.OrderStatus, courierID uuid.UUID, err error) {
+	err = s.db.Retry(func() error {
+		oldStatus, courierID, err = s.unassignOrder(orderID)
+		return err
+	})
+	return oldStatus, courierID, err
+}
+
+// unassignOrder выполняет UnassignOrder в одной транзакции
+func (s *OrderService) unassignOrder(orderID uuid.UUID) (models.OrderStatus, uuid.UUID, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", uuid.Nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Блокируем заказ до конца транзакции
+	var oldStatus models.OrderStatus
+	var courierID *uuid.UUID
+	err = tx.QueryRow("SELECT status, courier_id FROM orders WHERE id = $1 FOR UPDATE", orderID).Scan(&oldStatus, &courierID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", uuid.Nil, newError(models.ErrorCodeOrderNotFound, "order not found")
+		}
+		return "", uuid.Nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	if courierID == nil {
+		return "", uuid.Nil, newError(models.ErrorCodeOrderNotAssigned, "order is not assigned to a courier")
+	}
+
+	switch oldStatus {
+	case models.OrderStatusAccepted, models.OrderStatusPreparing, models.OrderStatusReady:
+	default:
+		return "", uuid.Nil, newError(models.ErrorCodeInvalidTransition, "order cannot be unassigned in status %s", oldStatus)
+	}
+
+	// Возвращаем заказ в пул
+	orderQuery := `
+		UPDATE orders
+		SET courier_id = NULL, status = $1, updated_at = $2, version = version + 1
+		WHERE id = $3
+	`
+	if _, err = tx.Exec(orderQuery, models.OrderStatusCreated, time.Now(), orderID); err != nil {
+		return "", uuid.Nil, fmt.Errorf("failed to unassign order: %w", err)
+	}
+
+	// Проверяем, остались ли у курьера другие активные заказы
+	var activeOrders int
+	activeQuery := `
+		SELECT COUNT(*) FROM orders
+		WHERE courier_id = $1 AND status IN ($2, $3, $4, $5)
+	`
+	err = tx.QueryRow(activeQuery, *courierID, models.OrderStatusAccepted, models.OrderStatusPreparing,
+		models.OrderStatusReady, models.OrderStatusInDelivery).Scan(&activeOrders)
+	if err != nil {
+		return "", uuid.Nil, fmt.Errorf("failed to count courier active orders: %w", err)
+	}
+
+	// Освобождаем курьера, если он больше ничем не занят
+	if activeOrders == 0 {
+		courierQuery := `
+			UPDATE couriers
+			SET status = $1, updated_at = $2
+			WHERE id = $3 AND status = $4
+		`
+		_, err = tx.Exec(courierQuery, models.CourierStatusAvailable, time.Now(), *courierID, models.CourierStatusBusy)
+		if err != nil {
+			return "", uuid.Nil, fmt.Errorf("failed to update courier status: %w", err)
+		}
+	}
+
+	if err = database.Commit(tx); err != nil {
+		return "", uuid.Nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.log.WithFields(map[string]interface{}{
+		"order_id":   orderID,
+		"courier_id": *courierID,
+		"old_status": oldStatus,
+	}).Info("Order unassigned from courier")
+
+	return oldStatus, *courierID, nil
+}
+
+// RemoveOrderItem удаляет позицию из заказа и пересчитывает его сумму.
+// Удаление возможно, пока заказ не передан в доставку, и не может затронуть последнюю позицию.
+// Возвращает сумму заказа до и после удаления.
+func (s *OrderService) RemoveOrderItem(orderID, itemID uuid.UUID) (oldTotal models.Money, newTotal models.Money, err error) {
+	err = s.db.Retry(func() error {
+		oldTotal, newTotal, err = s.removeOrderItem(orderID, itemID)
+		return err
+	})
+	return oldTotal, newTotal, err
+}
+
+// removeOrderItem выполняет RemoveOrderItem в одной транзакции
+func (s *OrderService) removeOrderItem(orderID, itemID uuid.UUID) (models.Money, models.Money, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Блокируем заказ до конца транзакции
+	var status models.OrderStatus
+	var oldAmount models.Money
+	err = tx.QueryRow("SELECT status, total_amount FROM orders WHERE id = $1 FOR UPDATE", orderID).Scan(&status, &oldAmount)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, 0, newError(models.ErrorCodeOrderNotFound, "order not found")
+		}
+		return 0, 0, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	if !orderItemsEditable(status) {
+		return 0, 0, newError(models.ErrorCodeInvalidTransition, "order items cannot be modified in status %s", status)
+	}
+
+	var itemsCount int
+	if err = tx.QueryRow("SELECT COUNT(*) FROM order_items WHERE order_id = $1", orderID).Scan(&itemsCount); err != nil {
+		return 0, 0, fmt.Errorf("failed to count order items: %w", err)
+	}
+
+	result, err := tx.Exec("DELETE FROM order_items WHERE id = $1 AND order_id = $2", itemID, orderID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to delete order item: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return 0, 0, newError(models.ErrorCodeOrderItemNotFound, "order item not found")
+	}
+
+	if itemsCount <= 1 {
+		return 0, 0, newError(models.ErrorCodeOrderLastItem, "cannot remove the last item of an order")
+	}
+
+	// Пересчет суммы заказа
+	newAmount, err := recalculateOrderTotal(tx, orderID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err = database.Commit(tx); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.log.WithFields(map[string]interface{}{
+		"order_id":   orderID,
+		"item_id":    itemID,
+		"old_amount": oldAmount,
+		"new_amount": newAmount,
+	}).Info("Order item removed")
+
+	return oldAmount, newAmount, nil
+}
+
+// UpdateOrderItem меняет количество и (или) цену позиции заказа и пересчитывает его сумму.
+// При изменении количества стоимость доставки пересчитывается по новому весу заказа,
+// если она не задана вручную. Изменение возможно, пока заказ не передан в доставку.
+// Возвращает измененную позицию и сумму заказа до и после изменения
+func (s *OrderService) UpdateOrderItem(orderID, itemID uuid.UUID, req *models.UpdateOrderItemRequest) (item *models.OrderItem, oldTotal models.Money, newTotal models.Money, err error) {
+	err = s.db.Retry(func() error {
+		item, oldTotal, newTotal, err = s.updateOrderItem(orderID, itemID, req)
+		return err
+	})
+	return item, oldTotal, newTotal, err
+}
+
+// updateOrderItem выполняет UpdateOrderItem в одной транзакции
+func (s *OrderService) updateOrderItem(orderID, itemID uuid.UUID, req *models.UpdateOrderItemRequest) (*models.OrderItem, models.Money, models.Money, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Блокируем заказ до конца транзакции
+	var status models.OrderStatus
+	var oldAmount models.Money
+	var pickupLat, pickupLon, deliveryLat, deliveryLon *float64
+	var costOverridden bool
 	query := `
-		SELECT id, customer_name, customer_phone, delivery_address, total_amount, 
-		       status, courier_id, created_at, updated_at, delivered_at
-		FROM orders 
-		WHERE 1=1
+		SELECT status, total_amount, pickup_lat, pickup_lon, delivery_lat, delivery_lon, delivery_cost_overridden
+		FROM orders WHERE id = $1 FOR UPDATE
 	`
+	err = tx.QueryRow(query, orderID).Scan(&status, &oldAmount, &pickupLat, &pickupLon, &deliveryLat, &deliveryLon, &costOverridden)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, 0, 0, newError(models.ErrorCodeOrderNotFound, "order not found")
+		}
+		return nil, 0, 0, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	if !orderItemsEditable(status) {
+		return nil, 0, 0, newError(models.ErrorCodeInvalidTransition, "order items cannot be modified in status %s", status)
+	}
+
+	// Цена позиции из каталога берется из него, поэтому клиент не может ее изменить
+	if req.Price != nil {
+		var sku string
+		err = tx.QueryRow("SELECT COALESCE(sku, '') FROM order_items WHERE id = $1 AND order_id = $2", itemID, orderID).Scan(&sku)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil, 0, 0, newError(models.ErrorCodeOrderItemNotFound, "order item not found")
+			}
+			return nil, 0, 0, fmt.Errorf("failed to get order item: %w", err)
+		}
+		if sku != "" {
+			return nil, 0, 0, newError(models.ErrorCodeOrderItemPriceFixed, "price of catalog item %s cannot be changed", sku)
+		}
+	}
+
+	item := &models.OrderItem{}
+	itemQuery := `
+		UPDATE order_items
+		SET quantity = COALESCE($1, quantity), price = COALESCE($2, price)
+		WHERE id = $3 AND order_id = $4
+		RETURNING id, order_id, name, quantity, price, weight_grams, COALESCE(sku, '')
+	`
+	err = tx.QueryRow(itemQuery, req.Quantity, req.Price, itemID, orderID).
+		Scan(&item.ID, &item.OrderID, &item.Name, &item.Quantity, &item.Price, &item.WeightGrams, &item.SKU)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, 0, 0, newError(models.ErrorCodeOrderItemNotFound, "order item not found")
+		}
+		return nil, 0, 0, fmt.Errorf("failed to update order item: %w", err)
+	}
+
+	// Количество меняет вес заказа, поэтому стоимость доставки пересчитывается в той же транзакции.
+	// Стоимость, установленная вручную, и заказы без известного маршрута не пересчитываются
+	if req.Quantity != nil && !costOverridden {
+		cost, ok, err := s.orderDeliveryCost(tx, orderID, pickupLat, pickupLon, deliveryLat, deliveryLon)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		if ok {
+			if _, err = tx.Exec("UPDATE orders SET delivery_cost = $1 WHERE id = $2", *cost, orderID); err != nil {
+				return nil, 0, 0, fmt.Errorf("failed to update delivery cost: %w", err)
+			}
+		}
+	}
+
+	newAmount, err := recalculateOrderTotal(tx, orderID)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	if err = database.Commit(tx); err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.log.WithFields(map[string]interface{}{
+		"order_id":   orderID,
+		"item_id":    itemID,
+		"old_amount": oldAmount,
+		"new_amount": newAmount,
+	}).Info("Order item updated")
+
+	return item, oldAmount, newAmount, nil
+}
+
+// orderItemsEditable сообщает, можно ли менять состав заказа в статусе status
+func orderItemsEditable(status models.OrderStatus) bool {
+	switch status {
+	case models.OrderStatusCreated, models.OrderStatusAccepted, models.OrderStatusPreparing, models.OrderStatusReady:
+		return true
+	}
+	return false
+}
+
+// recalculateOrderTotal пересчитывает сумму заказа по его позициям в рамках транзакции
+func recalculateOrderTotal(tx *sql.Tx, orderID uuid.UUID) (models.Money, error) {
+	var amount models.Money
+	amountQuery := `
+		UPDATE orders
+		SET total_amount = (SELECT COALESCE(SUM(price * quantity), 0) FROM order_items WHERE order_id = $1),
+		    updated_at = $2, version = version + 1
+		WHERE id = $1
+		RETURNING total_amount
+	`
+	if err := tx.QueryRow(amountQuery, orderID, time.Now()).Scan(&amount); err != nil {
+		return 0, fmt.Errorf("failed to recalculate order total: %w", err)
+	}
+	return amount, nil
+}
+
+// GetOrders получает список заказов, удовлетворяющих фильтру. Непустой Search ограничивает выборку
+// заказами, у которых имя клиента, телефон или адрес содержат строку поиска
+func (s *OrderService) GetOrders(filter *models.OrderFilter, limit, offset int) ([]*models.Order, error) {
+	query := "SELECT " + orderColumns + " FROM orders WHERE 1=1"
 	args := []interface{}{}
 	argIndex := 1
+	orderBy := " ORDER BY created_at DESC"
+
+	// Поиск по имени клиента, телефону и адресу: подстрока без учета регистра
+	// или совпадение слов, результаты упорядочиваются по релевантности
+	if filter.Search != "" {
+		document := "to_tsvector('simple', customer_name || ' ' || delivery_address)"
+		tsQuery := fmt.Sprintf("plainto_tsquery('simple', $%d)", argIndex)
+		query += fmt.Sprintf(` AND (customer_name ILIKE $%[1]d OR customer_phone ILIKE $%[1]d
+			OR delivery_address ILIKE $%[1]d OR %[2]s @@ %[3]s)`, argIndex+1, document, tsQuery)
+		orderBy = fmt.Sprintf(` ORDER BY ts_rank(%[1]s, %[2]s) DESC,
+			GREATEST(similarity(customer_name, $%[3]d), similarity(delivery_address, $%[3]d)) DESC, created_at DESC`,
+			document, tsQuery, argIndex)
+		args = append(args, filter.Search, "%"+escapeLikePattern(filter.Search)+"%")
+		argIndex += 2
+	}
 
-	if status != nil {
-		query += fmt.Sprintf(" AND status = $%d", argIndex)
-		args = append(args, *status)
+	if len(filter.Statuses) > 0 {
+		statuses := make([]string, len(filter.Statuses))
+		for i, status := range filter.Statuses {
+			statuses[i] = string(status)
+		}
+		query += fmt.Sprintf(" AND status = ANY($%d)", argIndex)
+		args = append(args, pq.Array(statuses))
 		argIndex++
 	}
 
-	if courierID != nil {
+	if filter.CourierID != nil {
 		query += fmt.Sprintf(" AND courier_id = $%d", argIndex)
-		args = append(args, *courierID)
+		args = append(args, *filter.CourierID)
+		argIndex++
+	} else if filter.Unassigned {
+		query += " AND courier_id IS NULL"
+	}
+
+	if filter.CreatedFrom != nil {
+		query += fmt.Sprintf(" AND created_at >= $%d", argIndex)
+		args = append(args, *filter.CreatedFrom)
 		argIndex++
 	}
 
-	query += " ORDER BY created_at DESC"
+	if filter.SLABreached {
+		query += " AND sla_breached_at IS NOT NULL"
+	}
+
+	switch filter.Sort {
+	case models.OrderSortCreatedAsc:
+		orderBy = " ORDER BY created_at ASC"
+	case models.OrderSortCreatedDesc:
+		orderBy = " ORDER BY created_at DESC"
+	}
+
+	query += orderBy
 
 	if limit > 0 {
 		query += fmt.Sprintf(" LIMIT $%d", argIndex)
@@ -222,7 +1391,7 @@ func (s *OrderService) GetOrders(status *models.OrderStatus, courierID *uuid.UUI
 		args = append(args, offset)
 	}
 
-	rows, err := s.db.Query(query, args...)
+	rows, err := s.db.QueryWithRetry(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get orders: %w", err)
 	}
@@ -230,10 +1399,8 @@ func (s *OrderService) GetOrders(status *models.OrderStatus, courierID *uuid.UUI
 
 	var orders []*models.Order
 	for rows.Next() {
-		order := &models.Order{}
-		if err := rows.Scan(&order.ID, &order.CustomerName, &order.CustomerPhone,
-			&order.DeliveryAddress, &order.TotalAmount, &order.Status,
-			&order.CourierID, &order.CreatedAt, &order.UpdatedAt, &order.DeliveredAt); err != nil {
+		order, err := scanOrder(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan order: %w", err)
 		}
 		orders = append(orders, order)
@@ -241,3 +1408,19 @@ func (s *OrderService) GetOrders(status *models.OrderStatus, courierID *uuid.UUI
 
 	return orders, nil
 }
+
+// GetUnassignedOrders возвращает созданные заказы без курьера, начиная с самых старых
+func (s *OrderService) GetUnassignedOrders(limit, offset int) ([]*models.Order, error) {
+	filter := &models.OrderFilter{
+		Statuses:   []models.OrderStatus{models.OrderStatusCreated},
+		Unassigned: true,
+		Sort:       models.OrderSortCreatedAsc,
+	}
+	return s.GetOrders(filter, limit, offset)
+}
+
+// escapeLikePattern экранирует спецсимволы шаблона LIKE, чтобы строка искалась буквально
+func escapeLikePattern(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+	return replacer.Replace(value)
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"delivery-system/internal/config"
+	"delivery-system/internal/database"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// createTestOrderWithItems создает заказ с маршрутом нулевой длины, позицией без SKU весом 1 кг
+// и позицией из каталога. Возвращает ID заказа и позиций
+func createTestOrderWithItems(t *testing.T, db *database.DB) (orderID, itemID, catalogItemID uuid.UUID) {
+	t.Helper()
+
+	orderID, itemID, catalogItemID = uuid.New(), uuid.New(), uuid.New()
+	_, err := db.Exec(`
+		INSERT INTO orders (id, customer_name, customer_phone, delivery_address, total_amount,
+		                    pickup_lat, pickup_lon, delivery_lat, delivery_lon, delivery_cost)
+		VALUES ($1, $2, $3, $4, 300, 55.75, 37.61, 55.75, 37.61, 100)
+	`, orderID, "Тестовый клиент", "+79990000000", "ул. Тестовая, д. 1")
+	if err != nil {
+		t.Fatalf("failed to create order: %v", err)
+	}
+	t.Cleanup(func() { db.Exec("DELETE FROM orders WHERE id = $1", orderID) })
+
+	_, err = db.Exec(`
+		INSERT INTO order_items (id, order_id, name, quantity, price, weight_grams, sku)
+		VALUES ($1, $3, 'Товар', 1, 100, 1000, NULL), ($2, $3, 'Товар из каталога', 1, 200, 0, 'TEST-SKU')
+	`, itemID, catalogItemID, orderID)
+	if err != nil {
+		t.Fatalf("failed to create order items: %v", err)
+	}
+	return orderID, itemID, catalogItemID
+}
+
+// newTestOrderService создает сервис заказов с тарифом без платы за расстояние:
+// 100 за доставку и 50 за каждый начатый килограмм сверх первого
+func newTestOrderService(db *database.DB, log *logger.Logger) *OrderService {
+	pricing := NewDeliveryPricingService(&config.DeliveryPricingConfig{
+		BasePrice:       100,
+		FreeWeightGrams: 1000,
+		PricePerKg:      50,
+	})
+	return NewOrderService(db, nil, pricing, &config.OrderConfig{}, log)
+}
+
+func TestUpdateOrderItemQuantity(t *testing.T) {
+	db, log := connectTestDB(t)
+	s := newTestOrderService(db, log)
+	orderID, itemID, _ := createTestOrderWithItems(t, db)
+
+	quantity := 3
+	item, oldTotal, newTotal, err := s.UpdateOrderItem(orderID, itemID, &models.UpdateOrderItemRequest{Quantity: &quantity})
+	if err != nil {
+		t.Fatalf("failed to update order item: %v", err)
+	}
+
+	if item.ID != itemID || item.Quantity != quantity || item.SKU != "" {
+		t.Fatalf("unexpected updated item: %+v", item)
+	}
+	if oldTotal != 30000 || newTotal != 50000 {
+		t.Fatalf("expected total to change from 300.00 to 500.00, got %s and %s", oldTotal, newTotal)
+	}
+
+	order, err := s.GetOrder(orderID)
+	if err != nil {
+		t.Fatalf("failed to get order: %v", err)
+	}
+	// 3 кг при бесплатном 1 кг: базовая цена 100 и по 50 за 2 кг сверх бесплатного веса
+	if order.DeliveryCost == nil || *order.DeliveryCost != 20000 {
+		t.Fatalf("expected delivery cost 200.00 after weight change, got %v", order.DeliveryCost)
+	}
+}
+
+func TestUpdateOrderItemCatalogPrice(t *testing.T) {
+	db, log := connectTestDB(t)
+	s := newTestOrderService(db, log)
+	orderID, _, catalogItemID := createTestOrderWithItems(t, db)
+
+	price := models.Money(1)
+	_, _, _, err := s.UpdateOrderItem(orderID, catalogItemID, &models.UpdateOrderItemRequest{Price: &price})
+	var serviceErr *Error
+	if !errors.As(err, &serviceErr) || serviceErr.Code != models.ErrorCodeOrderItemPriceFixed {
+		t.Fatalf("expected %s error, got %v", models.ErrorCodeOrderItemPriceFixed, err)
+	}
+
+	// Количество позиции из каталога менять можно, SKU возвращается в ответе
+	quantity := 2
+	item, _, newTotal, err := s.UpdateOrderItem(orderID, catalogItemID, &models.UpdateOrderItemRequest{Quantity: &quantity})
+	if err != nil {
+		t.Fatalf("failed to update catalog item quantity: %v", err)
+	}
+	if item.SKU != "TEST-SKU" || item.Price != 20000 {
+		t.Fatalf("unexpected updated catalog item: %+v", item)
+	}
+	if newTotal != 50000 {
+		t.Fatalf("expected total 500.00, got %s", newTotal)
+	}
+}
@@ -0,0 +1,387 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"delivery-system/internal/clock"
+	"delivery-system/internal/config"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/metrics"
+	"delivery-system/internal/redis"
+)
+
+// checkLimitScript атомарно проверяет блокировку и увеличивает счетчик запросов в текущем окне.
+// Запросы сверх лимита отклоняются и учитываются в счетчике превышений, который живет
+// в течение длительности блокировки; клиент блокируется, когда превышений набирается ARGV[6].
+// Каждая следующая блокировка в пределах ARGV[9] секунд после предыдущей длиннее в ARGV[7] раз,
+// но не дольше ARGV[8] секунд (0 - без ограничения).
+//
+// KEYS[1] - счетчик запросов, KEYS[2] - ключ блокировки, KEYS[3] - множество VIP клиентов,
+// KEYS[4] - счетчик превышений, KEYS[5] - счетчик блокировок
+// ARGV[1] - идентификатор клиента, ARGV[2] - обычный лимит, ARGV[3] - VIP лимит,
+// ARGV[4] - длительность окна в секундах, ARGV[5] - длительность блокировки в секундах,
+// ARGV[6] - число превышений до блокировки, ARGV[7] - множитель повторной блокировки,
+// ARGV[8] - максимальная длительность блокировки, ARGV[9] - сколько секунд помнить блокировки
+//
+// Возвращает {allowed, limit, remaining, ttl, banned, vip, new_ban, ban_level}
+const checkLimitScript = `
+local limit = tonumber(ARGV[2])
+local vip = redis.call('SISMEMBER', KEYS[3], ARGV[1])
+if vip == 1 then
+	limit = tonumber(ARGV[3])
+end
+
+local ban_level = tonumber(redis.call('GET', KEYS[5]) or '0')
+local ban_ttl = redis.call('TTL', KEYS[2])
+if ban_ttl > 0 then
+	return {0, limit, 0, ban_ttl, 1, vip, 0, ban_level}
+end
+
+local count = redis.call('INCR', KEYS[1])
+if count == 1 then
+	redis.call('EXPIRE', KEYS[1], tonumber(ARGV[4]))
+end
+local ttl = redis.call('TTL', KEYS[1])
+
+if count > limit then
+	local ban_duration = tonumber(ARGV[5])
+	if ban_duration > 0 then
+		local overage = redis.call('INCR', KEYS[4])
+		if overage == 1 then
+			redis.call('EXPIRE', KEYS[4], ban_duration)
+		end
+		if overage >= tonumber(ARGV[6]) then
+			ban_level = redis.call('INCR', KEYS[5])
+			redis.call('EXPIRE', KEYS[5], tonumber(ARGV[9]))
+
+			ban_duration = math.floor(ban_duration * tonumber(ARGV[7]) ^ (ban_level - 1))
+			local max_duration = tonumber(ARGV[8])
+			if max_duration > 0 and ban_duration > max_duration then
+				ban_duration = max_duration
+			end
+
+			redis.call('SET', KEYS[2], '1', 'EX', ban_duration)
+			redis.call('DEL', KEYS[4])
+			return {0, limit, 0, ban_duration, 1, vip, 1, ban_level}
+		end
+	end
+	return {0, limit, 0, ttl, 0, vip, 0, ban_level}
+end
+
+return {1, limit, limit - count, ttl, 0, vip, 0, ban_level}
+`
+
+// limitStatusScript возвращает состояние лимита клиента, не расходуя запрос.
+// Ключи и аргументы совпадают с checkLimitScript.
+const limitStatusScript = `
+local limit = tonumber(ARGV[2])
+local vip = redis.call('SISMEMBER', KEYS[3], ARGV[1])
+if vip == 1 then
+	limit = tonumber(ARGV[3])
+end
+
+local ban_level = tonumber(redis.call('GET', KEYS[5]) or '0')
+local ban_ttl = redis.call('TTL', KEYS[2])
+if ban_ttl > 0 then
+	return {0, limit, 0, ban_ttl, 1, vip, 0, ban_level}
+end
+
+local count = tonumber(redis.call('GET', KEYS[1]) or '0')
+local ttl = redis.call('TTL', KEYS[1])
+if ttl < 0 then
+	ttl = tonumber(ARGV[4])
+end
+
+local remaining = limit - count
+if remaining < 0 then
+	remaining = 0
+end
+
+return {1, limit, remaining, ttl, 0, vip, 0, ban_level}
+`
+
+// RateLimitResult представляет результат проверки лимита запросов
+type RateLimitResult struct {
+	Allowed   bool      `json:"allowed"`
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	ResetAt   time.Time `json:"reset_at"`
+	Banned    bool      `json:"banned"`
+	VIP       bool      `json:"vip"`
+	// BanLevel сколько раз клиент был заблокирован за RATE_LIMIT_BAN_HISTORY_TTL,
+	// от этого зависит длительность следующей блокировки
+	BanLevel int  `json:"ban_level"`
+	NewBan   bool `json:"-"` // блокировка установлена этим запросом
+}
+
+// RateLimitMetrics представляет счетчики событий лимитера, размеченные по признаку VIP
+type RateLimitMetrics struct {
+	Allowed  *metrics.Vec
+	Rejected *metrics.Vec
+	Bans     *metrics.Vec
+}
+
+// NewRateLimitMetrics регистрирует счетчики событий лимитера в реестре метрик
+func NewRateLimitMetrics(registry *metrics.Registry) *RateLimitMetrics {
+	return &RateLimitMetrics{
+		Allowed:  registry.NewCounter("delivery_rate_limit_allowed_total", "Requests allowed by the rate limiter", "vip"),
+		Rejected: registry.NewCounter("delivery_rate_limit_rejected_total", "Requests rejected by the rate limiter", "vip"),
+		Bans:     registry.NewCounter("delivery_rate_limit_bans_total", "Clients banned by the rate limiter", "vip"),
+	}
+}
+
+// RateLimiterService представляет сервис ограничения частоты запросов на основе Redis
+type RateLimiterService struct {
+	redisClient *redis.Client
+	cfg         *config.RateLimitConfig
+	clock       clock.Clock
+	metrics     *RateLimitMetrics
+	log         *logger.Logger
+}
+
+// NewRateLimiterService создает новый экземпляр сервиса ограничения частоты запросов
+func NewRateLimiterService(redisClient *redis.Client, cfg *config.RateLimitConfig, clk clock.Clock, rateLimitMetrics *RateLimitMetrics, log *logger.Logger) *RateLimiterService {
+	return &RateLimiterService{
+		redisClient: redisClient,
+		cfg:         cfg,
+		clock:       clk,
+		metrics:     rateLimitMetrics,
+		log:         log,
+	}
+}
+
+// CheckLimit учитывает запрос клиента и проверяет, не превышен ли лимит
+func (s *RateLimiterService) CheckLimit(ctx context.Context, identifier string) (*RateLimitResult, error) {
+	result, err := s.runScript(ctx, checkLimitScript, identifier)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check rate limit: %w", err)
+	}
+
+	s.recordMetrics(result)
+
+	if !result.Allowed {
+		s.log.WithFields(map[string]interface{}{
+			"client":    identifier,
+			"limit":     result.Limit,
+			"banned":    result.Banned,
+			"ban_level": result.BanLevel,
+		}).Warn("Rate limit exceeded")
+	}
+
+	return result, nil
+}
+
+// recordMetrics учитывает результат проверки в счетчиках лимитера
+func (s *RateLimiterService) recordMetrics(result *RateLimitResult) {
+	if s.metrics == nil {
+		return
+	}
+
+	vip := strconv.FormatBool(result.VIP)
+	if result.Allowed {
+		s.metrics.Allowed.Inc(vip)
+	} else {
+		s.metrics.Rejected.Inc(vip)
+	}
+	if result.NewBan {
+		s.metrics.Bans.Inc(vip)
+	}
+}
+
+// GetStatus возвращает текущее состояние лимита клиента без учета запроса
+func (s *RateLimiterService) GetStatus(ctx context.Context, identifier string) (*RateLimitResult, error) {
+	result, err := s.runScript(ctx, limitStatusScript, identifier)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rate limit status: %w", err)
+	}
+
+	return result, nil
+}
+
+// RateLimitBan представляет активную блокировку клиента лимитером
+type RateLimitBan struct {
+	Identifier string    `json:"identifier"`
+	TTLSeconds int       `json:"ttl_seconds"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// GetActiveBans возвращает клиентов, заблокированных в данный момент, в порядке истечения блокировки
+func (s *RateLimiterService) GetActiveBans(ctx context.Context) ([]*RateLimitBan, error) {
+	keys, err := s.redisClient.ScanKeys(ctx, redis.GenerateKey(redis.KeyPrefixRateLimitBan, "*"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rate limit bans: %w", err)
+	}
+
+	ttls, err := s.redisClient.TTLMultiple(ctx, keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rate limit ban ttl: %w", err)
+	}
+
+	now := s.clock.Now()
+	bans := make([]*RateLimitBan, 0, len(ttls))
+	for key, ttl := range ttls {
+		bans = append(bans, &RateLimitBan{
+			Identifier: strings.TrimPrefix(key, redis.KeyPrefixRateLimitBan+":"),
+			TTLSeconds: int(ttl.Seconds()),
+			ExpiresAt:  now.Add(ttl),
+		})
+	}
+
+	sort.Slice(bans, func(i, j int) bool {
+		return bans[i].ExpiresAt.Before(bans[j].ExpiresAt)
+	})
+
+	return bans, nil
+}
+
+// runScript выполняет Lua-скрипт лимитера и разбирает его ответ
+func (s *RateLimiterService) runScript(ctx context.Context, script, identifier string) (*RateLimitResult, error) {
+	keys := []string{
+		redis.GenerateKey(redis.KeyPrefixRateLimit, identifier),
+		redis.GenerateKey(redis.KeyPrefixRateLimitBan, identifier),
+		redis.KeyRateLimitVIP,
+		redis.GenerateKey(redis.KeyPrefixRateLimitOverage, identifier),
+		redis.GenerateKey(redis.KeyPrefixRateLimitBanCount, identifier),
+	}
+
+	raw, err := s.redisClient.Eval(ctx, script, keys,
+		identifier, s.cfg.DefaultRPM, s.cfg.VIPRPM, s.windowSeconds(), s.cfg.BanDuration, s.banThreshold(),
+		s.banEscalationFactor(), s.cfg.MaxBanDuration, s.banHistoryTTL())
+	if err != nil {
+		return nil, err
+	}
+
+	values, ok := raw.([]interface{})
+	if !ok || len(values) != 8 {
+		return nil, fmt.Errorf("unexpected script result: %v", raw)
+	}
+
+	nums := make([]int64, len(values))
+	for i, v := range values {
+		n, ok := v.(int64)
+		if !ok {
+			return nil, fmt.Errorf("unexpected script result value: %v", v)
+		}
+		nums[i] = n
+	}
+
+	return &RateLimitResult{
+		Allowed:   nums[0] == 1,
+		Limit:     int(nums[1]),
+		Remaining: int(nums[2]),
+		ResetAt:   s.clock.Now().Add(time.Duration(nums[3]) * time.Second),
+		Banned:    nums[4] == 1,
+		VIP:       nums[5] == 1,
+		NewBan:    nums[6] == 1,
+		BanLevel:  int(nums[7]),
+	}, nil
+}
+
+// banEscalationFactor возвращает множитель длительности повторной блокировки, не меньше единицы
+func (s *RateLimiterService) banEscalationFactor() float64 {
+	if s.cfg.BanEscalationFactor < 1 {
+		return 1
+	}
+	return s.cfg.BanEscalationFactor
+}
+
+// banHistoryTTL возвращает, сколько секунд помнить блокировки клиента, не меньше длительности блокировки
+func (s *RateLimiterService) banHistoryTTL() int {
+	if s.cfg.BanHistoryTTL < s.cfg.BanDuration {
+		return s.cfg.BanDuration
+	}
+	return s.cfg.BanHistoryTTL
+}
+
+// banThreshold возвращает число запросов сверх лимита до блокировки, не меньше одного
+func (s *RateLimiterService) banThreshold() int {
+	if s.cfg.BanThreshold < 1 {
+		return 1
+	}
+	return s.cfg.BanThreshold
+}
+
+// windowSeconds возвращает длительность окна подсчета, по умолчанию одна минута
+func (s *RateLimiterService) windowSeconds() int {
+	if s.cfg.WindowSeconds <= 0 {
+		return 60
+	}
+	return s.cfg.WindowSeconds
+}
+
+// acquireConcurrencyScript атомарно занимает место среди одновременных запросов клиента.
+// Если мест нет, счетчик не меняется. TTL счетчика продлевается при каждом занятии, чтобы места,
+// не освобожденные из-за остановки экземпляра сервиса, со временем вернулись клиенту.
+//
+// KEYS[1] - счетчик одновременных запросов
+// ARGV[1] - максимум одновременных запросов, ARGV[2] - TTL счетчика в секундах
+//
+// Возвращает {allowed, current}
+const acquireConcurrencyScript = `
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+if current >= tonumber(ARGV[1]) then
+	return {0, current}
+end
+
+current = redis.call('INCR', KEYS[1])
+redis.call('EXPIRE', KEYS[1], ARGV[2])
+return {1, current}
+`
+
+// releaseConcurrencyScript освобождает место, занятое acquireConcurrencyScript. Счетчик удаляется,
+// когда доходит до нуля; если он уже истек по TTL, ничего не происходит
+const releaseConcurrencyScript = `
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+if current <= 1 then
+	redis.call('DEL', KEYS[1])
+	return 0
+end
+return redis.call('DECR', KEYS[1])
+`
+
+// AcquireConcurrency занимает место среди одновременных запросов клиента с указанного IP.
+// Возвращает false, если клиент уже выполняет MaxConcurrentPerIP запросов. Занятое место
+// нужно освободить через ReleaseConcurrency после завершения запроса
+func (s *RateLimiterService) AcquireConcurrency(ctx context.Context, ip string) (bool, error) {
+	key := redis.GenerateKey(redis.KeyPrefixRateLimitConcurrency, ip)
+	raw, err := s.redisClient.Eval(ctx, acquireConcurrencyScript, []string{key}, s.cfg.MaxConcurrentPerIP, s.concurrencyTTL())
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire concurrency slot: %w", err)
+	}
+
+	values, ok := raw.([]interface{})
+	if !ok || len(values) != 2 {
+		return false, fmt.Errorf("unexpected script result: %v", raw)
+	}
+	allowed, _ := values[0].(int64)
+	if allowed != 1 {
+		s.log.WithFields(map[string]interface{}{
+			"ip":    ip,
+			"limit": s.cfg.MaxConcurrentPerIP,
+		}).Warn("Concurrent request limit exceeded")
+		return false, nil
+	}
+	return true, nil
+}
+
+// ReleaseConcurrency освобождает место, занятое AcquireConcurrency
+func (s *RateLimiterService) ReleaseConcurrency(ctx context.Context, ip string) error {
+	key := redis.GenerateKey(redis.KeyPrefixRateLimitConcurrency, ip)
+	if _, err := s.redisClient.Eval(ctx, releaseConcurrencyScript, []string{key}); err != nil {
+		return fmt.Errorf("failed to release concurrency slot: %w", err)
+	}
+	return nil
+}
+
+// concurrencyTTL возвращает TTL счетчика одновременных запросов, по умолчанию пять минут
+func (s *RateLimiterService) concurrencyTTL() int {
+	if s.cfg.ConcurrencyTTL <= 0 {
+		return 300
+	}
+	return s.cfg.ConcurrencyTTL
+}
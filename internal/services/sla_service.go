@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"delivery-system/internal/clock"
+	"delivery-system/internal/config"
+	"delivery-system/internal/database"
+	"delivery-system/internal/kafka"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// SLAService отслеживает заказы, не доставленные в установленный срок
+type SLAService struct {
+	db       *database.DB
+	producer *kafka.Producer
+	cfg      *config.SLAConfig
+	clock    clock.Clock
+	log      *logger.Logger
+}
+
+// NewSLAService создает новый экземпляр сервиса контроля сроков доставки
+func NewSLAService(db *database.DB, producer *kafka.Producer, cfg *config.SLAConfig, clk clock.Clock, log *logger.Logger) *SLAService {
+	return &SLAService{
+		db:       db,
+		producer: producer,
+		cfg:      cfg,
+		clock:    clk,
+		log:      log,
+	}
+}
+
+// Run периодически проверяет сроки доставки до отмены контекста.
+// Неположительный интервал проверки или срок доставки отключают мониторинг.
+func (s *SLAService) Run(ctx context.Context) {
+	interval := time.Duration(s.cfg.CheckInterval) * time.Second
+	if interval <= 0 || s.cfg.DeliveryTimeout <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.DetectBreaches(ctx); err != nil {
+				s.log.WithError(err).Error("Failed to detect delivery SLA breaches")
+			}
+		}
+	}
+}
+
+// DetectBreaches находит недоставленные и неотмененные заказы, созданные раньше срока доставки,
+// отмечает нарушение срока и публикует событие order.sla_breached. Каждый заказ отмечается один раз.
+func (s *SLAService) DetectBreaches(ctx context.Context) (int, error) {
+	now := s.clock.Now()
+	deadline := time.Duration(s.cfg.DeliveryTimeout) * time.Second
+	cutoff := now.Add(-deadline)
+
+	query := `
+		UPDATE orders
+		SET sla_breached_at = $1, version = version + 1
+		WHERE status NOT IN ($2, $3) AND sla_breached_at IS NULL AND created_at < $4
+		RETURNING id, status, courier_id, created_at
+	`
+
+	rows, err := s.db.Query(query, now, models.OrderStatusDelivered, models.OrderStatusCancelled, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark SLA breaches: %w", err)
+	}
+	defer rows.Close()
+
+	type breachedOrder struct {
+		id        uuid.UUID
+		status    models.OrderStatus
+		courierID *uuid.UUID
+		createdAt time.Time
+	}
+
+	var breached []breachedOrder
+	for rows.Next() {
+		var o breachedOrder
+		if err := rows.Scan(&o.id, &o.status, &o.courierID, &o.createdAt); err != nil {
+			return 0, fmt.Errorf("failed to scan SLA breached order: %w", err)
+		}
+		breached = append(breached, o)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read SLA breached orders: %w", err)
+	}
+
+	for _, o := range breached {
+		dueAt := o.createdAt.Add(deadline)
+		if err := s.producer.PublishOrderSLABreached(ctx, o.id, o.status, o.courierID, o.createdAt, dueAt); err != nil {
+			s.log.WithError(err).WithField("order_id", o.id).Error("Failed to publish order SLA breached event")
+		}
+
+		s.log.WithFields(map[string]interface{}{
+			"order_id":   o.id,
+			"status":     o.status,
+			"courier_id": o.courierID,
+			"due_at":     dueAt,
+		}).Warn("Order delivery SLA breached")
+	}
+
+	return len(breached), nil
+}
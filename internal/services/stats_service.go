@@ -0,0 +1,296 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"delivery-system/internal/database"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/metrics"
+	"delivery-system/internal/models"
+	"delivery-system/internal/redis"
+)
+
+// orderStatuses перечисляет все статусы заказа, чтобы в статистике присутствовали и нулевые значения
+var orderStatuses = []models.OrderStatus{
+	models.OrderStatusCreated,
+	models.OrderStatusAccepted,
+	models.OrderStatusPreparing,
+	models.OrderStatusReady,
+	models.OrderStatusInDelivery,
+	models.OrderStatusDelivered,
+	models.OrderStatusCancelled,
+}
+
+// orderStatsEventTTL сколько помнить учтенное в счетчиках событие, чтобы повторная доставка не учла его дважды
+const orderStatsEventTTL = 24 * time.Hour
+
+// moveOrderStatsScript учитывает событие в счетчиках заказов по статусам один раз:
+// уменьшает счетчик прежнего статуса (если ARGV[2] = 1) и увеличивает счетчик нового.
+// Пока счетчики не заполнены из базы данных, события не учитываются.
+// KEYS: [1] отметка события, [2] отметка заполнения, [3] счетчик прежнего статуса, [4] счетчик нового статуса
+// ARGV: [1] время хранения отметки события (сек), [2] 1 - уменьшить счетчик прежнего статуса
+const moveOrderStatsScript = `
+if redis.call('EXISTS', KEYS[2]) == 0 then
+	return 0
+end
+if not redis.call('SET', KEYS[1], 1, 'NX', 'EX', ARGV[1]) then
+	return 0
+end
+if ARGV[2] == '1' then
+	redis.call('DECR', KEYS[3])
+end
+redis.call('INCR', KEYS[4])
+return 1
+`
+
+// writeOrderStatsScript записывает счетчики (KEYS) значениями ARGV одной операцией.
+// Последний ключ - отметка заполнения счетчиков
+const writeOrderStatsScript = `
+for i, key in ipairs(KEYS) do
+	redis.call('SET', key, ARGV[i])
+end
+return 1
+`
+
+// seedOrderStatsScript записывает счетчики, только если их еще никто не заполнил: при запуске
+// экземпляра счетчики, которые уже ведут другие экземпляры, не перезаписываются
+const seedOrderStatsScript = `
+if redis.call('EXISTS', KEYS[#KEYS]) == 1 then
+	return 0
+end` + writeOrderStatsScript
+
+// StatsService представляет сервис агрегированной статистики. Количество заказов по статусам
+// хранится в счетчиках Redis, которые заполняются из базы данных при первом запуске (SeedOrderCounters),
+// обновляются по событиям Kafka и периодически сверяются с базой данных (ReconcileOrderCounters);
+// без счетчиков статистика считается запросом к базе данных
+type StatsService struct {
+	db          *database.DB
+	redisClient *redis.Client
+	log         *logger.Logger
+}
+
+// NewStatsService создает новый экземпляр сервиса статистики
+func NewStatsService(db *database.DB, redisClient *redis.Client, log *logger.Logger) *StatsService {
+	return &StatsService{
+		db:          db,
+		redisClient: redisClient,
+		log:         log,
+	}
+}
+
+// GetOrdersByStatus возвращает количество заказов в каждом статусе из счетчиков Redis,
+// а если они недоступны или не заполнены - из базы данных
+func (s *StatsService) GetOrdersByStatus(ctx context.Context) (map[models.OrderStatus]int, error) {
+	counts, ok, err := s.orderCounters(ctx)
+	if err != nil {
+		s.log.WithError(err).Warn("Failed to read order counters, counting orders in database")
+	}
+	if ok {
+		return counts, nil
+	}
+
+	return s.countOrdersByStatus()
+}
+
+// countOrdersByStatus считает заказы в каждом статусе запросом к базе данных
+func (s *StatsService) countOrdersByStatus() (map[models.OrderStatus]int, error) {
+	rows, err := s.db.QueryWithRetry("SELECT status, COUNT(*) FROM orders GROUP BY status")
+	if err != nil {
+		return nil, fmt.Errorf("failed to count orders by status: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[models.OrderStatus]int, len(orderStatuses))
+	for _, status := range orderStatuses {
+		counts[status] = 0
+	}
+
+	for rows.Next() {
+		var status models.OrderStatus
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan order status count: %w", err)
+		}
+		counts[status] = count
+	}
+
+	return counts, nil
+}
+
+// SeedOrderCounters заполняет счетчики заказов по статусам из базы данных, если они еще не заполнены.
+// Уже заполненные счетчики не меняются; расхождение с базой данных устраняет ReconcileOrderCounters
+func (s *StatsService) SeedOrderCounters(ctx context.Context) error {
+	counts, err := s.countOrdersByStatus()
+	if err != nil {
+		return err
+	}
+
+	seeded, err := s.writeOrderCounters(ctx, seedOrderStatsScript, counts)
+	if err != nil {
+		return fmt.Errorf("failed to seed order counters: %w", err)
+	}
+
+	if seeded {
+		s.log.WithField("counts", counts).Info("Order counters seeded from database")
+	}
+	return nil
+}
+
+// ReconcileOrderCounters перезаписывает счетчики заказов по статусам значениями counts, посчитанными
+// в базе данных, чтобы устранить накопившееся расхождение. Сверку выполняет один экземпляр сервиса
+// не чаще раза в interval
+func (s *StatsService) ReconcileOrderCounters(ctx context.Context, counts map[models.OrderStatus]int, interval time.Duration) error {
+	acquired, err := s.redisClient.SetNX(ctx, redis.KeyOrderStatsReconcileLock, time.Now().Unix(), interval)
+	if err != nil {
+		return fmt.Errorf("failed to acquire order counters reconcile lock: %w", err)
+	}
+	if !acquired {
+		return nil
+	}
+
+	if _, err := s.writeOrderCounters(ctx, writeOrderStatsScript, counts); err != nil {
+		return fmt.Errorf("failed to reconcile order counters: %w", err)
+	}
+
+	s.log.WithField("counts", counts).Debug("Order counters reconciled with database")
+	return nil
+}
+
+// writeOrderCounters выполняет script над счетчиками всех статусов и отметкой заполнения.
+// Возвращает false, если скрипт не записал счетчики
+func (s *StatsService) writeOrderCounters(ctx context.Context, script string, counts map[models.OrderStatus]int) (bool, error) {
+	keys := make([]string, 0, len(orderStatuses)+1)
+	args := make([]interface{}, 0, len(orderStatuses)+1)
+	for _, status := range orderStatuses {
+		keys = append(keys, orderCounterKey(status))
+		args = append(args, counts[status])
+	}
+	keys = append(keys, redis.KeyOrderStatsSeeded)
+	args = append(args, time.Now().Unix())
+
+	result, err := s.redisClient.Eval(ctx, script, keys, args...)
+	if err != nil {
+		return false, err
+	}
+	written, _ := result.(int64)
+	return written == 1, nil
+}
+
+// orderCounters читает счетчики заказов по статусам. Второе значение false, если счетчики не заполнены
+func (s *StatsService) orderCounters(ctx context.Context) (map[models.OrderStatus]int, bool, error) {
+	keys := make([]string, 0, len(orderStatuses)+1)
+	keys = append(keys, redis.KeyOrderStatsSeeded)
+	for _, status := range orderStatuses {
+		keys = append(keys, orderCounterKey(status))
+	}
+
+	values, err := s.redisClient.GetMultiple(ctx, keys)
+	if err != nil {
+		return nil, false, err
+	}
+	if _, ok := values[redis.KeyOrderStatsSeeded]; !ok {
+		return nil, false, nil
+	}
+
+	counts := make(map[models.OrderStatus]int, len(orderStatuses))
+	for _, status := range orderStatuses {
+		count, err := strconv.Atoi(values[orderCounterKey(status)])
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid order counter for status %s: %w", status, err)
+		}
+		counts[status] = count
+	}
+	return counts, true, nil
+}
+
+// HandleOrderCreated реализует kafka.EventHandler для события order.created
+func (s *StatsService) HandleOrderCreated(ctx context.Context, event *models.Event) error {
+	return s.moveOrderCounter(ctx, event, "", models.OrderStatusCreated)
+}
+
+// HandleOrderStatusChanged реализует kafka.EventHandler для события order.status_changed
+func (s *StatsService) HandleOrderStatusChanged(ctx context.Context, event *models.Event) error {
+	var data models.OrderStatusChangedEvent
+	if err := event.DecodeData(&data); err != nil {
+		return err
+	}
+	if data.OldStatus == data.NewStatus {
+		return nil
+	}
+
+	return s.moveOrderCounter(ctx, event, data.OldStatus, data.NewStatus)
+}
+
+// HandleCourierAssigned реализует kafka.EventHandler для события courier.assigned:
+// назначение курьера переводит заказ из created в accepted без события order.status_changed
+func (s *StatsService) HandleCourierAssigned(ctx context.Context, event *models.Event) error {
+	return s.moveOrderCounter(ctx, event, models.OrderStatusCreated, models.OrderStatusAccepted)
+}
+
+// moveOrderCounter переносит заказ из счетчика статуса from (пустой - новый заказ) в счетчик статуса to.
+// Ошибка возвращается, чтобы потребитель повторил обработку события
+func (s *StatsService) moveOrderCounter(ctx context.Context, event *models.Event, from, to models.OrderStatus) error {
+	decrement := "0"
+	fromKey := orderCounterKey(to)
+	if from != "" {
+		decrement = "1"
+		fromKey = orderCounterKey(from)
+	}
+
+	keys := []string{
+		redis.GenerateKey(redis.KeyPrefixOrderStatsEvent, event.ID.String()),
+		redis.KeyOrderStatsSeeded,
+		fromKey,
+		orderCounterKey(to),
+	}
+	if _, err := s.redisClient.Eval(ctx, moveOrderStatsScript, keys, int(orderStatsEventTTL.Seconds()), decrement); err != nil {
+		return fmt.Errorf("failed to update order counters for event %s: %w", event.ID, err)
+	}
+	return nil
+}
+
+// orderCounterKey возвращает ключ счетчика заказов в статусе
+func orderCounterKey(status models.OrderStatus) string {
+	return redis.GenerateKey(redis.KeyPrefixOrderStats, string(status))
+}
+
+// RunOrderStatusGauge периодически обновляет gauge распределения заказов по статусам запросом
+// к базе данных и сверяет с ним счетчики Redis до отмены контекста. Неположительный интервал отключает обновление.
+func (s *StatsService) RunOrderStatusGauge(ctx context.Context, gauge *metrics.Vec, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.refreshOrderStatusGauge(ctx, gauge, interval)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshOrderStatusGauge выполняет одно обновление gauge и сверку счетчиков
+func (s *StatsService) refreshOrderStatusGauge(ctx context.Context, gauge *metrics.Vec, interval time.Duration) {
+	counts, err := s.countOrdersByStatus()
+	if err != nil {
+		s.log.WithError(err).Error("Failed to refresh order status metrics")
+		return
+	}
+
+	for status, count := range counts {
+		gauge.Set(float64(count), string(status))
+	}
+
+	if err := s.ReconcileOrderCounters(ctx, counts, interval); err != nil {
+		s.log.WithError(err).Warn("Failed to reconcile order counters")
+	}
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"delivery-system/internal/database"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// WebhookService представляет сервис для работы с подписками на webhooks
+type WebhookService struct {
+	db  *database.DB
+	log *logger.Logger
+}
+
+// NewWebhookService создает новый экземпляр сервиса подписок
+func NewWebhookService(db *database.DB, log *logger.Logger) *WebhookService {
+	return &WebhookService{
+		db:  db,
+		log: log,
+	}
+}
+
+// webhookColumns список колонок подписки в порядке, ожидаемом scanWebhookSubscription
+const webhookColumns = `id, url, event_types, secret, active, created_at, updated_at`
+
+// CreateSubscription создает подписку. Секрет генерируется, если не передан в запросе
+func (s *WebhookService) CreateSubscription(req *models.CreateWebhookSubscriptionRequest) (*models.WebhookSubscription, error) {
+	secret := req.Secret
+	if secret == "" {
+		generated, err := generateWebhookSecret()
+		if err != nil {
+			return nil, err
+		}
+		secret = generated
+	}
+
+	now := time.Now()
+	subscription := &models.WebhookSubscription{
+		ID:         uuid.New(),
+		URL:        req.URL,
+		EventTypes: req.EventTypes,
+		Secret:     secret,
+		Active:     true,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	query := `
+		INSERT INTO webhook_subscriptions (id, url, event_types, secret, active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err := s.db.Exec(query, subscription.ID, subscription.URL, pq.Array(eventTypeStrings(subscription.EventTypes)),
+		subscription.Secret, subscription.Active, subscription.CreatedAt, subscription.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook subscription: %w", err)
+	}
+
+	s.log.WithFields(map[string]interface{}{
+		"subscription_id": subscription.ID,
+		"url":             subscription.URL,
+		"event_types":     subscription.EventTypes,
+	}).Info("Webhook subscription created")
+
+	return subscription, nil
+}
+
+// GetSubscription получает подписку по ID
+func (s *WebhookService) GetSubscription(subscriptionID uuid.UUID) (*models.WebhookSubscription, error) {
+	query := "SELECT " + webhookColumns + " FROM webhook_subscriptions WHERE id = $1"
+
+	subscription, err := scanWebhookSubscription(s.db.QueryRowWithRetry(query, subscriptionID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, newError(models.ErrorCodeWebhookSubscriptionNotFound, "webhook subscription not found")
+		}
+		return nil, fmt.Errorf("failed to get webhook subscription: %w", err)
+	}
+
+	return subscription, nil
+}
+
+// GetSubscriptions получает список всех подписок
+func (s *WebhookService) GetSubscriptions() ([]*models.WebhookSubscription, error) {
+	query := "SELECT " + webhookColumns + " FROM webhook_subscriptions ORDER BY created_at DESC"
+
+	rows, err := s.db.QueryWithRetry(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	return scanWebhookSubscriptions(rows)
+}
+
+// GetSubscriptionsForEvent получает активные подписки на указанный тип события
+func (s *WebhookService) GetSubscriptionsForEvent(eventType models.EventType) ([]*models.WebhookSubscription, error) {
+	query := "SELECT " + webhookColumns + " FROM webhook_subscriptions WHERE active AND $1 = ANY(event_types)"
+
+	rows, err := s.db.QueryWithRetry(query, string(eventType))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	return scanWebhookSubscriptions(rows)
+}
+
+// UpdateSubscription изменяет переданные поля подписки
+func (s *WebhookService) UpdateSubscription(subscriptionID uuid.UUID, req *models.UpdateWebhookSubscriptionRequest) (*models.WebhookSubscription, error) {
+	query := `
+		UPDATE webhook_subscriptions
+		SET url = COALESCE($1, url),
+		    event_types = COALESCE($2, event_types),
+		    active = COALESCE($3, active),
+		    updated_at = $4
+		WHERE id = $5
+		RETURNING ` + webhookColumns
+
+	var eventTypes interface{}
+	if len(req.EventTypes) > 0 {
+		eventTypes = pq.Array(eventTypeStrings(req.EventTypes))
+	}
+
+	subscription, err := scanWebhookSubscription(s.db.QueryRow(query, req.URL, eventTypes, req.Active, time.Now(), subscriptionID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, newError(models.ErrorCodeWebhookSubscriptionNotFound, "webhook subscription not found")
+		}
+		return nil, fmt.Errorf("failed to update webhook subscription: %w", err)
+	}
+
+	s.log.WithField("subscription_id", subscriptionID).Info("Webhook subscription updated")
+
+	return subscription, nil
+}
+
+// DeleteSubscription удаляет подписку
+func (s *WebhookService) DeleteSubscription(subscriptionID uuid.UUID) error {
+	result, err := s.db.Exec("DELETE FROM webhook_subscriptions WHERE id = $1", subscriptionID)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook subscription: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return newError(models.ErrorCodeWebhookSubscriptionNotFound, "webhook subscription not found")
+	}
+
+	s.log.WithField("subscription_id", subscriptionID).Info("Webhook subscription deleted")
+
+	return nil
+}
+
+// scanWebhookSubscription считывает подписку из строки, выбранной с колонками webhookColumns
+func scanWebhookSubscription(row rowScanner) (*models.WebhookSubscription, error) {
+	subscription := &models.WebhookSubscription{}
+	var eventTypes []string
+	err := row.Scan(&subscription.ID, &subscription.URL, pq.Array(&eventTypes), &subscription.Secret,
+		&subscription.Active, &subscription.CreatedAt, &subscription.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	subscription.EventTypes = make([]models.EventType, len(eventTypes))
+	for i, t := range eventTypes {
+		subscription.EventTypes[i] = models.EventType(t)
+	}
+
+	return subscription, nil
+}
+
+// scanWebhookSubscriptions считывает подписки из результата запроса
+func scanWebhookSubscriptions(rows *sql.Rows) ([]*models.WebhookSubscription, error) {
+	var subscriptions []*models.WebhookSubscription
+	for rows.Next() {
+		subscription, err := scanWebhookSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook subscription: %w", err)
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate webhook subscriptions: %w", err)
+	}
+
+	return subscriptions, nil
+}
+
+// eventTypeStrings преобразует типы событий в строки для хранения в массиве PostgreSQL
+func eventTypeStrings(eventTypes []models.EventType) []string {
+	result := make([]string, len(eventTypes))
+	for i, t := range eventTypes {
+		result[i] = string(t)
+	}
+	return result
+}
+
+// generateWebhookSecret генерирует случайный секрет для подписи запросов
+func generateWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,224 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"delivery-system/internal/config"
+	"delivery-system/internal/kafka"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+	"delivery-system/internal/services"
+)
+
+// errPermanent означает, что подписчик отклонил запрос и повтор не поможет
+var errPermanent = errors.New("permanent delivery failure")
+
+// Причины, по которым событие не было отправлено подписчику
+var (
+	errQueueFull = errors.New("webhook delivery queue is full")
+	errShutdown  = errors.New("dispatcher stopped before delivery")
+)
+
+// delivery представляет событие, ожидающее доставки одному подписчику
+type delivery struct {
+	subscription *models.WebhookSubscription
+	event        *models.Event
+	payload      []byte
+}
+
+// Dispatcher доставляет события Kafka подписчикам webhooks. Consumer только ставит доставки в очередь,
+// запросы к подписчикам с повторами выполняют воркеры, запущенные Run, поэтому медленный подписчик
+// не задерживает обработку партиции
+type Dispatcher struct {
+	webhookService *services.WebhookService
+	producer       *kafka.Producer
+	client         *http.Client
+	queue          chan *delivery
+	cfg            *config.WebhookConfig
+	log            *logger.Logger
+}
+
+// NewDispatcher создает новый диспетчер webhooks
+func NewDispatcher(webhookService *services.WebhookService, producer *kafka.Producer, cfg *config.WebhookConfig, log *logger.Logger) *Dispatcher {
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	// Прокси не используется: подключение к прокси обошло бы проверку адреса подписчика
+	transport := &http.Transport{
+		DialContext:         newDialer(timeout, cfg.AllowPrivateTargets).DialContext,
+		TLSHandshakeTimeout: timeout,
+		MaxIdleConnsPerHost: 4,
+		IdleConnTimeout:     90 * time.Second,
+	}
+
+	return &Dispatcher{
+		webhookService: webhookService,
+		producer:       producer,
+		client:         &http.Client{Timeout: timeout, Transport: transport},
+		queue:          make(chan *delivery, max(cfg.QueueSize, 1)),
+		cfg:            cfg,
+		log:            log,
+	}
+}
+
+// HandleEvent реализует kafka.EventHandler: ставит событие в очередь доставки всем активным подписчикам
+// на его тип. Ошибка возвращается только если не удалось получить подписки, чтобы consumer
+// повторил обработку. Если очередь заполнена, событие сразу отправляется в топик недоставленных сообщений
+func (d *Dispatcher) HandleEvent(ctx context.Context, event *models.Event) error {
+	subscriptions, err := d.webhookService.GetSubscriptionsForEvent(event.Type)
+	if err != nil {
+		return err
+	}
+	if len(subscriptions) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	for _, subscription := range subscriptions {
+		job := &delivery{subscription: subscription, event: event, payload: payload}
+		select {
+		case d.queue <- job:
+		default:
+			d.log.WithField("subscription_id", subscription.ID).
+				WithField("event_id", event.ID).
+				Error("Webhook delivery queue is full")
+			d.deadLetter(ctx, job, errQueueFull)
+		}
+	}
+
+	return nil
+}
+
+// Run запускает воркеры доставки и блокируется до отмены ctx. Доставки, оставшиеся в очереди
+// при остановке, отправляются в топик недоставленных сообщений
+func (d *Dispatcher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < max(d.cfg.Workers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-d.queue:
+					d.deliverWithRetry(ctx, job)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Контекст уже отменен, поэтому публикация выполняется с собственным таймаутом
+	drainCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for {
+		select {
+		case job := <-d.queue:
+			d.deadLetter(drainCtx, job, errShutdown)
+		default:
+			return
+		}
+	}
+}
+
+// deliverWithRetry доставляет событие подписчику с экспоненциальной паузой между попытками
+func (d *Dispatcher) deliverWithRetry(ctx context.Context, job *delivery) {
+	subscription, event, payload := job.subscription, job.event, job.payload
+	backoff := time.Duration(d.cfg.RetryBackoff) * time.Second
+
+	err := d.deliver(ctx, subscription, event, payload)
+	for attempt := 1; err != nil && !errors.Is(err, errPermanent) && attempt <= d.cfg.MaxRetries; attempt++ {
+		d.log.WithError(err).
+			WithField("subscription_id", subscription.ID).
+			WithField("event_id", event.ID).
+			WithField("attempt", attempt).
+			Warn("Retrying webhook delivery")
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		backoff *= 2
+
+		err = d.deliver(ctx, subscription, event, payload)
+	}
+
+	if err == nil {
+		return
+	}
+
+	// При остановке диспетчера доставка прерывается, а событие сохраняется в топике недоставленных сообщений
+	if ctx.Err() != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		d.deadLetter(shutdownCtx, job, errShutdown)
+		return
+	}
+
+	d.log.WithError(err).
+		WithField("subscription_id", subscription.ID).
+		WithField("event_id", event.ID).
+		Error("Webhook delivery failed")
+
+	d.deadLetter(ctx, job, err)
+}
+
+// deadLetter отправляет недоставленное событие в топик недоставленных сообщений, если он настроен
+func (d *Dispatcher) deadLetter(ctx context.Context, job *delivery, reason error) {
+	if d.cfg.DeadLetterTopic == "" {
+		return
+	}
+	err := d.producer.PublishWebhookDeadLetter(ctx, d.cfg.DeadLetterTopic, job.subscription.ID, job.subscription.URL,
+		job.payload, job.event.Type, reason)
+	if err != nil {
+		d.log.WithError(err).Error("Failed to send webhook to dead letter topic")
+	}
+}
+
+// deliver выполняет одну попытку доставки события подписчику
+func (d *Dispatcher) deliver(ctx context.Context, subscription *models.WebhookSubscription, event *models.Event, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("%w: failed to create request: %v", errPermanent, err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "delivery-system-webhooks")
+	req.Header.Set(HeaderSignature, Sign(subscription.Secret, time.Now(), payload))
+	req.Header.Set(HeaderEventType, string(event.Type))
+	req.Header.Set(HeaderEventID, event.ID.String())
+	req.Header.Set(HeaderSubscription, subscription.ID.String())
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrForbiddenTarget) {
+			return fmt.Errorf("%w: %v", errPermanent, err)
+		}
+		return fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("subscriber responded with status %d", resp.StatusCode)
+	default:
+		return fmt.Errorf("%w: subscriber responded with status %d", errPermanent, resp.StatusCode)
+	}
+}
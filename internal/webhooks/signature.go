@@ -0,0 +1,70 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Заголовки запроса, отправляемого подписчику
+const (
+	HeaderSignature    = "X-Webhook-Signature"
+	HeaderEventType    = "X-Webhook-Event"
+	HeaderEventID      = "X-Webhook-Event-ID"
+	HeaderSubscription = "X-Webhook-Subscription"
+)
+
+// Sign вычисляет значение заголовка X-Webhook-Signature в формате "t=<unix>,v1=<hex>".
+// Подпись - HMAC-SHA256 от строки "<unix>.<тело запроса>" с секретом подписки
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", ts, computeSignature(secret, ts, body))
+}
+
+// Verify проверяет заголовок подписи и допустимое отклонение времени подписи.
+// Предназначена для получателей webhooks, написанных на Go
+func Verify(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var ts, signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			signature = value
+		}
+	}
+	if ts == "" || signature == "" {
+		return fmt.Errorf("invalid signature header")
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid signature timestamp")
+	}
+	if tolerance > 0 {
+		if diff := now.Sub(time.Unix(unix, 0)); diff > tolerance || diff < -tolerance {
+			return fmt.Errorf("signature timestamp outside tolerance")
+		}
+	}
+
+	expected := computeSignature(secret, ts, body)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return fmt.Errorf("signature mismatch")
+	}
+
+	return nil
+}
+
+// computeSignature вычисляет HMAC-SHA256 от "<timestamp>.<body>"
+func computeSignature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
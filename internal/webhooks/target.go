@@ -0,0 +1,66 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenTarget означает, что адрес подписчика ведет во внутреннюю сеть сервиса
+var ErrForbiddenTarget = errors.New("webhook target address is not allowed")
+
+// IsForbiddenIP сообщает, что адрес не может быть адресом подписчика: loopback, частные сети,
+// link-local (в том числе сервис метаданных облака 169.254.169.254), multicast и неуказанный адрес
+func IsForbiddenIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified()
+}
+
+// CheckHost разрешает имя хоста подписчика и возвращает ErrForbiddenTarget, если хотя бы один
+// из его адресов запрещен. Проверка при создании подписки не заменяет проверку при подключении:
+// DNS-запись может измениться позже
+func CheckHost(ctx context.Context, host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		if IsForbiddenIP(ip) {
+			return ErrForbiddenTarget
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("failed to resolve webhook host: %w", err)
+	}
+	for _, addr := range addrs {
+		if IsForbiddenIP(addr.IP) {
+			return ErrForbiddenTarget
+		}
+	}
+	return nil
+}
+
+// newDialer создает dialer, который отказывается подключаться к запрещенным адресам.
+// Control вызывается для уже разрешенного адреса, поэтому проверка действует и при смене DNS-записи,
+// и при перенаправлениях
+func newDialer(timeout time.Duration, allowPrivate bool) *net.Dialer {
+	dialer := &net.Dialer{Timeout: timeout}
+	if allowPrivate {
+		return dialer
+	}
+
+	dialer.Control = func(network, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		ip := net.ParseIP(host)
+		if ip == nil || IsForbiddenIP(ip) {
+			return fmt.Errorf("%w: %s", ErrForbiddenTarget, address)
+		}
+		return nil
+	}
+	return dialer
+}
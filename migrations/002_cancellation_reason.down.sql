@@ -0,0 +1,3 @@
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS cancellation_comment,
+    DROP COLUMN IF EXISTS cancellation_reason;
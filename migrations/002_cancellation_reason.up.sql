@@ -0,0 +1,4 @@
+-- Причина отмены заказа
+ALTER TABLE orders
+    ADD COLUMN cancellation_reason VARCHAR(32) CHECK (cancellation_reason IN ('customer_request', 'no_courier', 'restaurant_closed', 'other')),
+    ADD COLUMN cancellation_comment TEXT;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_orders_status_created_at;
+
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS escalated_at,
+    DROP COLUMN IF EXISTS priority;
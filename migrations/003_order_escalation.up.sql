@@ -0,0 +1,6 @@
+-- Приоритет заказа и отметка об эскалации неназначенных заказов
+ALTER TABLE orders
+    ADD COLUMN priority INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN escalated_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_orders_status_created_at ON orders(status, created_at) WHERE escalated_at IS NULL;
@@ -0,0 +1,13 @@
+-- Рабочие смены курьеров
+CREATE TABLE courier_shifts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    courier_id UUID NOT NULL REFERENCES couriers(id) ON DELETE CASCADE,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    ended_at TIMESTAMP WITH TIME ZONE,
+    CHECK (ended_at IS NULL OR ended_at >= started_at)
+);
+
+CREATE INDEX idx_courier_shifts_courier_id ON courier_shifts(courier_id);
+
+-- У курьера может быть только одна открытая смена
+CREATE UNIQUE INDEX idx_courier_shifts_open ON courier_shifts(courier_id) WHERE ended_at IS NULL;
@@ -0,0 +1,4 @@
+-- Координаты точки забора заказа (ресторан, склад)
+ALTER TABLE orders
+    ADD COLUMN pickup_lat DECIMAL(10, 8),
+    ADD COLUMN pickup_lon DECIMAL(11, 8);
@@ -0,0 +1,3 @@
+-- Версия заказа для оптимистичной блокировки при конкурентных изменениях
+ALTER TABLE orders
+    ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,3 @@
+-- Признак работы курьера в компании. Деактивированные курьеры не участвуют в назначении заказов
+ALTER TABLE couriers
+    ADD COLUMN active BOOLEAN NOT NULL DEFAULT TRUE;
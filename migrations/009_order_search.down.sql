@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_orders_search_tsv;
+DROP INDEX IF EXISTS idx_orders_delivery_address_trgm;
+DROP INDEX IF EXISTS idx_orders_customer_phone_trgm;
+DROP INDEX IF EXISTS idx_orders_customer_name_trgm;
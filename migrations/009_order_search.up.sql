@@ -0,0 +1,7 @@
+-- Индексы для поиска заказов по имени клиента, телефону и адресу
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX idx_orders_customer_name_trgm ON orders USING GIN (customer_name gin_trgm_ops);
+CREATE INDEX idx_orders_customer_phone_trgm ON orders USING GIN (customer_phone gin_trgm_ops);
+CREATE INDEX idx_orders_delivery_address_trgm ON orders USING GIN (delivery_address gin_trgm_ops);
+CREATE INDEX idx_orders_search_tsv ON orders USING GIN (to_tsvector('simple', customer_name || ' ' || delivery_address));
@@ -0,0 +1,9 @@
+-- Подтверждение доставки: фото и подпись получателя, прикладываемые курьером при завершении заказа
+CREATE TABLE delivery_proofs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    order_id UUID NOT NULL UNIQUE REFERENCES orders(id) ON DELETE CASCADE,
+    courier_id UUID REFERENCES couriers(id),
+    photo_ref TEXT NOT NULL,
+    signature_ref TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,2 @@
+ALTER TABLE order_items
+    DROP COLUMN IF EXISTS weight_grams;
@@ -0,0 +1,3 @@
+-- Вес одной единицы товара в граммах для расчета надбавки за тяжелые заказы
+ALTER TABLE order_items
+    ADD COLUMN weight_grams INTEGER NOT NULL DEFAULT 0 CHECK (weight_grams >= 0);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_orders_sla_breached_at;
+
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS sla_breached_at;
@@ -0,0 +1,5 @@
+-- Отметка о нарушении срока доставки заказа
+ALTER TABLE orders
+    ADD COLUMN sla_breached_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_orders_sla_breached_at ON orders(sla_breached_at) WHERE sla_breached_at IS NOT NULL;
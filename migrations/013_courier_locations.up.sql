@@ -0,0 +1,11 @@
+-- История местоположений курьеров
+CREATE TABLE courier_locations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    courier_id UUID NOT NULL REFERENCES couriers(id) ON DELETE CASCADE,
+    lat DECIMAL(10, 8) NOT NULL,
+    lon DECIMAL(11, 8) NOT NULL,
+    recorded_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_courier_locations_courier_recorded_at ON courier_locations(courier_id, recorded_at);
@@ -0,0 +1,3 @@
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS delivery_lat,
+    DROP COLUMN IF EXISTS delivery_lon;
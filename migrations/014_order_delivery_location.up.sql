@@ -0,0 +1,4 @@
+-- Координаты точки доставки заказа
+ALTER TABLE orders
+    ADD COLUMN delivery_lat DECIMAL(10, 8),
+    ADD COLUMN delivery_lon DECIMAL(11, 8);
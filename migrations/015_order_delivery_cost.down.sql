@@ -0,0 +1,3 @@
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS delivery_cost,
+    DROP COLUMN IF EXISTS delivery_cost_overridden;
@@ -0,0 +1,5 @@
+-- Стоимость доставки заказа. delivery_cost_overridden отмечает стоимость, установленную оператором вручную,
+-- которую пересчет по тарифам не изменяет
+ALTER TABLE orders
+    ADD COLUMN delivery_cost DECIMAL(10, 2),
+    ADD COLUMN delivery_cost_overridden BOOLEAN NOT NULL DEFAULT FALSE;
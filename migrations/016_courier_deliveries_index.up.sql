@@ -0,0 +1,2 @@
+-- Индекс для истории доставок курьера
+CREATE INDEX idx_orders_courier_delivered_at ON orders(courier_id, delivered_at DESC) WHERE status = 'delivered';
@@ -0,0 +1,4 @@
+ALTER TABLE order_items
+    DROP COLUMN IF EXISTS sku;
+
+DROP TABLE IF EXISTS products;
@@ -0,0 +1,20 @@
+-- Каталог товаров с ценами. Позиции заказа со ссылкой на SKU получают цену из каталога,
+-- а не из запроса клиента
+CREATE TABLE products (
+    sku VARCHAR(64) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    price DECIMAL(10, 2) NOT NULL CHECK (price >= 0),
+    weight_grams INTEGER NOT NULL DEFAULT 0 CHECK (weight_grams >= 0),
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE TRIGGER update_products_updated_at
+    BEFORE UPDATE ON products
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- SKU сохраняется как есть, без внешнего ключа: удаление товара из каталога не затрагивает заказы
+ALTER TABLE order_items
+    ADD COLUMN sku VARCHAR(64);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_couriers_skills;
+
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS required_skills;
+
+ALTER TABLE couriers
+    DROP COLUMN IF EXISTS skills;
@@ -0,0 +1,10 @@
+-- Навыки курьера (например refrigerated, large_item, alcohol_verified) и навыки,
+-- необходимые для доставки заказа. Курьер подходит заказу, только если у него есть все требуемые навыки
+ALTER TABLE couriers
+    ADD COLUMN skills TEXT[] NOT NULL DEFAULT '{}';
+
+ALTER TABLE orders
+    ADD COLUMN required_skills TEXT[] NOT NULL DEFAULT '{}';
+
+-- Фильтр списка курьеров по навыку (skills @> ARRAY[...])
+CREATE INDEX idx_couriers_skills ON couriers USING GIN (skills);